@@ -0,0 +1,247 @@
+package communication
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"time"
+)
+
+// BasebandConfig configures GenerateBaseband.
+type BasebandConfig struct {
+	Modulation          Modulation
+	SymbolRate          float64 // in symbols per second
+	SamplesPerSymbol    int     // Oversampling, at least 2; even for OQPSK
+	RollOff             float64 // Root-raised-cosine excess bandwidth; 0 means 0.35
+	FilterSpan          int     // Pulse length in symbols; 0 means 10
+	BT                  float64 // GMSK Gaussian bandwidth-time product; 0 means 0.3
+	CarrierOffset       float64 // in Hz
+	DopplerRate         float64 // Carrier frequency ramp, in Hz/s
+	Phase               float64 // Initial carrier phase, in radians
+	PhaseNoiseLinewidth float64 // Lorentzian (Wiener phase noise) linewidth in Hz; 0 disables
+	EsN0dB              float64 // Symbol energy to noise density ratio of the added AWGN
+	Noiseless           bool    // Skip the AWGN; EsN0dB is ignored
+	Seed                int64   // Seeds the bits, phase noise and AWGN
+}
+
+// BasebandSignal is a generated block of complex baseband samples. Symbol k is centred on
+// sample k*SamplesPerSymbol; for OQPSK that is the centre of its in-phase component.
+type BasebandSignal struct {
+	Config        BasebandConfig // With defaults filled in
+	SampleRate    float64        // in Hz
+	Samples       []complex128   // Unit mean power before noise
+	Symbols       []complex128   // Transmitted constellation points; ±1 for GMSK
+	Bits          []int
+	NoiseVariance float64 // Complex AWGN power per sample; 0 when noiseless
+}
+
+// GenerateBaseband generates the given number of random symbols with the configured
+// modulation, pulse shaping and impairments. The same configuration and seed always produce
+// the same samples, and the bits and phase noise do not depend on EsN0dB or Noiseless, so a
+// noiseless copy of a noisy signal can be generated for comparison.
+func GenerateBaseband(config BasebandConfig, symbols int) (*BasebandSignal, error) {
+	if config.Modulation < ModulationBPSK || config.Modulation > ModulationGMSK {
+		return nil, fmt.Errorf("unknown modulation %v", config.Modulation)
+	}
+	if config.SymbolRate <= 0 {
+		return nil, fmt.Errorf("symbol rate must be positive, got %v", config.SymbolRate)
+	}
+	sps := config.SamplesPerSymbol
+	if sps < 2 {
+		return nil, fmt.Errorf("samples per symbol must be at least 2, got %d", sps)
+	}
+	if config.Modulation == ModulationOQPSK && sps%2 != 0 {
+		return nil, fmt.Errorf("OQPSK needs an even number of samples per symbol, got %d", sps)
+	}
+	if symbols < 1 {
+		return nil, fmt.Errorf("need at least one symbol, got %d", symbols)
+	}
+	if config.PhaseNoiseLinewidth < 0 {
+		return nil, fmt.Errorf("phase noise linewidth must not be negative, got %v", config.PhaseNoiseLinewidth)
+	}
+	if config.RollOff == 0 {
+		config.RollOff = 0.35
+	}
+	if config.RollOff < 0 || config.RollOff > 1 {
+		return nil, fmt.Errorf("roll-off must be in (0, 1], got %v", config.RollOff)
+	}
+	if config.FilterSpan == 0 {
+		config.FilterSpan = 10
+	}
+	if config.FilterSpan < 0 {
+		return nil, fmt.Errorf("filter span must be positive, got %d", config.FilterSpan)
+	}
+	if config.BT == 0 {
+		config.BT = 0.3
+	}
+	if config.BT < 0 {
+		return nil, fmt.Errorf("BT must be positive, got %v", config.BT)
+	}
+
+	rng := rand.New(rand.NewSource(config.Seed))
+	bits := make([]int, symbols*config.Modulation.BitsPerSymbol())
+	for i := range bits {
+		bits[i] = rng.Intn(2)
+	}
+	points := config.Modulation.Map(bits)
+	n := symbols * sps
+	sampleRate := config.SymbolRate * float64(sps)
+
+	var samples []complex128
+	switch config.Modulation {
+	case ModulationGMSK:
+		samples = gmskModulate(points, sps, config.BT)
+	case ModulationOQPSK:
+		taps := RootRaisedCosine(config.RollOff, sps, config.FilterSpan)
+		scaleTaps(taps, math.Sqrt(float64(sps)))
+		inPhase := make([]complex128, symbols)
+		quadrature := make([]complex128, symbols)
+		for k, p := range points {
+			inPhase[k], quadrature[k] = complex(real(p), 0), complex(imag(p), 0)
+		}
+		i := pulseShape(inPhase, taps, sps, n, 0)
+		q := pulseShape(quadrature, taps, sps, n, sps/2)
+		samples = make([]complex128, n)
+		for k := range samples {
+			samples[k] = complex(real(i[k]), real(q[k]))
+		}
+	default:
+		taps := RootRaisedCosine(config.RollOff, sps, config.FilterSpan)
+		scaleTaps(taps, math.Sqrt(float64(sps)))
+		samples = pulseShape(points, taps, sps, n, 0)
+	}
+
+	// Carrier offset, Doppler ramp and phase noise, drawn before the AWGN so they do not
+	// depend on it.
+	phaseNoiseStd := math.Sqrt(2 * math.Pi * config.PhaseNoiseLinewidth / sampleRate)
+	var phaseNoise float64
+	for k := range samples {
+		t := float64(k) / sampleRate
+		if phaseNoiseStd > 0 && k > 0 {
+			phaseNoise += rng.NormFloat64() * phaseNoiseStd
+		}
+		phase := 2*math.Pi*(config.CarrierOffset*t+0.5*config.DopplerRate*t*t) + config.Phase + phaseNoise
+		samples[k] *= cmplx.Rect(1, phase)
+	}
+
+	// Unit mean power spread over sps samples gives Es = sps, so N0 = sps / (Es/N0).
+	var noiseVariance float64
+	if !config.Noiseless {
+		noiseVariance = float64(sps) / math.Pow(10, config.EsN0dB/10)
+		sigma := math.Sqrt(noiseVariance / 2)
+		for k := range samples {
+			samples[k] += complex(rng.NormFloat64()*sigma, rng.NormFloat64()*sigma)
+		}
+	}
+
+	return &BasebandSignal{
+		Config:        config,
+		SampleRate:    sampleRate,
+		Samples:       samples,
+		Symbols:       points,
+		Bits:          bits,
+		NoiseVariance: noiseVariance,
+	}, nil
+}
+
+// CarrierSignal returns the metadata of the generated signal on the given channel, with unit
+// Amplitude and NoiseLevel set to the AWGN power per sample in dB (-Inf when noiseless).
+func (b *BasebandSignal) CarrierSignal(channel CarrierChannel) CarrierSignal {
+	return CarrierSignal{
+		Frequency:  channel.NominalFrequency + b.Config.CarrierOffset,
+		Phase:      b.Config.Phase,
+		Amplitude:  1,
+		NoiseLevel: 10 * math.Log10(b.NoiseVariance),
+		SignalID:   randomString(10),
+		Channel:    channel.Name,
+		Timestamp:  time.Now(),
+	}
+}
+
+// RootRaisedCosine returns span*samplesPerSymbol+1 root-raised-cosine taps with unit energy.
+// Filtering with the same taps at the receiver gives a raised-cosine response with no
+// intersymbol interference at the symbol centres.
+func RootRaisedCosine(rollOff float64, samplesPerSymbol, span int) []float64 {
+	length := span*samplesPerSymbol + 1
+	taps := make([]float64, length)
+	centre := length / 2
+	var energy float64
+	for i := range taps {
+		t := float64(i-centre) / float64(samplesPerSymbol) // in symbols
+		switch {
+		case t == 0:
+			taps[i] = 1 - rollOff + 4*rollOff/math.Pi
+		case math.Abs(math.Abs(4*rollOff*t)-1) < 1e-9:
+			taps[i] = rollOff / math.Sqrt2 * ((1+2/math.Pi)*math.Sin(math.Pi/(4*rollOff)) +
+				(1-2/math.Pi)*math.Cos(math.Pi/(4*rollOff)))
+		default:
+			taps[i] = (math.Sin(math.Pi*t*(1-rollOff)) + 4*rollOff*t*math.Cos(math.Pi*t*(1+rollOff))) /
+				(math.Pi * t * (1 - 16*rollOff*rollOff*t*t))
+		}
+		energy += taps[i] * taps[i]
+	}
+	scaleTaps(taps, 1/math.Sqrt(energy))
+	return taps
+}
+
+// scaleTaps multiplies every tap by gain.
+func scaleTaps(taps []float64, gain float64) {
+	for i := range taps {
+		taps[i] *= gain
+	}
+}
+
+// pulseShape places the centre tap of a pulse weighted by each symbol at sample
+// k*samplesPerSymbol+delay and returns the first n samples of the sum.
+func pulseShape(symbols []complex128, taps []float64, samplesPerSymbol, n, delay int) []complex128 {
+	out := make([]complex128, n)
+	centre := len(taps) / 2
+	for k, a := range symbols {
+		start := k*samplesPerSymbol + delay - centre
+		for j, h := range taps {
+			if idx := start + j; idx >= 0 && idx < n {
+				out[idx] += a * complex(h, 0)
+			}
+		}
+	}
+	return out
+}
+
+// gmskModulate integrates Gaussian-filtered NRZ symbols into a continuous phase with
+// modulation index 0.5, so each symbol advances the phase by ±π/2. Symbol k's frequency pulse
+// is centred on sample k*samplesPerSymbol.
+func gmskModulate(symbols []complex128, samplesPerSymbol int, bt float64) []complex128 {
+	n := len(symbols) * samplesPerSymbol
+	nrzWave := make([]complex128, n)
+	for i := range nrzWave {
+		k := (i + samplesPerSymbol/2) / samplesPerSymbol
+		if k < len(symbols) {
+			nrzWave[i] = symbols[k]
+		}
+	}
+	frequency := pulseShape(nrzWave, gaussianTaps(bt, samplesPerSymbol), 1, n, 0)
+	samples := make([]complex128, n)
+	var phase float64
+	for i, f := range frequency {
+		phase += math.Pi / 2 * real(f) / float64(samplesPerSymbol)
+		samples[i] = cmplx.Rect(1, phase)
+	}
+	return samples
+}
+
+// gaussianTaps returns a Gaussian pulse four symbols long with the given bandwidth-time
+// product, normalised to unit DC gain.
+func gaussianTaps(bt float64, samplesPerSymbol int) []float64 {
+	sigma := math.Sqrt(math.Ln2) / (2 * math.Pi * bt) // in symbols
+	taps := make([]float64, 4*samplesPerSymbol+1)
+	centre := len(taps) / 2
+	var sum float64
+	for i := range taps {
+		t := float64(i-centre) / float64(samplesPerSymbol)
+		taps[i] = math.Exp(-t * t / (2 * sigma * sigma))
+		sum += taps[i]
+	}
+	scaleTaps(taps, 1/sum)
+	return taps
+}
@@ -0,0 +1,207 @@
+package communication
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// basebandHash hashes the samples rounded to 1e-6, so golden values survive last-bit
+// differences in floating-point results between platforms.
+func basebandHash(samples []complex128) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, x := range samples {
+		for _, v := range []float64{real(x), imag(x)} {
+			binary.LittleEndian.PutUint64(buf[:], uint64(int64(math.Round(v*1e6))))
+			h.Write(buf[:])
+		}
+	}
+	return h.Sum64()
+}
+
+// matchedSymbols matched-filters a pulse-shaped PSK signal and samples it at the symbol
+// centres, scaled back to the unit-energy constellation.
+func matchedSymbols(signal *BasebandSignal) ([]complex128, error) {
+	config := signal.Config
+	if config.Modulation == ModulationGMSK {
+		return nil, errors.New("GMSK is not pulse shaped with root-raised-cosine taps")
+	}
+	sps := config.SamplesPerSymbol
+	taps := RootRaisedCosine(config.RollOff, sps, config.FilterSpan)
+	delay := len(taps) / 2
+	filtered := NewFIRFilter(taps).Process(append(append([]complex128(nil), signal.Samples...), make([]complex128, delay+sps)...))
+	scale := 1 / math.Sqrt(float64(sps))
+	symbols := make([]complex128, len(signal.Symbols))
+	for k := range symbols {
+		y := filtered[k*sps+delay]
+		if config.Modulation == ModulationOQPSK {
+			y = complex(real(y), imag(filtered[k*sps+sps/2+delay]))
+		}
+		symbols[k] = y * complex(scale, 0)
+	}
+	return symbols, nil
+}
+
+// TestBasebandGenerator checks each modulation for determinism against golden hashes, unit
+// power, the AWGN level, and matched-filter or differential detection.
+func TestBasebandGenerator(t *testing.T) {
+	const (
+		symbolRate = 10e3
+		sps        = 8
+		symbols    = 4096
+		esn0DB     = 10.0
+	)
+	golden := map[Modulation]uint64{
+		ModulationBPSK:  0xf2721fe53a2097f4,
+		ModulationQPSK:  0xbc12204c216c722b,
+		ModulationOQPSK: 0x67ab7985cfdbf23f,
+		Modulation8PSK:  0x9633a189e9c874e3,
+		ModulationGMSK:  0x3e9dd1253c756cfe,
+	}
+
+	for _, m := range []Modulation{ModulationBPSK, ModulationQPSK, ModulationOQPSK, Modulation8PSK, ModulationGMSK} {
+		config := BasebandConfig{Modulation: m, SymbolRate: symbolRate, SamplesPerSymbol: sps, Noiseless: true, Seed: 41}
+		clean, err := GenerateBaseband(config, symbols)
+		if err != nil {
+			t.Errorf("%v: %v", m, err)
+			continue
+		}
+		again, _ := GenerateBaseband(config, symbols)
+		config.Seed++
+		other, _ := GenerateBaseband(config, symbols)
+		hash := basebandHash(clean.Samples)
+		if hash != basebandHash(again.Samples) {
+			t.Errorf("%v: same seed gave different samples", m)
+		}
+		if hash == basebandHash(other.Samples) {
+			t.Errorf("%v: different seeds gave the same samples", m)
+		}
+		if hash != golden[m] {
+			t.Errorf("%v: hash %#016x, golden %#016x", m, hash, golden[m])
+		}
+
+		// The AWGN is the difference from the noiseless signal with the same seed.
+		config.Seed, config.Noiseless, config.EsN0dB = 41, false, esn0DB
+		noisy, _ := GenerateBaseband(config, symbols)
+		var power, noise float64
+		for k, x := range clean.Samples {
+			d := noisy.Samples[k] - x
+			power += real(x)*real(x) + imag(x)*imag(x)
+			noise += real(d)*real(d) + imag(d)*imag(d)
+		}
+		power /= float64(len(clean.Samples))
+		noise /= float64(len(clean.Samples))
+		measured := 10 * math.Log10(power*sps/noise)
+		if math.Abs(power-1) > 0.05 || math.Abs(measured-esn0DB) > 0.1 {
+			t.Errorf("%v: mean power %.3f, Es/N0 %.2f dB (target %.1f dB)", m, power, measured, esn0DB)
+		}
+
+		// Detection: a matched filter for the PSK family, differential phase for GMSK.
+		if m == ModulationGMSK {
+			var envelope float64
+			var errorCount int
+			for k, x := range clean.Samples {
+				envelope = math.Max(envelope, math.Abs(cmplx.Abs(x)-1))
+				if k%sps == sps/2 && k >= sps {
+					step := imag(x * cmplx.Conj(clean.Samples[k-sps]))
+					if (step > 0) != (real(clean.Symbols[k/sps]) > 0) {
+						errorCount++
+					}
+				}
+			}
+			if errorCount > 0 {
+				t.Errorf("%v: %d noiseless differential detection errors", m, errorCount)
+			}
+			if envelope > 1e-9 {
+				t.Errorf("%v: envelope deviation %.1e, want a constant envelope", m, envelope)
+			}
+			continue
+		}
+		cleanSymbols, err := matchedSymbols(clean)
+		if err != nil {
+			t.Fatalf("%v: %v", m, err)
+		}
+		noisySymbols, _ := matchedSymbols(noisy)
+		var errorCount int
+		for k, bit := range m.Demap(cleanSymbols) {
+			if bit != clean.Bits[k] {
+				errorCount++
+			}
+		}
+		if errorCount > 0 {
+			t.Errorf("%v: %d noiseless matched-filter detection errors", m, errorCount)
+		}
+		var evm float64
+		for k, y := range noisySymbols {
+			d := y - clean.Symbols[k]
+			evm += real(d)*real(d) + imag(d)*imag(d)
+		}
+		if snr := -10 * math.Log10(evm/float64(len(noisySymbols))); math.Abs(snr-esn0DB) > 0.3 {
+			t.Errorf("%v: matched-filter SNR %.2f dB, want %.1f dB", m, snr, esn0DB)
+		}
+	}
+}
+
+// TestBasebandDopplerRamp squares BPSK, which leaves a tone at twice the carrier, and checks
+// that the tone follows the Doppler ramp.
+func TestBasebandDopplerRamp(t *testing.T) {
+	const offset, rate, block = 2000.0, 500.0, 8192
+	ramp, err := GenerateBaseband(BasebandConfig{
+		Modulation: ModulationBPSK, SymbolRate: 10e3, SamplesPerSymbol: 8,
+		CarrierOffset: offset, DopplerRate: rate, Noiseless: true, Seed: 41,
+	}, 20000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	estimator, _ := NewFrequencyEstimator(FrequencyEstimatorConfig{SampleRate: ramp.SampleRate, Window: WindowHann, ZeroPadFactor: 4})
+	for _, start := range []int{0, len(ramp.Samples) - block} {
+		squared := make([]complex128, block)
+		for i := range squared {
+			squared[i] = ramp.Samples[start+i] * ramp.Samples[start+i]
+		}
+		estimate, err := estimator.Estimate(squared)
+		if err != nil {
+			t.Fatal(err)
+		}
+		at := (float64(start) + float64(block-1)/2) / ramp.SampleRate
+		if want := offset + rate*at; math.Abs(estimate.Offset/2-want) > 1 {
+			t.Errorf("Doppler ramp at %.2f s: %.2f Hz, expected %.2f Hz", at, estimate.Offset/2, want)
+		}
+	}
+}
+
+// TestBasebandPhaseNoise measures the phase noise as the phase of the impaired signal against
+// an unimpaired copy; its increments over a lag of L samples have variance 2π·linewidth·L/fs.
+func TestBasebandPhaseNoise(t *testing.T) {
+	const linewidth, lag = 50.0, 80
+	config := BasebandConfig{Modulation: ModulationBPSK, SymbolRate: 10e3, SamplesPerSymbol: 8, Noiseless: true, Seed: 41}
+	reference, err := GenerateBaseband(config, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.PhaseNoiseLinewidth = linewidth
+	jittered, err := GenerateBaseband(config, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum float64
+	var count int
+	for k := 0; k+lag < len(reference.Samples); k++ {
+		a, b := reference.Samples[k], reference.Samples[k+lag]
+		if cmplx.Abs(a) < 0.3 || cmplx.Abs(b) < 0.3 {
+			continue
+		}
+		d := cmplx.Phase(jittered.Samples[k+lag] * cmplx.Conj(b) * cmplx.Conj(jittered.Samples[k]*cmplx.Conj(a)))
+		sum += d * d
+		count++
+	}
+	measured := sum / float64(count)
+	want := 2 * math.Pi * linewidth * lag / reference.SampleRate
+	if math.Abs(measured/want-1) > 0.2 {
+		t.Errorf("phase noise %.0f Hz linewidth: increment variance %.4f rad², expected %.4f rad²", linewidth, measured, want)
+	}
+}
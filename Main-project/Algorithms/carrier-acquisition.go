@@ -0,0 +1,494 @@
+package communication
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sort"
+	"time"
+)
+
+// AcquisitionState is the state of an Acquirer.
+type AcquisitionState int
+
+const (
+	AcquisitionSearching AcquisitionState = iota // Coarse search for the carrier
+	AcquisitionPullIn                            // FLL, then the tracking loop, converging on the detected carrier
+	AcquisitionLocked                            // Tracking loop locked
+	AcquisitionLost                              // Lock lost; the tracking loop may relock before ReacquireTimeout
+)
+
+// String returns the state name.
+func (s AcquisitionState) String() string {
+	switch s {
+	case AcquisitionSearching:
+		return "searching"
+	case AcquisitionPullIn:
+		return "pull-in"
+	case AcquisitionLocked:
+		return "locked"
+	case AcquisitionLost:
+		return "lost"
+	default:
+		return fmt.Sprintf("AcquisitionState(%d)", int(s))
+	}
+}
+
+// AcquisitionMethod selects the coarse frequency search.
+type AcquisitionMethod int
+
+const (
+	AcquireFFT   AcquisitionMethod = iota // One FFT over FFTSize samples
+	AcquireSweep                          // Stepped sweep, one dwell per frequency step
+)
+
+// String returns the method name.
+func (m AcquisitionMethod) String() string {
+	switch m {
+	case AcquireFFT:
+		return "FFT"
+	case AcquireSweep:
+		return "sweep"
+	default:
+		return fmt.Sprintf("AcquisitionMethod(%d)", int(m))
+	}
+}
+
+// AcquisitionConfig configures carrier acquisition ahead of a CarrierLoop.
+type AcquisitionConfig struct {
+	Loop               LoopConfig // Fine tracking loop; its Type and SampleRate also apply to acquisition
+	Method             AcquisitionMethod
+	SearchRange        float64       // Largest offset searched, ± Hz; 0 means the widest the loop type allows
+	FFTSize            int           // Samples per FFT search, a power of two; 0 means 4096
+	SweepStep          float64       // Sweep frequency step, in Hz; 0 means 500
+	DetectionThreshold float64       // Peak-to-median power ratio that declares a detection; 0 means 25
+	FLLBandwidth       float64       // FLL noise bandwidth, in Hz; 0 means Loop.LoopBandwidth/4
+	HandoffThreshold   float64       // Smoothed FLL error below which the tracking loop takes over, in Hz; 0 means Loop.LoopBandwidth/2
+	PullInTimeout      time.Duration // Longest pull-in before searching again; 0 means 1 s
+	ReacquireTimeout   time.Duration // Longest the loop may stay unlocked before searching again; 0 means 50 ms
+}
+
+// AcquisitionTransition records one state change.
+type AcquisitionTransition struct {
+	From, To  AcquisitionState
+	Sample    int           // Index of the sample at which the transition happened
+	Dwell     time.Duration // Time spent in From
+	Frequency float64       // Carrier offset estimate at the transition, in Hz
+}
+
+// TransitionStats summarises the dwell times of every transition between two states.
+type TransitionStats struct {
+	From, To       AcquisitionState
+	Count          int
+	Mean, Min, Max time.Duration
+}
+
+// Acquirer finds a carrier anywhere in the search range with an FFT or sweep, pulls in with
+// a frequency-locked loop and hands off to a CarrierLoop for fine tracking.
+type Acquirer struct {
+	config  AcquisitionConfig
+	order   int // M-th power that strips the modulation: 1, 2 or 4
+	state   AcquisitionState
+	sample  int
+	entered int // Sample at which the current state was entered
+
+	search     []complex128 // Samples gathered for the current search
+	searchSize int
+	dwell      int // Sweep samples per frequency step
+
+	fllBlock  int     // Samples integrated per FLL discriminator update
+	fllGain   float64 // Frequency update gain per block
+	fllSettle int     // Blocks before a handoff is considered
+	fllPhase  float64 // NCO phase, in rad
+	fllFreq   float64 // NCO frequency, in rad/sample
+	fllSum    complex128
+	fllPrev   complex128
+	fllCount  int
+	fllBlocks int
+	fllError  float64 // Smoothed discriminator output, in rad/sample
+
+	loop        *CarrierLoop // Fine tracking loop, nil until handoff
+	transitions []AcquisitionTransition
+}
+
+// NewAcquirer validates the configuration and fills in defaults.
+func NewAcquirer(config AcquisitionConfig) (*Acquirer, error) {
+	if _, err := NewCarrierLoop(config.Loop); err != nil {
+		return nil, err
+	}
+	fs := config.Loop.SampleRate
+	order := config.Loop.Type.modulationOrder()
+	maxRange := fs / (2 * float64(order))
+	switch {
+	case config.SearchRange == 0:
+		config.SearchRange = maxRange
+	case config.SearchRange < 0 || config.SearchRange > maxRange:
+		return nil, fmt.Errorf("search range %v Hz must be in (0, %v] Hz for %v", config.SearchRange, maxRange, config.Loop.Type)
+	}
+	if config.FFTSize == 0 {
+		config.FFTSize = 4096
+	}
+	if config.FFTSize < 16 || config.FFTSize&(config.FFTSize-1) != 0 {
+		return nil, fmt.Errorf("FFT size %d is not a power of two of at least 16", config.FFTSize)
+	}
+	if config.SweepStep == 0 {
+		config.SweepStep = 500
+	}
+	if config.SweepStep < 0 || config.SweepStep > config.SearchRange {
+		return nil, fmt.Errorf("sweep step %v Hz must be in (0, %v] Hz", config.SweepStep, config.SearchRange)
+	}
+	if config.DetectionThreshold <= 0 {
+		config.DetectionThreshold = 25
+	}
+	if config.FLLBandwidth <= 0 {
+		config.FLLBandwidth = config.Loop.LoopBandwidth / 4
+	}
+	if config.HandoffThreshold <= 0 {
+		config.HandoffThreshold = config.Loop.LoopBandwidth / 2
+	}
+	if config.PullInTimeout <= 0 {
+		config.PullInTimeout = time.Second
+	}
+	if config.ReacquireTimeout <= 0 {
+		config.ReacquireTimeout = 50 * time.Millisecond
+	}
+
+	a := &Acquirer{config: config, order: order}
+	m := float64(order)
+	// The FLL integrates over fllBlock samples, which gives it a pull-in range of four
+	// times the residual error the coarse search leaves.
+	var residual float64
+	if config.Method == AcquireSweep {
+		a.dwell = int(math.Ceil(fs / (m * config.SweepStep)))
+		a.searchSize = a.dwell * a.sweepSteps()
+		residual = config.SweepStep / 2
+	} else {
+		a.searchSize = config.FFTSize
+		residual = fs / (m * float64(config.FFTSize))
+	}
+	a.fllBlock = int(math.Max(1, math.Floor(fs/(8*m*residual))))
+	a.fllGain = math.Min(1, 4*config.FLLBandwidth*float64(a.fllBlock)/fs)
+	a.fllSettle = int(math.Ceil(4/a.fllGain)) + 1
+	return a, nil
+}
+
+// lockLossFactor is how far above the lock threshold the RMS phase error must rise before
+// lock is declared lost, so noise near the threshold does not toggle the state.
+const lockLossFactor = 1.5
+
+// modulationOrder is the power that removes the data modulation the loop type tracks.
+func (t LoopType) modulationOrder() int {
+	switch t {
+	case LoopCostasBPSK:
+		return 2
+	case LoopCostasQPSK:
+		return 4
+	default:
+		return 1
+	}
+}
+
+// sweepSteps is the number of frequencies the sweep visits.
+func (a *Acquirer) sweepSteps() int {
+	return 2*int(math.Floor(a.config.SearchRange/a.config.SweepStep)) + 1
+}
+
+// Process runs acquisition and tracking over a block of samples. It returns the samples
+// derotated by whichever NCO is active and the transitions that happened in the block.
+func (a *Acquirer) Process(samples []complex128) ([]complex128, []AcquisitionTransition) {
+	out := make([]complex128, len(samples))
+	first := len(a.transitions)
+	for n, x := range samples {
+		out[n] = a.Step(x)
+	}
+	return out, append([]AcquisitionTransition(nil), a.transitions[first:]...)
+}
+
+// Step processes one sample and returns it derotated by the active NCO; samples are
+// passed through unchanged while searching.
+func (a *Acquirer) Step(x complex128) complex128 {
+	a.sample++
+	switch a.state {
+	case AcquisitionSearching:
+		a.search = append(a.search, x)
+		if len(a.search) == a.searchSize {
+			offset, detected := a.coarseSearch()
+			a.search = a.search[:0]
+			if detected {
+				a.startFLL(offset)
+				a.transition(AcquisitionPullIn)
+			}
+		}
+		return x
+
+	case AcquisitionPullIn:
+		if a.elapsed() > a.config.PullInTimeout {
+			a.loop = nil
+			a.transition(AcquisitionSearching)
+			return x
+		}
+		if a.loop == nil {
+			y := a.stepFLL(x)
+			if a.fllBlocks >= a.fllSettle && math.Abs(a.hertz(a.fllError)) < a.config.HandoffThreshold {
+				config := a.config.Loop
+				config.InitialFrequency = a.hertz(a.fllFreq)
+				a.loop, _ = NewCarrierLoop(config)
+			}
+			return y
+		}
+		y, _ := a.loop.Step(x)
+		if a.loop.Status().Locked {
+			a.transition(AcquisitionLocked)
+		}
+		return y
+
+	case AcquisitionLocked:
+		y, _ := a.loop.Step(x)
+		if a.loop.Status().RMSError > lockLossFactor*a.loop.config.LockThreshold {
+			a.transition(AcquisitionLost)
+		}
+		return y
+
+	default: // AcquisitionLost
+		y, _ := a.loop.Step(x)
+		switch {
+		case a.loop.Status().Locked:
+			a.transition(AcquisitionLocked)
+		case a.elapsed() > a.config.ReacquireTimeout:
+			a.transition(AcquisitionSearching)
+			a.loop = nil
+		}
+		return y
+	}
+}
+
+// coarseSearch looks for the carrier in the gathered samples and returns its offset in Hz.
+func (a *Acquirer) coarseSearch() (float64, bool) {
+	fs := a.config.Loop.SampleRate
+	m := float64(a.order)
+	stripped := make([]complex128, len(a.search))
+	for i, x := range a.search {
+		stripped[i] = a.stripModulation(x)
+	}
+
+	var power []float64
+	var frequencyOf func(peak int) float64
+	if a.config.Method == AcquireSweep {
+		steps := a.sweepSteps()
+		half := steps / 2
+		power = make([]float64, steps)
+		for k := range power {
+			f := m * a.config.SweepStep * float64(k-half)
+			block := stripped[k*a.dwell : (k+1)*a.dwell]
+			rotate := cmplx.Rect(1, -2*math.Pi*f/fs)
+			phasor := complex(1, 0)
+			var sum complex128
+			for _, x := range block {
+				sum += x * phasor
+				phasor *= rotate
+			}
+			power[k] = real(sum)*real(sum) + imag(sum)*imag(sum)
+		}
+		frequencyOf = func(peak int) float64 { return a.config.SweepStep * float64(peak-half) }
+	} else {
+		spectrum, _ := FFT(stripped)
+		size := len(spectrum)
+		// Keep the bins within ±M·SearchRange, ordered from most negative to most positive.
+		half := int(math.Min(float64(size/2-1), math.Floor(m*a.config.SearchRange*float64(size)/fs)))
+		power = make([]float64, 2*half+1)
+		for k := range power {
+			x := spectrum[(k-half+size)%size]
+			power[k] = real(x)*real(x) + imag(x)*imag(x)
+		}
+		frequencyOf = func(peak int) float64 {
+			delta := 0.0
+			if peak > 0 && peak < len(power)-1 {
+				delta = parabolicDelta(power, peak)
+			}
+			return (float64(peak-half) + delta) * fs / (m * float64(size))
+		}
+	}
+
+	peak := 0
+	for k, p := range power {
+		if p > power[peak] {
+			peak = k
+		}
+	}
+	sorted := append([]float64(nil), power...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	if median == 0 || power[peak]/median < a.config.DetectionThreshold {
+		return 0, false
+	}
+	return frequencyOf(peak), true
+}
+
+// stripModulation raises x to the modulation order so the carrier appears as a line at
+// M times its frequency.
+func (a *Acquirer) stripModulation(x complex128) complex128 {
+	switch a.order {
+	case 2:
+		return x * x
+	case 4:
+		x2 := x * x
+		return x2 * x2
+	default:
+		return x
+	}
+}
+
+// startFLL resets the FLL to the coarse estimate.
+func (a *Acquirer) startFLL(offsetHz float64) {
+	a.fllFreq = 2 * math.Pi * offsetHz / a.config.Loop.SampleRate
+	a.fllPhase, a.fllSum, a.fllPrev = 0, 0, 0
+	a.fllCount, a.fllBlocks = 0, 0
+	a.fllError = 0
+}
+
+// stepFLL derotates one sample and, at the end of each integration block, updates the NCO
+// frequency from the phase change of the modulation-stripped block sums.
+func (a *Acquirer) stepFLL(x complex128) complex128 {
+	y := x * cmplx.Rect(1, -a.fllPhase)
+	a.fllPhase = math.Mod(a.fllPhase+a.fllFreq, 2*math.Pi)
+	a.fllSum += a.stripModulation(y)
+	a.fllCount++
+	if a.fllCount < a.fllBlock {
+		return y
+	}
+	if a.fllPrev != 0 {
+		e := cmplx.Phase(a.fllSum*cmplx.Conj(a.fllPrev)) / float64(a.order*a.fllBlock)
+		a.fllFreq += a.fllGain * e
+		a.fllError += 0.25 * (e - a.fllError)
+		a.fllBlocks++
+	}
+	a.fllPrev, a.fllSum, a.fllCount = a.fllSum, 0, 0
+	return y
+}
+
+// transition moves to a new state and records the time spent in the old one.
+func (a *Acquirer) transition(to AcquisitionState) {
+	a.transitions = append(a.transitions, AcquisitionTransition{
+		From:      a.state,
+		To:        to,
+		Sample:    a.sample,
+		Dwell:     a.elapsed(),
+		Frequency: a.Frequency(),
+	})
+	a.state, a.entered = to, a.sample
+}
+
+// elapsed is the time spent in the current state.
+func (a *Acquirer) elapsed() time.Duration {
+	return seconds(float64(a.sample-a.entered) / a.config.Loop.SampleRate)
+}
+
+// hertz converts a frequency in rad/sample to Hz.
+func (a *Acquirer) hertz(w float64) float64 {
+	return w * a.config.Loop.SampleRate / (2 * math.Pi)
+}
+
+// State returns the current acquisition state.
+func (a *Acquirer) State() AcquisitionState {
+	return a.state
+}
+
+// Frequency returns the current carrier offset estimate, in Hz: the tracking loop's once it
+// has taken over, otherwise the FLL's. It is zero while searching.
+func (a *Acquirer) Frequency() float64 {
+	switch {
+	case a.loop != nil:
+		return a.loop.FrequencyOffset()
+	case a.state == AcquisitionSearching:
+		return 0
+	default:
+		return a.hertz(a.fllFreq)
+	}
+}
+
+// Loop returns the tracking loop, or nil before handoff.
+func (a *Acquirer) Loop() *CarrierLoop {
+	return a.loop
+}
+
+// Transitions returns every transition so far.
+func (a *Acquirer) Transitions() []AcquisitionTransition {
+	return append([]AcquisitionTransition(nil), a.transitions...)
+}
+
+// Statistics returns dwell time statistics for each kind of transition, ordered by state.
+func (a *Acquirer) Statistics() []TransitionStats {
+	type edge struct{ from, to AcquisitionState }
+	byEdge := make(map[edge]*TransitionStats)
+	var stats []*TransitionStats
+	for _, t := range a.transitions {
+		s, ok := byEdge[edge{t.From, t.To}]
+		if !ok {
+			s = &TransitionStats{From: t.From, To: t.To, Min: t.Dwell, Max: t.Dwell}
+			byEdge[edge{t.From, t.To}] = s
+			stats = append(stats, s)
+		}
+		s.Count++
+		s.Mean += t.Dwell
+		if t.Dwell < s.Min {
+			s.Min = t.Dwell
+		}
+		if t.Dwell > s.Max {
+			s.Max = t.Dwell
+		}
+	}
+	result := make([]TransitionStats, len(stats))
+	for i, s := range stats {
+		s.Mean /= time.Duration(s.Count)
+		result[i] = *s
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].From != result[j].From {
+			return result[i].From < result[j].From
+		}
+		return result[i].To < result[j].To
+	})
+	return result
+}
+
+// AcquisitionResult summarises an acquisition run on one signal.
+type AcquisitionResult struct {
+	SignalID    string
+	State       AcquisitionState // State at the end of the samples
+	Frequency   float64          // Final carrier offset estimate, in Hz
+	Transitions []AcquisitionTransition
+	Statistics  []TransitionStats
+	Recovered   []complex128 // Derotated samples
+}
+
+// AcquireCarrier acquires and tracks the carrier of the signal's IQ samples. If the loop
+// ends locked, the signal's Frequency and Phase are updated from its estimate.
+func (cs *CarrierSync) AcquireCarrier(signalID string, samples []complex128, config AcquisitionConfig) (AcquisitionResult, error) {
+	acq, err := NewAcquirer(config)
+	if err != nil {
+		return AcquisitionResult{}, err
+	}
+	recovered, _ := acq.Process(samples)
+	result := AcquisitionResult{
+		SignalID:    signalID,
+		State:       acq.State(),
+		Frequency:   acq.Frequency(),
+		Transitions: acq.Transitions(),
+		Statistics:  acq.Statistics(),
+		Recovered:   recovered,
+	}
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	for i := range cs.signals {
+		if cs.signals[i].SignalID != signalID {
+			continue
+		}
+		if result.State == AcquisitionLocked {
+			cs.signals[i].Frequency = config.Loop.CenterFrequency + result.Frequency
+			cs.signals[i].Phase = acq.Loop().Status().Phase
+		}
+		return result, nil
+	}
+	return AcquisitionResult{}, fmt.Errorf("unknown signal %q", signalID)
+}
@@ -0,0 +1,75 @@
+package communication
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+// TestCarrierAcquisition acquires carriers with offsets from hundreds of Hz to tens of kHz
+// with both search methods.
+func TestCarrierAcquisition(t *testing.T) {
+	const (
+		sampleRate = 500e3
+		symbolRate = 25e3
+		snrDB      = 15.0
+		searchHz   = 60e3
+	)
+	rng := rand.New(rand.NewSource(37))
+	offsets := []float64{500, -7200, 48000}
+
+	for _, loopType := range []LoopType{LoopPLL, LoopCostasBPSK, LoopCostasQPSK} {
+		for _, method := range []AcquisitionMethod{AcquireFFT, AcquireSweep} {
+			for _, offset := range offsets {
+				acq, err := NewAcquirer(AcquisitionConfig{
+					Loop:        LoopConfig{Type: loopType, SampleRate: sampleRate, LoopBandwidth: 200},
+					Method:      method,
+					SearchRange: searchHz,
+				})
+				if err != nil {
+					t.Fatalf("%v %v: %v", loopType, method, err)
+				}
+				samples := syntheticCarrier(rng, loopType, acq.searchSize+200000, sampleRate, symbolRate, offset, rng.Float64()*2*math.Pi, snrDB)
+				acq.Process(samples)
+				if acq.State() != AcquisitionLocked || math.Abs(acq.Frequency()-offset) > 1 {
+					t.Errorf("%v %v %+.0f Hz: %v, frequency %.2f Hz", loopType, method, offset, acq.State(), acq.Frequency())
+				}
+			}
+		}
+	}
+}
+
+// TestCarrierReacquisition fades a locked carrier out and back in to exercise the lost and
+// reacquisition transitions: 200 ms of carrier, 100 ms of noise only, then the carrier again.
+func TestCarrierReacquisition(t *testing.T) {
+	const (
+		sampleRate = 500e3
+		snrDB      = 15.0
+	)
+	rng := rand.New(rand.NewSource(37))
+	acq, err := NewAcquirer(AcquisitionConfig{
+		Loop:        LoopConfig{Type: LoopPLL, SampleRate: sampleRate, LoopBandwidth: 200},
+		SearchRange: 60e3,
+	})
+	if err != nil {
+		t.Fatalf("NewAcquirer: %v", err)
+	}
+	samples := syntheticCarrier(rng, LoopPLL, 250000, sampleRate, 25e3, 3100, 0, snrDB)
+	for k := 100000; k < 150000; k++ {
+		samples[k] -= cmplx.Rect(1, 2*math.Pi*3100*float64(k)/sampleRate)
+	}
+	acq.Process(samples)
+	if acq.State() != AcquisitionLocked || math.Abs(acq.Frequency()-3100) > 1 {
+		t.Errorf("after fade: %v, frequency %.2f Hz, want locked at 3100 Hz", acq.State(), acq.Frequency())
+	}
+	lost := false
+	for _, tr := range acq.Transitions() {
+		if tr.To == AcquisitionLost {
+			lost = true
+		}
+	}
+	if !lost {
+		t.Errorf("the fade never lost lock: %v", acq.Transitions())
+	}
+}
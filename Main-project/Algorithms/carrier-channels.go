@@ -0,0 +1,193 @@
+package communication
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// CarrierChannel is one entry of a channel plan.
+type CarrierChannel struct {
+	Name             string
+	NominalFrequency float64 // Assigned carrier frequency, in Hz
+	Tolerance        float64 // Largest deviation that is still this channel's carrier and gets corrected, in Hz
+	Bandwidth        float64 // Occupied bandwidth; carriers inside it but beyond Tolerance are interference, in Hz
+}
+
+// ChannelStatus classifies a signal against the channel plan.
+type ChannelStatus int
+
+const (
+	ChannelInTolerance  ChannelStatus = iota // Within the CarrierSync tolerance of its channel
+	ChannelCorrected                         // Beyond the sync tolerance but within the channel tolerance; corrected
+	ChannelInterference                      // Inside a channel's bandwidth but too far from its carrier
+	ChannelUnassigned                        // Outside every channel
+)
+
+// String returns the status label.
+func (s ChannelStatus) String() string {
+	switch s {
+	case ChannelInTolerance:
+		return "in-tolerance"
+	case ChannelCorrected:
+		return "corrected"
+	case ChannelInterference:
+		return "interference"
+	case ChannelUnassigned:
+		return "unassigned"
+	default:
+		return fmt.Sprintf("ChannelStatus(%d)", int(s))
+	}
+}
+
+// ChannelMatch is the result of matching one signal to the channel plan.
+type ChannelMatch struct {
+	SignalID   string
+	Channel    string  // Matched channel; empty when unassigned
+	Nominal    float64 // Nominal frequency of Channel, in Hz
+	Measured   float64 // Signal frequency before correction, in Hz
+	Offset     float64 // Measured minus Nominal, in Hz
+	Correction float64 // Frequency change applied, in Hz; zero unless Status is ChannelCorrected
+	Status     ChannelStatus
+}
+
+// ChannelPlan is a set of non-overlapping carrier channels, e.g. S-, X- and Ka-band.
+type ChannelPlan struct {
+	channels []CarrierChannel // Sorted by NominalFrequency
+}
+
+// NewChannelPlan validates the channels and builds a plan.
+func NewChannelPlan(channels ...CarrierChannel) (*ChannelPlan, error) {
+	if len(channels) == 0 {
+		return nil, errors.New("channel plan needs at least one channel")
+	}
+	sorted := append([]CarrierChannel(nil), channels...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].NominalFrequency < sorted[j].NominalFrequency })
+
+	names := make(map[string]bool, len(sorted))
+	for i, ch := range sorted {
+		switch {
+		case ch.Name == "":
+			return nil, fmt.Errorf("channel at %.0f Hz has no name", ch.NominalFrequency)
+		case names[ch.Name]:
+			return nil, fmt.Errorf("duplicate channel name %q", ch.Name)
+		case ch.NominalFrequency <= 0:
+			return nil, fmt.Errorf("channel %q: nominal frequency must be positive", ch.Name)
+		case ch.Tolerance <= 0:
+			return nil, fmt.Errorf("channel %q: tolerance must be positive", ch.Name)
+		case ch.Bandwidth < 2*ch.Tolerance:
+			return nil, fmt.Errorf("channel %q: bandwidth %.0f Hz is narrower than its ±%.0f Hz tolerance", ch.Name, ch.Bandwidth, ch.Tolerance)
+		}
+		names[ch.Name] = true
+		if i > 0 {
+			prev := sorted[i-1]
+			if prev.NominalFrequency+prev.Bandwidth/2 > ch.NominalFrequency-ch.Bandwidth/2 {
+				return nil, fmt.Errorf("channels %q and %q overlap", prev.Name, ch.Name)
+			}
+		}
+	}
+	return &ChannelPlan{channels: sorted}, nil
+}
+
+// DefaultChannelPlan is the single 1.8 GHz channel CarrierSync has always assumed.
+func DefaultChannelPlan() *ChannelPlan {
+	plan, _ := NewChannelPlan(CarrierChannel{Name: "L-1800", NominalFrequency: 1.8e9, Tolerance: 1e3, Bandwidth: 1e6})
+	return plan
+}
+
+// Channels returns a copy of the plan's channels in frequency order.
+func (p *ChannelPlan) Channels() []CarrierChannel {
+	return append([]CarrierChannel(nil), p.channels...)
+}
+
+// Nearest returns the channel whose nominal frequency is closest to f.
+func (p *ChannelPlan) Nearest(f float64) CarrierChannel {
+	i := sort.Search(len(p.channels), func(i int) bool { return p.channels[i].NominalFrequency >= f })
+	switch {
+	case i == 0:
+		return p.channels[0]
+	case i == len(p.channels):
+		return p.channels[i-1]
+	}
+	if f-p.channels[i-1].NominalFrequency <= p.channels[i].NominalFrequency-f {
+		return p.channels[i-1]
+	}
+	return p.channels[i]
+}
+
+// containing returns the channel whose bandwidth contains f, if any.
+func (p *ChannelPlan) containing(f float64) (CarrierChannel, bool) {
+	i := sort.Search(len(p.channels), func(i int) bool {
+		return p.channels[i].NominalFrequency+p.channels[i].Bandwidth/2 >= f
+	})
+	if i < len(p.channels) && p.channels[i].NominalFrequency-p.channels[i].Bandwidth/2 <= f {
+		return p.channels[i], true
+	}
+	return CarrierChannel{}, false
+}
+
+// Match classifies a signal against the channel whose bandwidth contains it, or the nearest
+// channel when none does. syncTolerance is the deviation below which no correction is needed.
+func (p *ChannelPlan) Match(signal CarrierSignal, syncTolerance float64) ChannelMatch {
+	ch, ok := p.containing(signal.Frequency)
+	if !ok {
+		ch = p.Nearest(signal.Frequency)
+	}
+	offset := signal.Frequency - ch.NominalFrequency
+	match := ChannelMatch{
+		SignalID: signal.SignalID,
+		Channel:  ch.Name,
+		Nominal:  ch.NominalFrequency,
+		Measured: signal.Frequency,
+		Offset:   offset,
+	}
+	switch deviation := math.Abs(offset); {
+	case deviation <= syncTolerance && deviation <= ch.Tolerance:
+		match.Status = ChannelInTolerance
+	case deviation <= ch.Tolerance:
+		match.Status = ChannelCorrected
+		match.Correction = -offset
+	case deviation <= ch.Bandwidth/2:
+		match.Status = ChannelInterference
+	default:
+		match.Status = ChannelUnassigned
+		match.Channel, match.Nominal, match.Offset = "", 0, 0
+	}
+	return match
+}
+
+// SetChannelPlan replaces the channel plan used by CorrectFrequency.
+func (cs *CarrierSync) SetChannelPlan(plan *ChannelPlan) error {
+	if plan == nil {
+		return errors.New("channel plan must not be nil")
+	}
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	cs.plan = plan
+	return nil
+}
+
+// SimulateChannelSignal generates a simulated carrier on the given channel, deviating from
+// its nominal frequency by up to half its tolerance.
+func SimulateChannelSignal(channel CarrierChannel) CarrierSignal {
+	return CarrierSignal{
+		Frequency:  channel.NominalFrequency + randomFloat(-channel.Tolerance/2, channel.Tolerance/2),
+		Phase:      randomFloat(0, 2*math.Pi),
+		Amplitude:  randomFloat(0.8, 1.2),
+		NoiseLevel: randomFloat(-40, -20),
+		SignalID:   randomString(10),
+		Channel:    channel.Name,
+		Timestamp:  time.Now(),
+	}
+}
+
+// simulatePlanSignal generates a simulated carrier on a random channel of the plan.
+func (cs *CarrierSync) simulatePlanSignal() CarrierSignal {
+	cs.mutex.Lock()
+	channels := cs.plan.channels
+	cs.mutex.Unlock()
+	return SimulateChannelSignal(channels[rand.Intn(len(channels))])
+}
@@ -0,0 +1,41 @@
+package communication
+
+import "testing"
+
+// TestChannelMatch checks that a signal inside a wide channel's bandwidth is matched to it
+// even when a narrow neighbour's nominal frequency is closer.
+func TestChannelMatch(t *testing.T) {
+	plan, err := NewChannelPlan(
+		CarrierChannel{Name: "narrow", NominalFrequency: 1000e6, Tolerance: 1e3, Bandwidth: 10e3},
+		CarrierChannel{Name: "wide", NominalFrequency: 1001e6, Tolerance: 10e3, Bandwidth: 1.9e6},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		frequency float64
+		channel   string
+		status    ChannelStatus
+	}{
+		{1000e6 + 500, "narrow", ChannelInTolerance},
+		{1000e6 + 3e3, "narrow", ChannelInterference},
+		{1000.06e6, "wide", ChannelInterference}, // 60 kHz from narrow, 940 kHz from wide
+		{1001e6 + 5e3, "wide", ChannelCorrected},
+		{1000.03e6, "", ChannelUnassigned}, // Between the two bandwidths
+		{1003e6, "", ChannelUnassigned},
+	}
+	for _, c := range cases {
+		match := plan.Match(CarrierSignal{SignalID: "s", Frequency: c.frequency}, 1e3)
+		if match.Channel != c.channel || match.Status != c.status {
+			t.Errorf("%.0f Hz: matched %q as %v, want %q as %v", c.frequency, match.Channel, match.Status, c.channel, c.status)
+		}
+	}
+
+	cs := NewCarrierSync(50)
+	if err := cs.SetChannelPlan(nil); err == nil {
+		t.Errorf("SetChannelPlan(nil) returned no error")
+	}
+	if err := cs.SetChannelPlan(plan); err != nil {
+		t.Errorf("SetChannelPlan: %v", err)
+	}
+}
@@ -0,0 +1,499 @@
+package communication
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"time"
+)
+
+// SyncEventKind classifies a CarrierSync event.
+type SyncEventKind int
+
+const (
+	EventLock SyncEventKind = iota
+	EventUnlock
+	EventCycleSlip
+	EventLowSNR
+	EventInterference
+	EventUnassigned
+)
+
+// String returns the event kind.
+func (k SyncEventKind) String() string {
+	switch k {
+	case EventLock:
+		return "lock"
+	case EventUnlock:
+		return "unlock"
+	case EventCycleSlip:
+		return "cycle slip"
+	case EventLowSNR:
+		return "low SNR"
+	case EventInterference:
+		return "interference"
+	case EventUnassigned:
+		return "unassigned"
+	default:
+		return fmt.Sprintf("SyncEventKind(%d)", int(k))
+	}
+}
+
+// SyncEvent is one entry of the CarrierSync event log.
+type SyncEvent struct {
+	Kind      SyncEventKind
+	SignalID  string
+	Channel   string // Channel the signal was matched to, if known
+	Source    string // Lock detector or loop that raised a lock, unlock or cycle slip
+	Sample    int    // Index of the sample that raised a lock, unlock or cycle slip
+	Value     float64
+	Cycles    int // Cycle slip size in units of 2π/M, where M is the loop's modulation order
+	Timestamp time.Time
+}
+
+// String formats the event as a log line. Value is the detector metric for lock events, the
+// SNR in dB for low SNR and the offset from nominal in Hz for interference.
+func (e SyncEvent) String() string {
+	switch e.Kind {
+	case EventLock:
+		return fmt.Sprintf("Lock on signal %s by %s detector (metric %.3f) at %v", e.SignalID, e.Source, e.Value, e.Timestamp)
+	case EventUnlock:
+		return fmt.Sprintf("Loss of lock on signal %s by %s detector (metric %.3f) at %v", e.SignalID, e.Source, e.Value, e.Timestamp)
+	case EventCycleSlip:
+		return fmt.Sprintf("Cycle slip of %+d on signal %s by %s at %v", e.Cycles, e.SignalID, e.Source, e.Timestamp)
+	case EventLowSNR:
+		return fmt.Sprintf("Low SNR (%.2f dB) for signal %s at %v", e.Value, e.SignalID, e.Timestamp)
+	case EventInterference:
+		return fmt.Sprintf("Interference on channel %s from signal %s (%.2f Hz off nominal) at %v", e.Channel, e.SignalID, e.Value, e.Timestamp)
+	case EventUnassigned:
+		return fmt.Sprintf("Unassigned signal %s at %v", e.SignalID, e.Timestamp)
+	default:
+		return fmt.Sprintf("%v on signal %s at %v", e.Kind, e.SignalID, e.Timestamp)
+	}
+}
+
+// LockDetectorKind selects a lock detector.
+type LockDetectorKind int
+
+const (
+	// LockIQRatio is the generalised I/Q power ratio Re⟨y^M⟩/⟨|y|^M⟩ of the derotated samples,
+	// aligned so a locked noiseless signal gives 1. For BPSK it is (I²−Q²)/(I²+Q²). In noise
+	// it falls to S/(S+N) for a PLL and BPSK and to about 0.7 for QPSK at 10 dB.
+	LockIQRatio LockDetectorKind = iota
+	// LockPhaseVariance is the smoothed variance of the loop's phase detector output, in rad².
+	LockPhaseVariance
+)
+
+// String returns the detector name.
+func (k LockDetectorKind) String() string {
+	switch k {
+	case LockIQRatio:
+		return "I/Q ratio"
+	case LockPhaseVariance:
+		return "phase variance"
+	default:
+		return fmt.Sprintf("LockDetectorKind(%d)", int(k))
+	}
+}
+
+// LockDetectorConfig configures one lock detector. Lock is declared when the metric passes
+// LockThreshold and lost only when it passes back beyond UnlockThreshold.
+type LockDetectorConfig struct {
+	Kind            LockDetectorKind
+	Averaging       int     // Time constant of the metric's moving average, in samples; 0 means 1000
+	LockThreshold   float64 // 0 means 0.5 for LockIQRatio and 0.1 rad² for LockPhaseVariance
+	UnlockThreshold float64 // 0 means 0.3 for LockIQRatio and 0.2 rad² for LockPhaseVariance
+}
+
+// LockDetector applies one lock test with hysteresis to a carrier loop's output.
+type LockDetector struct {
+	config   LockDetectorConfig
+	order    int
+	align    complex128 // Rotates y^M of a locked constellation onto the positive real axis
+	alpha    float64
+	power    complex128 // Smoothed aligned y^M
+	envelope float64    // Smoothed |y|^M
+	variance float64
+	locked   bool
+}
+
+// NewLockDetector validates the configuration for a loop of the given type and fills in
+// defaults.
+func NewLockDetector(config LockDetectorConfig, loopType LoopType) (*LockDetector, error) {
+	if config.Averaging == 0 {
+		config.Averaging = 1000
+	}
+	if config.Averaging < 1 {
+		return nil, fmt.Errorf("averaging must be at least one sample, got %d", config.Averaging)
+	}
+	switch config.Kind {
+	case LockIQRatio:
+		if config.LockThreshold == 0 {
+			config.LockThreshold = 0.5
+		}
+		if config.UnlockThreshold == 0 {
+			config.UnlockThreshold = 0.3
+		}
+		if config.UnlockThreshold > config.LockThreshold || config.LockThreshold > 1 {
+			return nil, fmt.Errorf("I/Q ratio thresholds need unlock %v <= lock %v <= 1", config.UnlockThreshold, config.LockThreshold)
+		}
+	case LockPhaseVariance:
+		if config.LockThreshold == 0 {
+			config.LockThreshold = 0.1
+		}
+		if config.UnlockThreshold == 0 {
+			config.UnlockThreshold = 0.2
+		}
+		if config.UnlockThreshold < config.LockThreshold || config.LockThreshold <= 0 {
+			return nil, fmt.Errorf("phase variance thresholds need 0 < lock %v <= unlock %v", config.LockThreshold, config.UnlockThreshold)
+		}
+	default:
+		return nil, fmt.Errorf("unknown lock detector %v", config.Kind)
+	}
+	order := loopType.modulationOrder()
+	align := complex(1, 0)
+	if loopType == LoopCostasQPSK {
+		align = -1 // Symbols at odd multiples of 45° give y⁴ on the negative real axis
+	}
+	return &LockDetector{
+		config:   config,
+		order:    order,
+		align:    align,
+		alpha:    1 / float64(config.Averaging),
+		variance: math.Pi * math.Pi / 3, // Uniform phase error until the loop settles
+	}, nil
+}
+
+// Update feeds one derotated sample and its phase detector output and reports whether the
+// lock state changed.
+func (d *LockDetector) Update(y complex128, phaseError float64) (changed bool) {
+	power := d.align
+	for i := 0; i < d.order; i++ {
+		power *= y
+	}
+	d.power += complex(d.alpha, 0) * (power - d.power)
+	d.envelope += d.alpha * (math.Pow(cmplx.Abs(y), float64(d.order)) - d.envelope)
+	d.variance += d.alpha * (phaseError*phaseError - d.variance)
+
+	metric := d.Metric()
+	var locked bool
+	if d.config.Kind == LockPhaseVariance {
+		locked = metric < d.config.LockThreshold || (d.locked && metric <= d.config.UnlockThreshold)
+	} else {
+		locked = metric > d.config.LockThreshold || (d.locked && metric >= d.config.UnlockThreshold)
+	}
+	changed = locked != d.locked
+	d.locked = locked
+	return changed
+}
+
+// Metric returns the detector's current metric.
+func (d *LockDetector) Metric() float64 {
+	if d.config.Kind == LockPhaseVariance {
+		return d.variance
+	}
+	if d.envelope == 0 {
+		return 0
+	}
+	return real(d.power) / d.envelope
+}
+
+// Locked reports the detector's lock state.
+func (d *LockDetector) Locked() bool {
+	return d.locked
+}
+
+// Kind returns the detector kind.
+func (d *LockDetector) Kind() LockDetectorKind {
+	return d.config.Kind
+}
+
+// LockMonitorConfig configures a LockMonitor.
+type LockMonitorConfig struct {
+	Loop          LoopConfig
+	Detectors     []LockDetectorConfig // Empty means one of each kind with defaults
+	SlipAveraging int                  // Time constant of the cycle slip phase average, in samples; 0 means 50
+	SignalID      string
+	Start         time.Time        // Time of the first sample
+	Events        chan<- SyncEvent // Optional; events that do not fit are dropped
+}
+
+// LockMonitor runs a carrier loop with lock detectors and reports lock, unlock and cycle slip
+// events. A cycle slip is counted each time the smoothed residual phase of y^M, unwrapped,
+// moves one full turn (2π/M of carrier phase) closer to another multiple of 2π while any
+// detector reports lock and the smoothed residual keeps at least half its envelope, so noise
+// alone cannot slip. The reference is reset without an event whenever that condition returns.
+type LockMonitor struct {
+	config    LockMonitorConfig
+	loop      *CarrierLoop
+	detectors []*LockDetector
+	order     int
+	align     complex128
+	slipAlpha float64
+	residual  complex128 // Smoothed aligned y^M
+	envelope  float64    // Smoothed |y|^M
+	lastAngle float64
+	unwrapped float64 // Unwrapped angle of residual, in rad
+	reference int     // Turn the residual was last settled on
+	locked    bool
+	tracking  bool // The slip counter's reference is valid
+	sample    int
+	slips     int
+	dropped   int
+}
+
+// NewLockMonitor creates the loop and detectors.
+func NewLockMonitor(config LockMonitorConfig) (*LockMonitor, error) {
+	loop, err := NewCarrierLoop(config.Loop)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Detectors) == 0 {
+		config.Detectors = []LockDetectorConfig{{Kind: LockIQRatio}, {Kind: LockPhaseVariance}}
+	}
+	if config.SlipAveraging == 0 {
+		config.SlipAveraging = 50
+	}
+	if config.SlipAveraging < 1 {
+		return nil, fmt.Errorf("slip averaging must be at least one sample, got %d", config.SlipAveraging)
+	}
+	m := &LockMonitor{
+		config:    config,
+		loop:      loop,
+		order:     config.Loop.Type.modulationOrder(),
+		align:     1,
+		slipAlpha: 1 / float64(config.SlipAveraging),
+	}
+	if config.Loop.Type == LoopCostasQPSK {
+		m.align = -1
+	}
+	for _, dc := range config.Detectors {
+		d, err := NewLockDetector(dc, config.Loop.Type)
+		if err != nil {
+			return nil, err
+		}
+		m.detectors = append(m.detectors, d)
+	}
+	return m, nil
+}
+
+// Process runs the loop over a block and returns the derotated samples and the events raised.
+func (m *LockMonitor) Process(samples []complex128) ([]complex128, []SyncEvent) {
+	out := make([]complex128, len(samples))
+	var events []SyncEvent
+	for n, x := range samples {
+		y, e := m.loop.Step(x)
+		out[n] = y
+		events = append(events, m.update(y, e)...)
+		m.sample++
+	}
+	return out, events
+}
+
+// update runs the detectors and the slip counter on one loop output.
+func (m *LockMonitor) update(y complex128, phaseError float64) []SyncEvent {
+	var events []SyncEvent
+	locked := false
+	for _, d := range m.detectors {
+		if d.Update(y, phaseError) {
+			kind := EventUnlock
+			if d.Locked() {
+				kind = EventLock
+			}
+			events = append(events, m.event(kind, d.Kind().String(), d.Metric(), 0))
+		}
+		locked = locked || d.Locked()
+	}
+
+	power := m.align
+	for i := 0; i < m.order; i++ {
+		power *= y
+	}
+	m.residual += complex(m.slipAlpha, 0) * (power - m.residual)
+	angle := cmplx.Phase(m.residual)
+	step := angle - m.lastAngle
+	step -= 2 * math.Pi * math.Round(step/(2*math.Pi))
+	m.unwrapped += step
+	m.lastAngle = angle
+
+	m.envelope += m.slipAlpha * (math.Pow(cmplx.Abs(y), float64(m.order)) - m.envelope)
+
+	turn := int(math.Round(m.unwrapped / (2 * math.Pi)))
+	tracking := locked && cmplx.Abs(m.residual) >= 0.5*m.envelope
+	switch {
+	case tracking && !m.tracking:
+		m.reference = turn
+	case tracking && turn != m.reference:
+		cycles := turn - m.reference
+		m.reference = turn
+		m.slips += cycles
+		events = append(events, m.event(EventCycleSlip, m.config.Loop.Type.String(), 0, cycles))
+	}
+	m.tracking = tracking
+	m.locked = locked
+	return events
+}
+
+// event builds an event for the current sample and offers it to the Events channel.
+func (m *LockMonitor) event(kind SyncEventKind, source string, value float64, cycles int) SyncEvent {
+	e := SyncEvent{
+		Kind:      kind,
+		SignalID:  m.config.SignalID,
+		Source:    source,
+		Sample:    m.sample,
+		Value:     value,
+		Cycles:    cycles,
+		Timestamp: m.config.Start.Add(seconds(float64(m.sample) / m.config.Loop.SampleRate)),
+	}
+	if m.config.Events != nil {
+		select {
+		case m.config.Events <- e:
+		default:
+			m.dropped++
+		}
+	}
+	return e
+}
+
+// Loop returns the carrier loop.
+func (m *LockMonitor) Loop() *CarrierLoop {
+	return m.loop
+}
+
+// Detectors returns the lock detectors in configuration order.
+func (m *LockMonitor) Detectors() []*LockDetector {
+	return append([]*LockDetector(nil), m.detectors...)
+}
+
+// Locked reports whether any detector reports lock.
+func (m *LockMonitor) Locked() bool {
+	return m.locked
+}
+
+// CycleSlips returns the net cycle slips counted, in units of 2π/M.
+func (m *LockMonitor) CycleSlips() int {
+	return m.slips
+}
+
+// Dropped returns the number of events the Events channel had no room for.
+func (m *LockMonitor) Dropped() int {
+	return m.dropped
+}
+
+// TrackLock runs a LockMonitor over a signal's samples, timed from the signal's Timestamp,
+// and records its events in the event log. The signal's Frequency and Phase are updated from
+// the loop if it ends locked.
+func (cs *CarrierSync) TrackLock(signalID string, samples []complex128, config LockMonitorConfig) ([]SyncEvent, error) {
+	cs.mutex.Lock()
+	index := -1
+	for i, s := range cs.signals {
+		if s.SignalID == signalID {
+			index = i
+			config.SignalID, config.Start = signalID, s.Timestamp
+		}
+	}
+	cs.mutex.Unlock()
+	if index < 0 {
+		return nil, fmt.Errorf("unknown signal %q", signalID)
+	}
+
+	monitor, err := NewLockMonitor(config)
+	if err != nil {
+		return nil, err
+	}
+	_, events := monitor.Process(samples)
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	for i := range cs.signals {
+		if cs.signals[i].SignalID != signalID {
+			continue
+		}
+		for j := range events {
+			events[j].Channel = cs.signals[i].Channel
+			cs.logEventLocked(events[j])
+		}
+		if monitor.Locked() {
+			status := monitor.Loop().Status()
+			cs.signals[i].Frequency = config.Loop.CenterFrequency + status.Frequency
+			cs.signals[i].Phase = status.Phase
+		}
+		return events, nil
+	}
+	return events, errors.New("signal removed while tracking")
+}
+
+// Subscribe returns a channel that receives every event logged from now on. Events are
+// dropped for a subscriber whose buffer is full.
+func (cs *CarrierSync) Subscribe(buffer int) <-chan SyncEvent {
+	ch := make(chan SyncEvent, buffer)
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	cs.subscribers = append(cs.subscribers, ch)
+	return ch
+}
+
+// Unsubscribe stops and closes a channel returned by Subscribe.
+func (cs *CarrierSync) Unsubscribe(events <-chan SyncEvent) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	for i, ch := range cs.subscribers {
+		if ch == events {
+			close(ch)
+			cs.subscribers = append(cs.subscribers[:i], cs.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// logEventLocked appends an event to the log, overwriting the oldest once maxErrorLog are
+// kept, and offers it to every subscriber.
+func (cs *CarrierSync) logEventLocked(event SyncEvent) {
+	if len(cs.errorLog) < maxErrorLog {
+		cs.errorLog = append(cs.errorLog, event)
+	} else {
+		cs.errorLog[cs.logStart] = event
+		cs.logStart = (cs.logStart + 1) % len(cs.errorLog)
+		cs.logDropped++
+	}
+	for _, ch := range cs.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// eventsLocked returns a copy of the event log, oldest event first.
+func (cs *CarrierSync) eventsLocked() []SyncEvent {
+	events := make([]SyncEvent, 0, len(cs.errorLog))
+	events = append(events, cs.errorLog[cs.logStart:]...)
+	return append(events, cs.errorLog[:cs.logStart]...)
+}
+
+// Events returns a copy of the newest maxErrorLog events, oldest first.
+func (cs *CarrierSync) Events() []SyncEvent {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	return cs.eventsLocked()
+}
+
+// DroppedEvents returns the number of events overwritten because the log was full.
+func (cs *CarrierSync) DroppedEvents() int {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	return cs.logDropped
+}
+
+// GetErrorLog returns the event log as log lines.
+func (cs *CarrierSync) GetErrorLog() []string {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	events := cs.eventsLocked()
+	lines := make([]string, len(events))
+	for i, e := range events {
+		lines[i] = e.String()
+	}
+	return lines
+}
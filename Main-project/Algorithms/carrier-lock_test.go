@@ -0,0 +1,156 @@
+package communication
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// TestLockDetectors tracks a carrier that takes a frequency step large enough to slip
+// cycles, then fades out and returns, and checks each detector's lock and unlock events and
+// the net slip count against the true loop phase.
+func TestLockDetectors(t *testing.T) {
+	const (
+		sampleRate = 10e3
+		snrDB      = 20.0
+		stepAt     = 30000
+		fadeAt     = 60000
+		returnAt   = 80000
+		n          = 120000
+	)
+	for _, tc := range []struct {
+		loopType LoopType
+		step     float64 // Frequency step, in Hz
+	}{
+		{LoopPLL, 45},
+		{LoopCostasBPSK, 20},
+	} {
+		rng := rand.New(rand.NewSource(44))
+		order := float64(tc.loopType.modulationOrder())
+		sigma := math.Sqrt(1 / (2 * math.Pow(10, snrDB/10)))
+		samples := make([]complex128, n)
+		truePhase := make([]float64, n)
+		var phase float64
+		symbol := complex(1, 0)
+		for k := range samples {
+			truePhase[k] = phase
+			if tc.loopType == LoopCostasBPSK && k%10 == 0 {
+				symbol = complex(2*float64(rng.Intn(2))-1, 0)
+			}
+			if k < fadeAt || k >= returnAt {
+				samples[k] = symbol * cmplx.Rect(1, phase)
+			}
+			samples[k] += complex(rng.NormFloat64()*sigma, rng.NormFloat64()*sigma)
+			if k >= stepAt {
+				phase += 2 * math.Pi * tc.step / sampleRate
+			}
+		}
+
+		events := make(chan SyncEvent, 64)
+		monitor, err := NewLockMonitor(LockMonitorConfig{
+			Loop:     LoopConfig{Type: tc.loopType, SampleRate: sampleRate, LoopBandwidth: 20},
+			SignalID: tc.loopType.String(),
+			Start:    time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+			Events:   events,
+		})
+		if err != nil {
+			t.Fatalf("%v: %v", tc.loopType, err)
+		}
+		// The slip truth is how far the loop's phase has fallen behind the carrier by the
+		// time the signal fades, in units of 2π/M.
+		monitor.Process(samples[:fadeAt])
+		truth := int(math.Round((truePhase[fadeAt-1] - monitor.Loop().UnwrappedPhase()) / (2 * math.Pi / order)))
+		slips := monitor.CycleSlips()
+		monitor.Process(samples[fadeAt:])
+		close(events)
+
+		sequences := make(map[string][]SyncEvent)
+		for e := range events {
+			if e.Kind != EventCycleSlip {
+				sequences[e.Source] = append(sequences[e.Source], e)
+			}
+		}
+		for _, d := range monitor.Detectors() {
+			seq := sequences[d.Kind().String()]
+			// Expected: lock before the step, loss of lock during the fade and lock again
+			// after the signal returns; a slip may add an unlock/lock pair after the step.
+			var lockedBefore, lostInFade, relocked bool
+			for _, e := range seq {
+				switch {
+				case e.Kind == EventLock && e.Sample < stepAt:
+					lockedBefore = true
+				case e.Kind == EventUnlock && e.Sample >= fadeAt && e.Sample < returnAt:
+					lostInFade = true
+				case e.Kind == EventLock && e.Sample >= returnAt:
+					relocked = true
+				}
+			}
+			if !lockedBefore || !lostInFade || !relocked || !d.Locked() {
+				t.Errorf("%v %v: %d events, locked %v, lost in fade %v, relocked %v, locked at end %v",
+					tc.loopType, d.Kind(), len(seq), lockedBefore, lostInFade, relocked, d.Locked())
+			}
+		}
+		if truth == 0 {
+			t.Errorf("%v %.0f Hz step: the loop did not slip, so the slip count is untested", tc.loopType, tc.step)
+		}
+		if slips != truth {
+			t.Errorf("%v %.0f Hz step: %d cycle slips counted, %d true", tc.loopType, tc.step, slips, truth)
+		}
+		if monitor.Dropped() != 0 {
+			t.Errorf("%v: %d events dropped", tc.loopType, monitor.Dropped())
+		}
+	}
+}
+
+// TestTrackLock checks that CarrierSync records lock events in its log and publishes them
+// to subscribers.
+func TestTrackLock(t *testing.T) {
+	cs := NewCarrierSync(50)
+	signal := SimulateSignal()
+	signal.Frequency = 1.8e9
+	cs.AddSignal(signal)
+	subscription := cs.Subscribe(16)
+	events, err := cs.TrackLock(signal.SignalID, cs.SimulateSamples(10e3, 20000)[signal.SignalID], LockMonitorConfig{
+		Loop: LoopConfig{Type: LoopPLL, SampleRate: 10e3, LoopBandwidth: 50, CenterFrequency: 1.8e9},
+	})
+	cs.Unsubscribe(subscription)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received int
+	for range subscription {
+		received++
+	}
+	if len(events) < 2 {
+		t.Errorf("%d lock events, want at least 2", len(events))
+	}
+	if received != len(events) {
+		t.Errorf("%d events delivered to the subscriber, want %d", received, len(events))
+	}
+	if len(cs.Events()) != len(events) {
+		t.Errorf("CarrierSync logged %d events, want %d", len(cs.Events()), len(events))
+	}
+}
+
+// TestEventLogBounded checks that the CarrierSync event log keeps the newest maxErrorLog
+// events in order and counts the ones it overwrote.
+func TestEventLogBounded(t *testing.T) {
+	cs := NewCarrierSync(50)
+	for i := 0; i < maxErrorLog+5; i++ {
+		cs.logEventLocked(SyncEvent{Kind: EventLock, Sample: i})
+	}
+	events := cs.Events()
+	if len(events) != maxErrorLog || len(cs.GetErrorLog()) != maxErrorLog {
+		t.Fatalf("log holds %d events, want %d", len(events), maxErrorLog)
+	}
+	for i, e := range events {
+		if e.Sample != i+5 {
+			t.Fatalf("event %d is sample %d, want %d", i, e.Sample, i+5)
+		}
+	}
+	if cs.DroppedEvents() != 5 {
+		t.Errorf("%d events dropped, want 5", cs.DroppedEvents())
+	}
+}
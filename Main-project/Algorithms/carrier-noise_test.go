@@ -0,0 +1,164 @@
+package communication
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestNoiseFilters filters a tone in white noise with each filter type and checks the measured
+// noise reduction against the reduction predicted from the filter's impulse response energy.
+func TestNoiseFilters(t *testing.T) {
+	const (
+		sampleRate = 100e3
+		toneHz     = 1e3
+		snrDB      = 10.0
+		n          = 1 << 16
+		settle     = 2048 // Output samples dropped while filters fill
+	)
+	rng := rand.New(rand.NewSource(40))
+	input := syntheticCarrier(rng, LoopPLL, n, sampleRate, sampleRate, toneHz, 0, snrDB)
+
+	taps, _ := DesignFIR(FilterLowpass, sampleRate, 5e3, 0, 129, WindowBlackman)
+	butterworth, _ := DesignButterworth(FilterLowpass, 6, 5e3, sampleRate)
+	chebyshev, _ := DesignChebyshev(FilterLowpass, 6, 0.5, 5e3, sampleRate)
+	cic, _ := NewCICDecimator(3, 8, 1)
+
+	filters := []struct {
+		name   string
+		filter Filter
+	}{
+		{"FIR lowpass 5 kHz", NewFIRFilter(taps)},
+		{"Butterworth-6 5 kHz", butterworth},
+		{"Chebyshev-6 0.5 dB 5 kHz", chebyshev},
+		{"CIC N=3 R=8", cic},
+	}
+	for _, f := range filters {
+		// The predicted noise reduction is 1/Σh² for unit-variance white noise.
+		var energy float64
+		if _, ok := f.filter.(*CICDecimator); ok {
+			energy = cicNoiseGain(3, 8)
+		} else {
+			impulse := make([]complex128, 1<<14)
+			impulse[0] = 1
+			for _, h := range f.filter.Process(impulse) {
+				energy += real(h) * real(h)
+			}
+			f.filter.Reset()
+		}
+		predicted := -10 * math.Log10(energy)
+
+		// Stream in uneven blocks to exercise the carried-over state.
+		var output []complex128
+		for start := 0; start < n; start += 1000 {
+			end := start + 1000
+			if end > n {
+				end = n
+			}
+			output = append(output, f.filter.Process(input[start:end])...)
+		}
+		skip := settle
+		if _, ok := f.filter.(*CICDecimator); ok {
+			skip = settle / 8
+		}
+		measured, err := MeasureNoiseReduction(input, output[skip:])
+		if err != nil {
+			t.Errorf("%s: %v", f.name, err)
+			continue
+		}
+		if math.Abs(measured.ReductionDB-predicted) > 0.5 {
+			t.Errorf("%s: noise reduced %.2f dB, predicted %.2f dB", f.name, measured.ReductionDB, predicted)
+		}
+	}
+}
+
+// TestAdaptiveCanceller cancels an interferer that reaches the primary input through an
+// unknown two-tap path while the reference input sees it directly, with LMS and NLMS.
+func TestAdaptiveCanceller(t *testing.T) {
+	const (
+		sampleRate = 100e3
+		toneHz     = 1e3
+		n          = 1 << 16
+	)
+	rng := rand.New(rand.NewSource(40))
+	for _, normalized := range []bool{false, true} {
+		interference := make([]complex128, n)
+		for i := range interference {
+			interference[i] = complex(rng.NormFloat64(), rng.NormFloat64())
+		}
+		primary := make([]complex128, n)
+		wanted := syntheticCarrier(rng, LoopPLL, n, sampleRate, sampleRate, toneHz, 0, 60)
+		for i := range primary {
+			primary[i] = wanted[i] + 0.8*interference[i]
+			if i > 0 {
+				primary[i] += complex(0.3, -0.2) * interference[i-1]
+			}
+		}
+		step, name := 0.002, "LMS"
+		if normalized {
+			step, name = 0.01, "NLMS"
+		}
+		canceller, err := NewAdaptiveCanceller(4, step, normalized)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		cleaned, err := canceller.Process(primary, interference)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var before, after float64
+		for i := n / 2; i < n; i++ {
+			d, e := primary[i]-wanted[i], cleaned[i]-wanted[i]
+			before += real(d)*real(d) + imag(d)*imag(d)
+			after += real(e)*real(e) + imag(e)*imag(e)
+		}
+		measured, err := MeasureNoiseReduction(primary[n/2:], cleaned[n/2:])
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		reduction := 10 * math.Log10(before/after)
+		if reduction < 20 {
+			t.Errorf("%s: interference reduced %.2f dB, want at least 20 dB", name, reduction)
+		}
+		if math.Abs(measured.ReductionDB-reduction) > 3 {
+			t.Errorf("%s: reduction measured from samples %.2f dB, from the known interference %.2f dB",
+				name, measured.ReductionDB, reduction)
+		}
+	}
+}
+
+// TestAdvancedNoiseReduction checks that the caller's samples are left alone and that a
+// noiseless block does not change the signal's noise level.
+func TestAdvancedNoiseReduction(t *testing.T) {
+	cs := NewCarrierSync(50)
+	cs.AddSignal(CarrierSignal{SignalID: "noisy", NoiseLevel: -40})
+	cs.AddSignal(CarrierSignal{SignalID: "silent", NoiseLevel: -40})
+	noisy := syntheticCarrier(rand.New(rand.NewSource(40)), LoopPLL, 8192, 100e3, 100e3, 1e3, 0, 10)
+	samples := map[string][]complex128{
+		"noisy":  noisy,
+		"silent": make([]complex128, 8192),
+	}
+	original := append([]complex128(nil), noisy...)
+	taps, _ := DesignFIR(FilterLowpass, 100e3, 5e3, 0, 129, WindowBlackman)
+	filtered, reductions := cs.AdvancedNoiseReduction(samples, func() Filter { return NewFIRFilter(taps) })
+
+	for i := range original {
+		if samples["noisy"][i] != original[i] {
+			t.Fatalf("input sample %d changed from %v to %v", i, original[i], samples["noisy"][i])
+		}
+	}
+	if len(filtered["noisy"]) != len(noisy) || len(filtered["silent"]) != 8192 {
+		t.Errorf("filtered %d and %d samples, want %d each", len(filtered["noisy"]), len(filtered["silent"]), len(noisy))
+	}
+	if _, ok := reductions["silent"]; ok {
+		t.Errorf("noise reduction reported for a noiseless block: %+v", reductions["silent"])
+	}
+	for _, s := range cs.signals {
+		switch {
+		case s.SignalID == "silent" && s.NoiseLevel != -40:
+			t.Errorf("noiseless block moved the noise level to %v dB", s.NoiseLevel)
+		case s.SignalID == "noisy" && !(s.NoiseLevel < -40):
+			t.Errorf("noise level %v dB, want below -40 dB after filtering", s.NoiseLevel)
+		}
+	}
+}
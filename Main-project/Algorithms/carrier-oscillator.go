@@ -0,0 +1,371 @@
+package communication
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// OscillatorConfig describes a frequency reference. The power-law noise levels are given as
+// the Allan deviation each type contributes at τ = 1 s; they scale as 1/τ for white PM, 1/√τ
+// for white FM, 1 for flicker FM and √τ for random-walk FM.
+type OscillatorConfig struct {
+	NominalFrequency     float64                       // in Hz
+	WhitePM              float64                       // Allan deviation at 1 s
+	WhiteFM              float64                       // Allan deviation at 1 s
+	FlickerFM            float64                       // Allan deviation floor
+	RandomWalkFM         float64                       // Allan deviation at 1 s
+	ShortestTau          float64                       // Shortest averaging time, in s, the flicker FM is modelled down to; 0 means 1 ms
+	FrequencyOffset      float64                       // Initial fractional frequency error
+	AgingPerDay          float64                       // Fractional frequency drift per day
+	TempCoefficient      float64                       // Fractional frequency change per °C
+	TempCurvature        float64                       // Fractional frequency change per °C²
+	ReferenceTemperature float64                       // in °C, where the temperature terms are zero
+	Temperature          func(elapsed float64) float64 // in °C after elapsed seconds; nil holds ReferenceTemperature
+	Seed                 int64
+}
+
+// TCXOConfig returns typical temperature-compensated crystal oscillator figures: an Allan
+// deviation near 5e-10 at 1 s, 5 ppb/°C residual temperature error and 1 ppb/day aging.
+func TCXOConfig(nominalFrequency float64) OscillatorConfig {
+	return OscillatorConfig{
+		NominalFrequency:     nominalFrequency,
+		WhiteFM:              2e-10,
+		FlickerFM:            5e-10,
+		RandomWalkFM:         1e-11,
+		TempCoefficient:      5e-9,
+		ReferenceTemperature: 25,
+		AgingPerDay:          1e-9,
+	}
+}
+
+// OCXOConfig returns typical oven-controlled crystal oscillator figures: an Allan deviation
+// near 5e-12 at 1 s, 0.05 ppb/°C and 0.1 ppb/day aging.
+func OCXOConfig(nominalFrequency float64) OscillatorConfig {
+	return OscillatorConfig{
+		NominalFrequency:     nominalFrequency,
+		WhiteFM:              1e-12,
+		FlickerFM:            5e-12,
+		RandomWalkFM:         1e-13,
+		TempCoefficient:      5e-11,
+		ReferenceTemperature: 25,
+		AgingPerDay:          1e-10,
+	}
+}
+
+// SinusoidalTemperature returns a temperature profile swinging ±swing °C around mean with the
+// given period, for OscillatorConfig.Temperature.
+func SinusoidalTemperature(mean, swing float64, period time.Duration) func(float64) float64 {
+	return func(elapsed float64) float64 {
+		return mean + swing*math.Sin(2*math.Pi*elapsed/period.Seconds())
+	}
+}
+
+// flickerPolesPerDecade sets how finely the flicker FM spectrum is approximated.
+const flickerPolesPerDecade = 2
+
+// flickerLongestTau is the longest correlation time of the flicker FM approximation, in s.
+const flickerLongestTau = 1e6
+
+// Oscillator simulates the time error of a frequency reference. Flicker FM is a sum of
+// first-order Gauss-Markov processes with correlation times spaced evenly in log time,
+// whose spectra add up to 1/f between ShortestTau and about eleven days.
+type Oscillator struct {
+	config     OscillatorConfig
+	rng        *rand.Rand
+	elapsed    float64   // in s
+	timeError  float64   // Integrated fractional frequency, in s, excluding white PM
+	whitePM    float64   // Current white PM time error, in s
+	randomWalk float64   // Random-walk FM fractional frequency
+	flicker    []float64 // Gauss-Markov states
+	flickerTau []float64 // Their correlation times, in s
+	flickerStd float64   // Stationary standard deviation of each state
+}
+
+// NewOscillator validates the configuration and starts the oscillator with its noise states
+// drawn from their stationary distributions.
+func NewOscillator(config OscillatorConfig) (*Oscillator, error) {
+	if config.NominalFrequency <= 0 {
+		return nil, fmt.Errorf("nominal frequency must be positive, got %v", config.NominalFrequency)
+	}
+	if config.WhitePM < 0 || config.WhiteFM < 0 || config.FlickerFM < 0 || config.RandomWalkFM < 0 {
+		return nil, errors.New("noise levels must not be negative")
+	}
+	if config.ShortestTau == 0 {
+		config.ShortestTau = 1e-3
+	}
+	if config.ShortestTau < 0 || config.ShortestTau >= flickerLongestTau {
+		return nil, fmt.Errorf("shortest tau must be in (0, %v) s, got %v", flickerLongestTau, config.ShortestTau)
+	}
+
+	o := &Oscillator{config: config, rng: rand.New(rand.NewSource(config.Seed))}
+	if config.FlickerFM > 0 {
+		// Equal-variance Lorentzians spaced by a factor r in correlation time sum to a
+		// one-sided spectrum of σ²/(f·ln r); flicker FM has Allan variance 2·ln2·h₋₁.
+		ratio := math.Pow(10, 1.0/flickerPolesPerDecade)
+		o.flickerStd = config.FlickerFM * math.Sqrt(math.Log(ratio)/(2*math.Ln2))
+		for tau := config.ShortestTau / 10; tau <= flickerLongestTau; tau *= ratio {
+			o.flickerTau = append(o.flickerTau, tau)
+			o.flicker = append(o.flicker, o.rng.NormFloat64()*o.flickerStd)
+		}
+	}
+	o.whitePM = o.rng.NormFloat64() * config.WhitePM / math.Sqrt(3)
+	return o, nil
+}
+
+// deterministic is the fractional frequency from offset, aging and temperature at t seconds.
+func (o *Oscillator) deterministic(t float64) float64 {
+	y := o.config.FrequencyOffset + o.config.AgingPerDay*t/86400
+	if o.config.Temperature != nil {
+		dT := o.config.Temperature(t) - o.config.ReferenceTemperature
+		y += o.config.TempCoefficient*dT + o.config.TempCurvature*dT*dT
+	}
+	return y
+}
+
+// Advance moves the oscillator forward by dt seconds and returns its time error.
+func (o *Oscillator) Advance(dt float64) float64 {
+	if dt <= 0 {
+		return o.TimeError()
+	}
+	// Offset, aging and temperature by the trapezoidal rule.
+	x := dt * (o.deterministic(o.elapsed) + o.deterministic(o.elapsed+dt)) / 2
+
+	// White FM: the time error is a random walk with diffusion WhiteFM² per second.
+	if o.config.WhiteFM > 0 {
+		x += o.rng.NormFloat64() * o.config.WhiteFM * math.Sqrt(dt)
+	}
+	// Random-walk FM: Allan variance Dτ/3 for frequency diffusion D per second.
+	if o.config.RandomWalkFM > 0 {
+		previous := o.randomWalk
+		o.randomWalk += o.rng.NormFloat64() * o.config.RandomWalkFM * math.Sqrt(3*dt)
+		x += dt * (previous + o.randomWalk) / 2
+	}
+	for i, tau := range o.flickerTau {
+		rho := math.Exp(-dt / tau)
+		o.flicker[i] = rho*o.flicker[i] + math.Sqrt(1-rho*rho)*o.flickerStd*o.rng.NormFloat64()
+		x += dt * o.flicker[i]
+	}
+	// White PM: independent time error samples with Allan variance 3σₓ²/τ².
+	if o.config.WhitePM > 0 {
+		o.whitePM = o.rng.NormFloat64() * o.config.WhitePM / math.Sqrt(3)
+	}
+
+	o.timeError += x
+	o.elapsed += dt
+	return o.TimeError()
+}
+
+// TimeError returns the accumulated time error, in s.
+func (o *Oscillator) TimeError() float64 {
+	return o.timeError + o.whitePM
+}
+
+// Phase returns the accumulated phase error at the nominal frequency, in rad.
+func (o *Oscillator) Phase() float64 {
+	return 2 * math.Pi * o.config.NominalFrequency * o.TimeError()
+}
+
+// FractionalFrequency returns the current fractional frequency error, excluding the white
+// noise types, which have no instantaneous value.
+func (o *Oscillator) FractionalFrequency() float64 {
+	y := o.deterministic(o.elapsed) + o.randomWalk
+	for _, s := range o.flicker {
+		y += s
+	}
+	return y
+}
+
+// Elapsed returns the time the oscillator has been running.
+func (o *Oscillator) Elapsed() time.Duration {
+	return seconds(o.elapsed)
+}
+
+// AllanPoint is the Allan deviation at one averaging time.
+type AllanPoint struct {
+	Tau       float64 // Averaging time, in s
+	Deviation float64
+	Count     int // Second differences averaged
+}
+
+// AllanDeviation computes the overlapping Allan deviation from time error samples, in s,
+// spaced interval seconds apart. Averaging times are rounded to a whole number of samples;
+// those too long for the data are skipped.
+func AllanDeviation(timeError []float64, interval float64, taus []float64) []AllanPoint {
+	var points []AllanPoint
+	for _, tau := range taus {
+		m := int(math.Round(tau / interval))
+		count := len(timeError) - 2*m
+		if m < 1 || count < 1 {
+			continue
+		}
+		var sum float64
+		for i := 0; i < count; i++ {
+			d := timeError[i+2*m] - 2*timeError[i+m] + timeError[i]
+			sum += d * d
+		}
+		actual := float64(m) * interval
+		points = append(points, AllanPoint{
+			Tau:       actual,
+			Deviation: math.Sqrt(sum / (2 * float64(count) * actual * actual)),
+			Count:     count,
+		})
+	}
+	return points
+}
+
+// signalOscillator is the oscillator of one CarrierSync signal with the signal's frequency
+// and phase when it was attached.
+type signalOscillator struct {
+	oscillator *Oscillator
+	frequency  float64 // in Hz
+	phase      float64 // in rad
+}
+
+// SetReferenceOscillator models the receiver's local reference. Its errors appear, negated,
+// in every signal's frequency and phase.
+func (cs *CarrierSync) SetReferenceOscillator(config OscillatorConfig) error {
+	oscillator, err := NewOscillator(config)
+	if err != nil {
+		return err
+	}
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	cs.reference = oscillator
+	return nil
+}
+
+// AttachOscillator models the transmitter oscillator of a signal. A zero NominalFrequency
+// takes the signal's current Frequency.
+func (cs *CarrierSync) AttachOscillator(signalID string, config OscillatorConfig) error {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	for _, signal := range cs.signals {
+		if signal.SignalID != signalID {
+			continue
+		}
+		if config.NominalFrequency == 0 {
+			config.NominalFrequency = signal.Frequency
+		}
+		oscillator, err := NewOscillator(config)
+		if err != nil {
+			return err
+		}
+		cs.oscillators[signalID] = &signalOscillator{oscillator: oscillator, frequency: signal.Frequency, phase: signal.Phase}
+		return nil
+	}
+	return fmt.Errorf("unknown signal %q", signalID)
+}
+
+// AdvanceOscillators runs every oscillator forward by elapsed and updates the Frequency and
+// Phase of the signals they affect as the receiver would measure them against its reference.
+func (cs *CarrierSync) AdvanceOscillators(elapsed time.Duration) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	dt := elapsed.Seconds()
+	if cs.reference != nil {
+		cs.reference.Advance(dt)
+	}
+	for _, so := range cs.oscillators {
+		so.oscillator.Advance(dt)
+	}
+	cs.applyOscillatorsLocked()
+}
+
+// applyOscillatorsLocked recomputes signal headers from the oscillator states.
+func (cs *CarrierSync) applyOscillatorsLocked() {
+	var refFrequency, refTime float64
+	if cs.reference != nil {
+		refFrequency, refTime = cs.reference.FractionalFrequency(), cs.reference.TimeError()
+	}
+	for i := range cs.signals {
+		signal := &cs.signals[i]
+		so, ok := cs.oscillators[signal.SignalID]
+		if !ok && cs.reference == nil {
+			continue
+		}
+		if !ok {
+			// Without its own oscillator the signal's base values live in a zero-noise entry.
+			so = &signalOscillator{frequency: signal.Frequency, phase: signal.Phase}
+			cs.oscillators[signal.SignalID] = so
+		}
+		y, x := -refFrequency, -refTime
+		if so.oscillator != nil {
+			y += so.oscillator.FractionalFrequency()
+			x += so.oscillator.TimeError()
+		}
+		signal.Frequency = so.frequency * (1 + y)
+		signal.Phase = math.Mod(so.phase+2*math.Pi*so.frequency*x, 2*math.Pi)
+		if signal.Phase < 0 {
+			signal.Phase += 2 * math.Pi
+		}
+	}
+}
+
+// oscillatorPhases advances the reference and each signal oscillator through n samples and
+// returns every signal's phase error trajectory, in rad, against the reference. Signals that
+// neither oscillator affects are left out. The caller holds cs.mutex.
+func (cs *CarrierSync) oscillatorPhases(sampleRate float64, n int) map[string][]float64 {
+	if cs.reference == nil && len(cs.oscillators) == 0 {
+		return nil
+	}
+	dt := 1 / sampleRate
+	reference := make([]float64, n)
+	if cs.reference != nil {
+		for i := range reference {
+			reference[i] = cs.reference.TimeError()
+			cs.reference.Advance(dt)
+		}
+	}
+	phases := make(map[string][]float64, len(cs.signals))
+	for _, signal := range cs.signals {
+		so := cs.oscillators[signal.SignalID]
+		if so == nil && cs.reference == nil {
+			continue
+		}
+		frequency := signal.Frequency
+		if so != nil {
+			frequency = so.frequency
+		}
+		phase := make([]float64, n)
+		for i := range phase {
+			x := -reference[i]
+			if so != nil && so.oscillator != nil {
+				x += so.oscillator.TimeError()
+				so.oscillator.Advance(dt)
+			}
+			phase[i] = 2 * math.Pi * frequency * x
+		}
+		phases[signal.SignalID] = phase
+	}
+	return phases
+}
+
+// TrackAllanDeviation tracks a signal's carrier in its samples and returns the Allan deviation
+// of the loop's phase, converted to time error at the signal's frequency. Averaging times
+// shorter than about 1/(2π·LoopBandwidth) see the loop's own filtering.
+func (cs *CarrierSync) TrackAllanDeviation(signalID string, samples []complex128, config LoopConfig, taus []float64) ([]AllanPoint, error) {
+	cs.mutex.Lock()
+	var frequency float64
+	for _, signal := range cs.signals {
+		if signal.SignalID == signalID {
+			frequency = signal.Frequency
+		}
+	}
+	cs.mutex.Unlock()
+	if frequency == 0 {
+		return nil, fmt.Errorf("unknown signal %q", signalID)
+	}
+	loop, err := NewCarrierLoop(config)
+	if err != nil {
+		return nil, err
+	}
+	timeError := make([]float64, len(samples))
+	for i, x := range samples {
+		loop.Step(x)
+		timeError[i] = loop.UnwrappedPhase() / (2 * math.Pi * frequency)
+	}
+	return AllanDeviation(timeError, 1/config.SampleRate, taus), nil
+}
@@ -0,0 +1,117 @@
+package communication
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestOscillator checks the Allan deviation of each power-law noise type against its specification.
+func TestOscillator(t *testing.T) {
+	const (
+		nominal = 10e6
+		step    = 0.01
+		n       = 1000000 // 10⁴ s
+	)
+	taus := []float64{0.1, 1, 10, 100}
+	noiseTypes := []struct {
+		name   string
+		config OscillatorConfig
+		slope  float64 // Allan deviation ∝ τ^slope
+	}{
+		{"white PM", OscillatorConfig{WhitePM: 1e-10}, -1},
+		{"white FM", OscillatorConfig{WhiteFM: 1e-10}, -0.5},
+		{"flicker FM", OscillatorConfig{FlickerFM: 1e-10, ShortestTau: step}, 0},
+		{"random-walk FM", OscillatorConfig{RandomWalkFM: 1e-10}, 0.5},
+	}
+	for _, nt := range noiseTypes {
+		nt.config.NominalFrequency, nt.config.Seed = nominal, 43
+		oscillator, err := NewOscillator(nt.config)
+		if err != nil {
+			t.Errorf("%s: %v", nt.name, err)
+			continue
+		}
+		timeError := make([]float64, n)
+		for i := range timeError {
+			timeError[i] = oscillator.Advance(step)
+		}
+		for _, p := range AllanDeviation(timeError, step, taus) {
+			if want := 1e-10 * math.Pow(p.Tau, nt.slope); math.Abs(p.Deviation/want-1) > 0.15 {
+				t.Errorf("%s: ADEV(%.1f s) %.3e, specified %.3e", nt.name, p.Tau, p.Deviation, want)
+			}
+		}
+	}
+}
+
+// TestOscillatorDrift checks the deterministic temperature and aging drift over two days.
+func TestOscillatorDrift(t *testing.T) {
+	drift, err := NewOscillator(OscillatorConfig{
+		NominalFrequency:     10e6,
+		AgingPerDay:          1e-9,
+		TempCoefficient:      5e-9,
+		TempCurvature:        1e-10,
+		ReferenceTemperature: 25,
+		Temperature:          SinusoidalTemperature(25, 10, 24*time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var worst float64
+	for hour := 1; hour <= 48; hour++ {
+		drift.Advance(3600)
+		s := float64(hour) * 3600
+		dT := 10 * math.Sin(2*math.Pi*s/86400)
+		want := 1e-9*s/86400 + 5e-9*dT + 1e-10*dT*dT
+		worst = math.Max(worst, math.Abs(drift.FractionalFrequency()-want))
+	}
+	if worst > 1e-15 {
+		t.Errorf("temperature and aging over 48 h: worst fractional frequency error %.1e", worst)
+	}
+}
+
+// TestTrackedAllanDeviation checks that a carrier loop tracking a signal with both transmitter
+// and reference oscillator noise reports the Allan deviation of the phase difference between
+// them: a 1.8 GHz carrier from a TCXO-class transmitter, received against an OCXO-class
+// reference and tracked by a 20 Hz PLL.
+func TestTrackedAllanDeviation(t *testing.T) {
+	const sampleRate, samples = 2e3, 200000
+	cs := NewCarrierSync(10)
+	signal := SimulateSignal()
+	signal.NoiseLevel = -40
+	cs.AddSignal(signal)
+	transmitter := OscillatorConfig{FlickerFM: 1e-10, RandomWalkFM: 1e-11, WhiteFM: 1e-11, Seed: 1}
+	reference := OscillatorConfig{NominalFrequency: 10e6, WhiteFM: 1e-11, FlickerFM: 1e-11, Seed: 2}
+	if err := cs.SetReferenceOscillator(reference); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.AttachOscillator(signal.SignalID, transmitter); err != nil {
+		t.Fatal(err)
+	}
+
+	// The true phase trajectory comes from a copy of the same oscillators.
+	transmitter.NominalFrequency = signal.Frequency
+	truthTx, _ := NewOscillator(transmitter)
+	truthRef, _ := NewOscillator(reference)
+	truth := make([]float64, samples)
+	for i := range truth {
+		truth[i] = truthTx.TimeError() - truthRef.TimeError()
+		truthTx.Advance(1 / sampleRate)
+		truthRef.Advance(1 / sampleRate)
+	}
+
+	iq := cs.SimulateSamples(sampleRate, samples)[signal.SignalID]
+	offset := signal.Frequency - DefaultChannelPlan().Nearest(signal.Frequency).NominalFrequency
+	taus := []float64{0.5, 1, 5, 10}
+	tracked, err := cs.TrackAllanDeviation(signal.SignalID, iq, LoopConfig{
+		Type: LoopPLL, SampleRate: sampleRate, LoopBandwidth: 20, InitialFrequency: offset,
+	}, taus)
+	if err != nil {
+		t.Fatalf("tracking: %v", err)
+	}
+	expected := AllanDeviation(truth, 1/sampleRate, taus)
+	for i, p := range tracked {
+		if math.Abs(p.Deviation/expected[i].Deviation-1) > 0.1 {
+			t.Errorf("tracked ADEV(%.1f s) %.3e, oscillators %.3e", p.Tau, p.Deviation, expected[i].Deviation)
+		}
+	}
+}
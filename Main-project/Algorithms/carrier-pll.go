@@ -0,0 +1,218 @@
+package communication
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// LoopType selects the phase detector used by a CarrierLoop.
+type LoopType int
+
+const (
+	LoopPLL        LoopType = iota // Residual (unmodulated) carrier
+	LoopCostasBPSK                 // Suppressed carrier, BPSK; 180° ambiguity
+	LoopCostasQPSK                 // Suppressed carrier, QPSK; 90° ambiguity
+)
+
+// String returns the loop name.
+func (t LoopType) String() string {
+	switch t {
+	case LoopPLL:
+		return "PLL"
+	case LoopCostasBPSK:
+		return "Costas-BPSK"
+	case LoopCostasQPSK:
+		return "Costas-QPSK"
+	default:
+		return fmt.Sprintf("LoopType(%d)", int(t))
+	}
+}
+
+// LoopConfig configures a second-order carrier tracking loop.
+type LoopConfig struct {
+	Type             LoopType
+	SampleRate       float64 // Complex sample rate, in Hz
+	LoopBandwidth    float64 // One-sided noise bandwidth Bn, in Hz
+	Damping          float64 // Damping factor ζ; 0 means 1/√2
+	InitialFrequency float64 // NCO starting frequency offset, in Hz
+	CenterFrequency  float64 // RF frequency the baseband was mixed down from, in Hz
+	LockThreshold    float64 // RMS phase error below which the loop reports lock, in rad; 0 means 0.35
+	ReportEvery      int     // Samples between LoopStatus reports; 0 means every 1000
+}
+
+// LoopStatus is a snapshot of the loop state.
+type LoopStatus struct {
+	Sample     int     // Index of the sample the status refers to
+	Elapsed    float64 // Seconds since the loop started
+	PhaseError float64 // Instantaneous detector output, in rad
+	RMSError   float64 // Smoothed RMS phase error, in rad
+	Frequency  float64 // NCO frequency offset estimate, in Hz
+	Phase      float64 // NCO phase, in rad, wrapped to [0, 2π)
+	Locked     bool
+}
+
+// CarrierLoop is a second-order PLL or Costas loop running on complex baseband samples.
+type CarrierLoop struct {
+	config    LoopConfig
+	k1, k2    float64 // Proportional and integral gains
+	phase     float64 // NCO phase, in rad
+	unwrapped float64 // NCO phase without wrapping, in rad
+	frequency float64 // NCO frequency, in rad/sample
+	errorVar  float64 // Smoothed squared phase error
+	sample    int
+}
+
+// NewCarrierLoop computes the loop gains from the bandwidth and damping.
+func NewCarrierLoop(config LoopConfig) (*CarrierLoop, error) {
+	if config.SampleRate <= 0 {
+		return nil, fmt.Errorf("sample rate must be positive, got %v", config.SampleRate)
+	}
+	if config.LoopBandwidth <= 0 || config.LoopBandwidth >= config.SampleRate/4 {
+		return nil, fmt.Errorf("loop bandwidth %v Hz must be in (0, %v) Hz", config.LoopBandwidth, config.SampleRate/4)
+	}
+	if config.Damping <= 0 {
+		config.Damping = 1 / math.Sqrt2
+	}
+	if config.LockThreshold <= 0 {
+		config.LockThreshold = 0.35
+	}
+	if config.ReportEvery <= 0 {
+		config.ReportEvery = 1000
+	}
+	k1, k2 := loopGains(config.LoopBandwidth/config.SampleRate, config.Damping)
+	return &CarrierLoop{
+		config:    config,
+		k1:        k1,
+		k2:        k2,
+		frequency: 2 * math.Pi * config.InitialFrequency / config.SampleRate,
+		errorVar:  math.Pi * math.Pi / 3, // Uniform phase error until the loop settles
+	}, nil
+}
+
+// loopGains returns the proportional-plus-integral gains of a second-order loop with unit
+// detector gain for normalised bandwidth bnT and damping zeta.
+func loopGains(bnT, zeta float64) (k1, k2 float64) {
+	theta := bnT / (zeta + 1/(4*zeta))
+	denom := 1 + 2*zeta*theta + theta*theta
+	return 4 * zeta * theta / denom, 4 * theta * theta / denom
+}
+
+// Step derotates one sample by the NCO, updates the loop and returns the derotated
+// sample with the phase detector output.
+func (l *CarrierLoop) Step(x complex128) (complex128, float64) {
+	y := x * cmplx.Rect(1, -l.phase)
+	e := l.detect(y)
+
+	l.frequency += l.k2 * e
+	l.phase += l.frequency + l.k1*e
+	l.unwrapped += l.frequency + l.k1*e
+	l.phase = math.Mod(l.phase, 2*math.Pi)
+	if l.phase < 0 {
+		l.phase += 2 * math.Pi
+	}
+	l.errorVar += 0.002 * (e*e - l.errorVar)
+	l.sample++
+	return y, e
+}
+
+// detect is the phase detector, normalised to unit gain near lock.
+func (l *CarrierLoop) detect(y complex128) float64 {
+	i, q := real(y), imag(y)
+	switch l.config.Type {
+	case LoopCostasBPSK:
+		if i == 0 {
+			return 0
+		}
+		return math.Atan(q / i)
+	case LoopCostasQPSK:
+		mag := cmplx.Abs(y)
+		if mag == 0 {
+			return 0
+		}
+		return (sign(i)*q - sign(q)*i) / (mag * math.Sqrt2)
+	default:
+		return math.Atan2(q, i)
+	}
+}
+
+// Process runs the loop over a block and returns the derotated samples and periodic status.
+func (l *CarrierLoop) Process(samples []complex128) ([]complex128, []LoopStatus) {
+	out := make([]complex128, len(samples))
+	var reports []LoopStatus
+	for n, x := range samples {
+		var e float64
+		out[n], e = l.Step(x)
+		if l.sample%l.config.ReportEvery == 0 || n == len(samples)-1 {
+			status := l.Status()
+			status.PhaseError = e
+			reports = append(reports, status)
+		}
+	}
+	return out, reports
+}
+
+// Status returns the current loop state.
+func (l *CarrierLoop) Status() LoopStatus {
+	rms := math.Sqrt(l.errorVar)
+	return LoopStatus{
+		Sample:    l.sample,
+		Elapsed:   float64(l.sample) / l.config.SampleRate,
+		RMSError:  rms,
+		Frequency: l.FrequencyOffset(),
+		Phase:     l.phase,
+		Locked:    rms < l.config.LockThreshold,
+	}
+}
+
+// UnwrappedPhase returns the NCO phase accumulated since the loop started, in rad.
+func (l *CarrierLoop) UnwrappedPhase() float64 {
+	return l.unwrapped
+}
+
+// FrequencyOffset returns the NCO frequency estimate, in Hz.
+func (l *CarrierLoop) FrequencyOffset() float64 {
+	return l.frequency * l.config.SampleRate / (2 * math.Pi)
+}
+
+// LoopResult summarises a carrier recovery run on one signal.
+type LoopResult struct {
+	SignalID  string
+	Final     LoopStatus
+	History   []LoopStatus
+	Recovered []complex128 // Derotated samples
+}
+
+// RecoverCarrier tracks the carrier of the signal's IQ samples and updates its Frequency
+// and Phase from the loop's final estimate.
+func (cs *CarrierSync) RecoverCarrier(signalID string, samples []complex128, config LoopConfig) (LoopResult, error) {
+	loop, err := NewCarrierLoop(config)
+	if err != nil {
+		return LoopResult{}, err
+	}
+	recovered, history := loop.Process(samples)
+	final := loop.Status()
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	for i := range cs.signals {
+		if cs.signals[i].SignalID == signalID {
+			cs.signals[i].Frequency = config.CenterFrequency + final.Frequency
+			cs.signals[i].Phase = final.Phase
+			return LoopResult{SignalID: signalID, Final: final, History: history, Recovered: recovered}, nil
+		}
+	}
+	return LoopResult{}, fmt.Errorf("unknown signal %q", signalID)
+}
+
+// sign returns -1, 0 or 1.
+func sign(x float64) float64 {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	default:
+		return 0
+	}
+}
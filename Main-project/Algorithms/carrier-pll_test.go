@@ -0,0 +1,58 @@
+package communication
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+// syntheticCarrier generates n samples of a carrier at offsetHz with the modulation the loop
+// type expects (none, BPSK or QPSK at symbolRate) and complex white noise at snrDB.
+func syntheticCarrier(rng *rand.Rand, loopType LoopType, n int, sampleRate, symbolRate, offsetHz, phase, snrDB float64) []complex128 {
+	sigma := math.Sqrt(1 / (2 * math.Pow(10, snrDB/10)))
+	samplesPerSymbol := int(sampleRate / symbolRate)
+	samples := make([]complex128, n)
+	symbol := complex(1, 0)
+	for k := range samples {
+		if k%samplesPerSymbol == 0 {
+			switch loopType {
+			case LoopCostasBPSK:
+				symbol = complex(2*float64(rng.Intn(2))-1, 0)
+			case LoopCostasQPSK:
+				symbol = cmplx.Rect(1, math.Pi/4+math.Pi/2*float64(rng.Intn(4)))
+			}
+		}
+		carrier := cmplx.Rect(1, 2*math.Pi*offsetHz*float64(k)/sampleRate+phase)
+		samples[k] = symbol*carrier + complex(rng.NormFloat64()*sigma, rng.NormFloat64()*sigma)
+	}
+	return samples
+}
+
+// TestCarrierLoops runs each loop type on a synthetic signal with a known frequency and
+// phase offset and checks that it locks onto the offset.
+func TestCarrierLoops(t *testing.T) {
+	const (
+		sampleRate = 100e3
+		symbolRate = 10e3
+		offsetHz   = 150.0
+		snrDB      = 20.0
+		n          = 100000
+	)
+	rng := rand.New(rand.NewSource(11))
+
+	for _, loopType := range []LoopType{LoopPLL, LoopCostasBPSK, LoopCostasQPSK} {
+		samples := syntheticCarrier(rng, loopType, n, sampleRate, symbolRate, offsetHz, 0.7, snrDB)
+
+		loop, err := NewCarrierLoop(LoopConfig{Type: loopType, SampleRate: sampleRate, LoopBandwidth: 200})
+		if err != nil {
+			t.Fatalf("%v: %v", loopType, err)
+		}
+		_, history := loop.Process(samples)
+		final := history[len(history)-1]
+		if !final.Locked || math.Abs(final.Frequency-offsetHz) > 1 {
+			t.Errorf("%v: frequency %.3f Hz (true %.1f Hz), RMS phase error %.3f rad, locked %v",
+				loopType, final.Frequency, offsetHz, final.RMSError, final.Locked)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package communication
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestSNREstimators measures the bias and standard deviation of each estimator over repeated
+// trials at several SNRs and checks them against the figures in the estimators' documentation.
+func TestSNREstimators(t *testing.T) {
+	const (
+		sampleRate       = 100e3
+		samplesPerSymbol = 8
+		n                = 4096
+		trials           = 200
+	)
+	rng := rand.New(rand.NewSource(38))
+
+	estimators := []struct {
+		name     string
+		loopType LoopType
+		estimate func([]complex128) (SNREstimate, error)
+		offset   float64 // dB between the per-sample SNR and what the estimator reports
+		maxBias  float64 // dB
+		maxStd   float64 // dB
+	}{
+		{"M2M4", LoopCostasQPSK, func(x []complex128) (SNREstimate, error) { return EstimateSNRM2M4(x, sampleRate) }, 0, 0.1, 0.4},
+		{"split-symbol", LoopCostasQPSK, func(x []complex128) (SNREstimate, error) {
+			return EstimateSNRSplitSymbol(x, sampleRate, samplesPerSymbol)
+		}, 10 * math.Log10(samplesPerSymbol), 0.1, 0.3},
+		{"spectral", LoopPLL, func(x []complex128) (SNREstimate, error) { return EstimateCN0Spectral(x, sampleRate, WindowHann) }, 0, 0.1, 0.25},
+	}
+
+	for _, est := range estimators {
+		for _, snrDB := range []float64{0, 5, 10, 20} {
+			var results []float64
+			for trial := 0; trial < trials; trial++ {
+				offset, phase := 0.0, rng.Float64()*2*math.Pi
+				if est.loopType == LoopPLL {
+					offset = rng.Float64()*20e3 - 10e3
+				}
+				x := syntheticCarrier(rng, est.loopType, n, sampleRate, sampleRate/samplesPerSymbol, offset, phase, snrDB)
+				e, err := est.estimate(x)
+				if err != nil {
+					t.Fatalf("%s at %.1f dB: %v", est.name, snrDB, err)
+				}
+				if math.IsInf(e.SNRdB, 0) {
+					continue
+				}
+				results = append(results, e.SNRdB)
+			}
+			truth := snrDB + est.offset
+			var mean, variance float64
+			for _, r := range results {
+				mean += r / float64(len(results))
+			}
+			for _, r := range results {
+				variance += (r - mean) * (r - mean) / float64(len(results))
+			}
+			bias, std := mean-truth, math.Sqrt(variance)
+			if len(results) < trials || math.Abs(bias) > est.maxBias || std > est.maxStd {
+				t.Errorf("%s at %.1f dB: bias %+.3f dB (max %.2f), std %.3f dB (max %.2f), %d/%d finite trials",
+					est.name, truth, bias, est.maxBias, std, est.maxStd, len(results), trials)
+			}
+		}
+	}
+}
@@ -17,6 +17,7 @@ type CarrierSignal struct {
 	Amplitude  float64 // in arbitrary units
 	NoiseLevel float64 // in dB
 	SignalID   string
+	Channel    string // Channel plan entry the signal was matched to
 	Timestamp  time.Time
 }
 
@@ -24,7 +25,17 @@ type CarrierSignal struct {
 type CarrierSync struct {
 	signals       []CarrierSignal
 	mutex         sync.Mutex
-	errorLog      []string
+	errorLog      []SyncEvent // The newest maxErrorLog events, a ring starting at logStart
+	logStart      int
+	logDropped    int // Events overwritten in errorLog
+	subscribers   []chan SyncEvent
+	retention     *retainer[CarrierSignal]
+	plan          *ChannelPlan
+	snr           map[string]SNREstimate // Latest measured SNR by signal ID
+	snrThresholds SNRThresholds
+	lowSNR        map[string]bool // Signals currently flagged for low SNR
+	reference     *Oscillator     // Local reference; nil means ideal
+	oscillators   map[string]*signalOscillator
 	syncTolerance float64 // Tolerance in Hz
 }
 
@@ -32,32 +43,29 @@ type CarrierSync struct {
 func NewCarrierSync(tolerance float64) *CarrierSync {
 	return &CarrierSync{
 		signals:       []CarrierSignal{},
+		plan:          DefaultChannelPlan(),
+		snr:           make(map[string]SNREstimate),
+		snrThresholds: DefaultSNRThresholds(),
+		lowSNR:        make(map[string]bool),
+		oscillators:   make(map[string]*signalOscillator),
 		syncTolerance: tolerance,
 	}
 }
 
-// AddSignal adds a new CarrierSignal for synchronization.
+// AddSignal adds a new CarrierSignal for synchronization, applying the retention policy when due.
 func (cs *CarrierSync) AddSignal(signal CarrierSignal) {
 	cs.mutex.Lock()
-	defer cs.mutex.Unlock()
 	cs.signals = append(cs.signals, signal)
+	if cs.retention != nil && cs.retention.due(signal.Timestamp, len(cs.signals)) {
+		cs.applyRetentionLocked()
+		return
+	}
+	cs.mutex.Unlock()
 }
 
-// SimulateSignal generates a simulated carrier signal.
+// SimulateSignal generates a simulated carrier signal on the default 1.8 GHz channel.
 func SimulateSignal() CarrierSignal {
-	baseFrequency := 1.8e9          // 1.8 GHz
-	noise := randomFloat(0.01, 0.1) // Simulated noise
-	phase := randomFloat(0, 2*math.Pi)
-	amplitude := randomFloat(0.8, 1.2)
-
-	return CarrierSignal{
-		Frequency:  baseFrequency + randomFloat(-500, 500), // ±500 Hz deviation
-		Phase:      phase,
-		Amplitude:  amplitude,
-		NoiseLevel: noise,
-		SignalID:   randomString(10),
-		Timestamp:  time.Now(),
-	}
+	return SimulateChannelSignal(DefaultChannelPlan().channels[0]) // ±Tolerance/2 deviation
 }
 
 // AlignPhase aligns the phase of the given signals.
@@ -76,35 +84,57 @@ func (cs *CarrierSync) AlignPhase() {
 	}
 }
 
-// CorrectFrequency attempts to correct frequency deviations within tolerance.
-func (cs *CarrierSync) CorrectFrequency() {
+// CorrectFrequency matches each signal to its nearest channel in the channel plan and
+// corrects deviations beyond the sync tolerance relative to that channel's nominal frequency.
+func (cs *CarrierSync) CorrectFrequency() []ChannelMatch {
 	cs.mutex.Lock()
 	defer cs.mutex.Unlock()
 
+	matches := make([]ChannelMatch, len(cs.signals))
 	for i := range cs.signals {
 		signal := &cs.signals[i]
-		if math.Abs(signal.Frequency-1.8e9) > cs.syncTolerance {
-			correctedFrequency := 1.8e9
-			fmt.Printf("Signal %s frequency corrected: %.2f Hz -> %.2f Hz\n", signal.SignalID, signal.Frequency, correctedFrequency)
+		match := cs.plan.Match(*signal, cs.syncTolerance)
+		signal.Channel = match.Channel
+		switch match.Status {
+		case ChannelCorrected:
+			correctedFrequency := signal.Frequency + match.Correction
+			fmt.Printf("Signal %s frequency corrected on %s: %.2f Hz -> %.2f Hz\n", signal.SignalID, match.Channel, signal.Frequency, correctedFrequency)
 			signal.Frequency = correctedFrequency
-		} else {
-			fmt.Printf("Signal %s frequency within tolerance: %.2f Hz\n", signal.SignalID, signal.Frequency)
+		case ChannelInTolerance:
+			fmt.Printf("Signal %s frequency within tolerance on %s: %.2f Hz\n", signal.SignalID, match.Channel, signal.Frequency)
+		case ChannelInterference:
+			fmt.Printf("Signal %s flagged as interference on %s: %.2f Hz (%.2f Hz off nominal)\n", signal.SignalID, match.Channel, signal.Frequency, match.Offset)
+			cs.logEventLocked(SyncEvent{Kind: EventInterference, SignalID: signal.SignalID, Channel: match.Channel, Value: match.Offset, Timestamp: signal.Timestamp})
+		default:
+			fmt.Printf("Signal %s unassigned: %.2f Hz is outside every channel\n", signal.SignalID, signal.Frequency)
+			cs.logEventLocked(SyncEvent{Kind: EventUnassigned, SignalID: signal.SignalID, Timestamp: signal.Timestamp})
 		}
+		matches[i] = match
 	}
+	return matches
 }
 
-// CalculateSignalStrength calculates signal-to-noise ratio (SNR) for a signal.
-func (cs *CarrierSync) CalculateSignalStrength() {
+// CalculateSignalStrength returns the SNR of each signal, taken from its latest recorded
+// estimate or else from its Amplitude and NoiseLevel, and flags low SNR with hysteresis.
+func (cs *CarrierSync) CalculateSignalStrength() []SignalQuality {
 	cs.mutex.Lock()
 	defer cs.mutex.Unlock()
 
-	for _, signal := range cs.signals {
-		snr := 10 * math.Log10(signal.Amplitude/signal.NoiseLevel)
-		fmt.Printf("Signal %s SNR: %.2f dB\n", signal.SignalID, snr)
-		if snr < 10.0 {
-			cs.errorLog = append(cs.errorLog, fmt.Sprintf("Low SNR for signal %s at %v", signal.SignalID, signal.Timestamp))
+	qualities := make([]SignalQuality, len(cs.signals))
+	for i, signal := range cs.signals {
+		quality := SignalQuality{SignalID: signal.SignalID, Method: SNRFromHeader, SNRdB: headerSNR(signal), CN0: math.NaN()}
+		if estimate, ok := cs.snr[signal.SignalID]; ok {
+			quality.Method, quality.SNRdB, quality.CN0 = estimate.Method, estimate.SNRdB, estimate.CN0
+		}
+		var entered bool
+		quality.Low, entered = cs.updateLowSNR(signal.SignalID, quality.SNRdB)
+		fmt.Printf("Signal %s SNR: %.2f dB (%v)\n", signal.SignalID, quality.SNRdB, quality.Method)
+		if entered {
+			cs.logEventLocked(SyncEvent{Kind: EventLowSNR, SignalID: signal.SignalID, Channel: signal.Channel, Value: quality.SNRdB, Timestamp: signal.Timestamp})
 		}
+		qualities[i] = quality
 	}
+	return qualities
 }
 
 // Synchronize synchronizes all carrier signals.
@@ -116,7 +146,7 @@ func (cs *CarrierSync) Synchronize() {
 	fmt.Println("Carrier Synchronization Completed.")
 }
 
-// SaveErrorLog saves the error log to a file for post-analysis.
+// SaveErrorLog saves the event log to a file for post-analysis, one line per event.
 func (cs *CarrierSync) SaveErrorLog(filename string) error {
 	cs.mutex.Lock()
 	defer cs.mutex.Unlock()
@@ -127,23 +157,44 @@ func (cs *CarrierSync) SaveErrorLog(filename string) error {
 	}
 	defer file.Close()
 
-	for _, log := range cs.errorLog {
-		file.WriteString(log + "\n")
+	for _, event := range cs.eventsLocked() {
+		file.WriteString(event.String() + "\n")
 	}
 	return nil
 }
 
-// AdvancedNoiseReduction applies noise reduction techniques to signals.
-func (cs *CarrierSync) AdvancedNoiseReduction() {
+// AdvancedNoiseReduction filters each signal's samples with a fresh filter from newFilter,
+// measures the noise reduction from the samples before and after filtering, and lowers the
+// signal's NoiseLevel by the measured amount. It returns the filtered samples and the
+// measurements; samples is not modified, and signals without samples are left unchanged.
+func (cs *CarrierSync) AdvancedNoiseReduction(samples map[string][]complex128, newFilter func() Filter) (map[string][]complex128, map[string]NoiseReduction) {
+	filtered := make(map[string][]complex128, len(samples))
+	reductions := make(map[string]NoiseReduction, len(samples))
+	for id, before := range samples {
+		after := newFilter().Process(before)
+		filtered[id] = after
+		reduction, err := MeasureNoiseReduction(before, after)
+		if err != nil {
+			fmt.Printf("Signal %s noise not measured: %v\n", id, err)
+			continue
+		}
+		reductions[id] = reduction
+	}
+
 	cs.mutex.Lock()
 	defer cs.mutex.Unlock()
 
 	for i := range cs.signals {
 		signal := &cs.signals[i]
+		reduction, ok := reductions[signal.SignalID]
+		if !ok {
+			continue
+		}
 		originalNoise := signal.NoiseLevel
-		signal.NoiseLevel = originalNoise * 0.8 // Example: reduce noise by 20%
-		fmt.Printf("Signal %s noise reduced: %.2f -> %.2f\n", signal.SignalID, originalNoise, signal.NoiseLevel)
+		signal.NoiseLevel = originalNoise - reduction.ReductionDB
+		fmt.Printf("Signal %s noise reduced: %.2f dB -> %.2f dB (measured %.2f dB)\n", signal.SignalID, originalNoise, signal.NoiseLevel, reduction.ReductionDB)
 	}
+	return filtered, reductions
 }
 
 // LogSignalDetails logs details of all signals.
@@ -184,7 +235,7 @@ func (cs *CarrierSync) SimulateRealTimeProcessing(duration time.Duration) {
 			case <-stop:
 				return
 			default:
-				newSignal := SimulateSignal()
+				newSignal := cs.simulatePlanSignal()
 				cs.AddSignal(newSignal)
 				cs.Synchronize()
 				time.Sleep(100 * time.Millisecond) // Simulate processing interval
@@ -207,7 +258,11 @@ func TestCarrierSync() {
 	fmt.Println("Initial Signal Details:")
 	cs.LogSignalDetails()
 	cs.Synchronize()
-	cs.AdvancedNoiseReduction()
+	samples := cs.SimulateSamples(100e3, 8192)
+	cs.AdvancedNoiseReduction(samples, func() Filter {
+		taps, _ := DesignFIR(FilterLowpass, 100e3, 2e3, 0, 129, WindowBlackman)
+		return NewFIRFilter(taps)
+	})
 	cs.Synchronize()
 
 	fmt.Println("Saving Error Log...")
@@ -0,0 +1,291 @@
+package communication
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// TimingDetector selects the timing error detector used by a SymbolSync.
+type TimingDetector int
+
+const (
+	TimingGardner       TimingDetector = iota // Non-data-aided, insensitive to carrier phase; needs two samples per symbol
+	TimingMuellerMuller                       // Decision-directed, one sample per symbol; needs carrier lock
+)
+
+// String returns the detector name.
+func (d TimingDetector) String() string {
+	switch d {
+	case TimingGardner:
+		return "Gardner"
+	case TimingMuellerMuller:
+		return "Mueller-Muller"
+	default:
+		return fmt.Sprintf("TimingDetector(%d)", int(d))
+	}
+}
+
+// TimingConfig configures symbol timing recovery.
+type TimingConfig struct {
+	Detector         TimingDetector
+	Modulation       Modulation
+	SamplesPerSymbol float64 // Nominal input samples per symbol, at least 2
+	LoopBandwidth    float64 // Noise bandwidth normalised to the symbol rate (BnTs); 0 means 0.01
+	Damping          float64 // Damping factor ζ; 0 means 1/√2
+	LockThreshold    float64 // Lock indicator above which timing is reported locked; 0 means 0.1
+	EyeWindow        int     // Symbols in the eye metrics; 0 means 256
+	ReportEvery      int     // Symbols between TimingStatus reports; 0 means every 100
+}
+
+// EyeMetrics describes the eye diagram at the strobe instants over the last EyeWindow symbols.
+type EyeMetrics struct {
+	Opening    float64 // (mean − 3σ)/mean of the decision-axis magnitudes; 1 is fully open, ≤ 0 closed
+	MinOpening float64 // Smallest decision-axis magnitude over the mean
+	EVM        float64 // RMS error vector magnitude relative to the mean symbol amplitude
+	Symbols    int
+}
+
+// TimingStatus is a snapshot of the symbol synchroniser.
+type TimingStatus struct {
+	Symbol           int     // Index of the symbol the status refers to
+	SamplesPerSymbol float64 // Smoothed symbol period, in input samples
+	TimingError      float64 // Smoothed detector output, in symbols (positive is late)
+	LockIndicator    float64 // Strobe energy minus midpoint energy, over their sum; near 0 without timing lock
+	Locked           bool
+	Eye              EyeMetrics
+}
+
+// SymbolSync recovers symbol timing with a Gardner or Mueller–Müller detector driving a
+// cubic Farrow interpolator, and outputs one interpolated sample per symbol.
+type SymbolSync struct {
+	config TimingConfig
+	k1, k2 float64 // Proportional and integral gains
+
+	buf    []complex128 // Unconsumed input samples
+	base   int          // Absolute index of buf[0]
+	strobe float64      // Absolute sample time of the next symbol
+	rate   float64      // Integral path: relative symbol period correction
+
+	prev, prevDecision complex128
+	power              float64 // Smoothed strobe power, for detector normalisation
+	midPower           float64 // Smoothed midpoint power
+	errorAvg           float64
+	rateAvg            float64 // Smoothed rate, for reporting
+	symbols            int
+
+	eye     []complex128 // Ring buffer of recent strobe samples
+	eyeNext int
+}
+
+// NewSymbolSync computes the loop gains and validates the configuration.
+func NewSymbolSync(config TimingConfig) (*SymbolSync, error) {
+	if config.SamplesPerSymbol < 2 {
+		return nil, fmt.Errorf("samples per symbol must be at least 2, got %v", config.SamplesPerSymbol)
+	}
+	if config.LoopBandwidth == 0 {
+		config.LoopBandwidth = 0.01
+	}
+	if config.LoopBandwidth < 0 || config.LoopBandwidth >= 0.25 {
+		return nil, fmt.Errorf("timing loop bandwidth %v must be in (0, 0.25) of the symbol rate", config.LoopBandwidth)
+	}
+	if config.Damping <= 0 {
+		config.Damping = 1 / math.Sqrt2
+	}
+	if config.LockThreshold <= 0 {
+		config.LockThreshold = 0.1
+	}
+	if config.EyeWindow <= 0 {
+		config.EyeWindow = 256
+	}
+	if config.ReportEvery <= 0 {
+		config.ReportEvery = 100
+	}
+	k1, k2 := loopGains(config.LoopBandwidth, config.Damping)
+	return &SymbolSync{
+		config: config,
+		k1:     k1,
+		k2:     k2,
+		strobe: config.SamplesPerSymbol, // Leave room for the first midpoint
+		eye:    make([]complex128, 0, config.EyeWindow),
+	}, nil
+}
+
+// Process consumes input samples and returns the symbols completed so far with periodic status.
+// Samples may be passed in blocks of any size.
+func (s *SymbolSync) Process(samples []complex128) ([]complex128, []TimingStatus) {
+	s.buf = append(s.buf, samples...)
+	var symbols []complex128
+	var reports []TimingStatus
+	for int(s.strobe)+2 < s.base+len(s.buf) {
+		symbols = append(symbols, s.nextSymbol())
+		if s.symbols%s.config.ReportEvery == 0 {
+			reports = append(reports, s.Status())
+		}
+	}
+	// Keep enough history for the next midpoint interpolation.
+	if drop := int(s.strobe-s.config.SamplesPerSymbol) - 2 - s.base; drop > 0 {
+		s.buf = append(s.buf[:0], s.buf[drop:]...)
+		s.base += drop
+	}
+	return symbols, reports
+}
+
+// nextSymbol interpolates the strobe and midpoint samples, runs the detector and advances
+// the strobe by one corrected symbol period.
+func (s *SymbolSync) nextSymbol() complex128 {
+	period := s.config.SamplesPerSymbol * (1 - s.rate)
+	y := s.interpolate(s.strobe)
+	mid := s.interpolate(s.strobe - period/2)
+	decision := s.config.Modulation.Decide(y)
+
+	p := real(y)*real(y) + imag(y)*imag(y)
+	pm := real(mid)*real(mid) + imag(mid)*imag(mid)
+	if s.symbols == 0 {
+		s.power, s.midPower = p, pm
+	} else {
+		s.power += 0.01 * (p - s.power)
+		s.midPower += 0.01 * (pm - s.midPower)
+	}
+
+	// Both detectors are scaled to unit gain per symbol of timing error, positive when late.
+	var e float64
+	if s.symbols > 0 && s.power > 0 {
+		switch s.config.Detector {
+		case TimingMuellerMuller:
+			mm := real(cmplx.Conj(s.prevDecision)*y - cmplx.Conj(decision)*s.prev)
+			e = -mm / math.Sqrt(s.power)
+		default:
+			e = real(cmplx.Conj(mid)*(y-s.prev)) / (2 * s.power)
+		}
+		e = math.Max(-1, math.Min(1, e))
+	}
+	s.rate += s.k2 * e
+	s.strobe += s.config.SamplesPerSymbol * (1 - s.rate - s.k1*e)
+	s.errorAvg += 0.01 * (e - s.errorAvg)
+	s.rateAvg += 0.01 * (s.rate - s.rateAvg)
+
+	s.prev, s.prevDecision = y, decision
+	if len(s.eye) < cap(s.eye) {
+		s.eye = append(s.eye, y)
+	} else {
+		s.eye[s.eyeNext] = y
+		s.eyeNext = (s.eyeNext + 1) % len(s.eye)
+	}
+	s.symbols++
+	return y
+}
+
+// interpolate evaluates the input at absolute sample time t.
+func (s *SymbolSync) interpolate(t float64) complex128 {
+	i := int(math.Floor(t)) - s.base
+	mu := t - math.Floor(t)
+	at := func(k int) complex128 {
+		if k < 0 || k >= len(s.buf) {
+			return 0
+		}
+		return s.buf[k]
+	}
+	return farrowCubic(at(i-1), at(i), at(i+1), at(i+2), mu)
+}
+
+// farrowCubic is a cubic Lagrange interpolator in Farrow form: it returns the value at
+// fractional position mu ∈ [0, 1) between x0 and x1.
+func farrowCubic(xm1, x0, x1, x2 complex128, mu float64) complex128 {
+	v3 := (x2-xm1)/6 + (x0-x1)/2
+	v2 := (xm1+x1)/2 - x0
+	v1 := -xm1/3 - x0/2 + x1 - x2/6
+	m := complex(mu, 0)
+	return ((v3*m+v2)*m+v1)*m + x0
+}
+
+// Status returns the current timing state and eye metrics.
+func (s *SymbolSync) Status() TimingStatus {
+	indicator := 0.0
+	if total := s.power + s.midPower; total > 0 {
+		indicator = (s.power - s.midPower) / total
+	}
+	return TimingStatus{
+		Symbol:           s.symbols,
+		SamplesPerSymbol: s.config.SamplesPerSymbol * (1 - s.rateAvg),
+		TimingError:      s.errorAvg,
+		LockIndicator:    indicator,
+		Locked:           indicator > s.config.LockThreshold,
+		Eye:              s.eyeMetrics(),
+	}
+}
+
+// eyeMetrics measures the eye over the buffered strobe samples. Each decision axis (I, and Q
+// for QPSK) contributes the magnitude of its component.
+func (s *SymbolSync) eyeMetrics() EyeMetrics {
+	if len(s.eye) == 0 {
+		return EyeMetrics{}
+	}
+	var axis []float64
+	for _, y := range s.eye {
+		axis = append(axis, math.Abs(real(y)))
+		if s.config.Modulation == ModulationQPSK {
+			axis = append(axis, math.Abs(imag(y)))
+		}
+	}
+	var mean, minimum = 0.0, math.Inf(1)
+	for _, v := range axis {
+		mean += v / float64(len(axis))
+		minimum = math.Min(minimum, v)
+	}
+	var variance float64
+	for _, v := range axis {
+		variance += (v - mean) * (v - mean) / float64(len(axis))
+	}
+	if mean == 0 {
+		return EyeMetrics{Symbols: len(s.eye)}
+	}
+
+	amplitude := mean
+	if s.config.Modulation == ModulationQPSK {
+		amplitude *= math.Sqrt2
+	}
+	var errPower float64
+	for _, y := range s.eye {
+		d := y - s.config.Modulation.Decide(y)*complex(amplitude, 0)
+		errPower += real(d)*real(d) + imag(d)*imag(d)
+	}
+	return EyeMetrics{
+		Opening:    (mean - 3*math.Sqrt(variance)) / mean,
+		MinOpening: minimum / mean,
+		EVM:        math.Sqrt(errPower/float64(len(s.eye))) / amplitude,
+		Symbols:    len(s.eye),
+	}
+}
+
+// DemodResult summarises an end-to-end demodulation of one signal.
+type DemodResult struct {
+	SignalID string
+	Carrier  LoopStatus   // Final carrier loop state
+	Timing   TimingStatus // Final timing state
+	History  []TimingStatus
+	Symbols  []complex128 // Carrier- and timing-corrected symbols
+	Bits     []int        // Hard decisions; subject to the carrier loop's phase ambiguity
+}
+
+// DemodulateSignal recovers the carrier of the signal's IQ samples with RecoverCarrier, then
+// symbol timing, and returns the symbols and hard-decision bits.
+func (cs *CarrierSync) DemodulateSignal(signalID string, samples []complex128, loop LoopConfig, timing TimingConfig) (DemodResult, error) {
+	symbolSync, err := NewSymbolSync(timing)
+	if err != nil {
+		return DemodResult{}, err
+	}
+	carrier, err := cs.RecoverCarrier(signalID, samples, loop)
+	if err != nil {
+		return DemodResult{}, err
+	}
+	symbols, history := symbolSync.Process(carrier.Recovered)
+	return DemodResult{
+		SignalID: signalID,
+		Carrier:  carrier.Final,
+		Timing:   symbolSync.Status(),
+		History:  history,
+		Symbols:  symbols,
+		Bits:     timing.Modulation.Demap(symbols),
+	}, nil
+}
@@ -0,0 +1,117 @@
+package communication
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+// triangularPulseStream modulates symbols with triangular pulses (rectangular pulses after
+// their matched filter) delayed by delay symbols, on a carrier at offsetHz, with white noise.
+func triangularPulseStream(rng *rand.Rand, symbols []complex128, sampleRate, symbolRate, delay, offsetHz, phase, snrDB float64) []complex128 {
+	sigma := math.Sqrt(1 / (2 * math.Pow(10, snrDB/10)))
+	n := int(float64(len(symbols)) * sampleRate / symbolRate)
+	samples := make([]complex128, n)
+	for i := range samples {
+		t := float64(i)*symbolRate/sampleRate - delay // In symbols
+		k := int(math.Floor(t))
+		frac := t - float64(k)
+		var x complex128
+		if k >= 0 && k < len(symbols) {
+			x += symbols[k] * complex(1-frac, 0)
+		}
+		if k+1 >= 0 && k+1 < len(symbols) {
+			x += symbols[k+1] * complex(frac, 0)
+		}
+		carrier := cmplx.Rect(1, 2*math.Pi*offsetHz*float64(i)/sampleRate+phase)
+		samples[i] = x*carrier + complex(rng.NormFloat64()*sigma, rng.NormFloat64()*sigma)
+	}
+	return samples
+}
+
+// bestAlignment compares received symbols with the sent ones after skipping settle symbols,
+// trying each phase rotation the carrier loop may have locked to and a small range of delays,
+// and returns the bit errors and bits compared for the best alignment.
+func bestAlignment(mod Modulation, sent, received []complex128, settle int) (errors, compared int) {
+	rotations := 2
+	if mod == ModulationQPSK {
+		rotations = 4
+	}
+	best := -1
+	for r := 0; r < rotations; r++ {
+		rot := cmplx.Rect(1, 2*math.Pi*float64(r)/float64(rotations))
+		for lag := -3; lag <= 3; lag++ {
+			errs, bits := 0, 0
+			for k := settle; k < len(received)-settle; k++ {
+				j := k + lag
+				if j < 0 || j >= len(sent) {
+					continue
+				}
+				a := mod.Demap([]complex128{received[k] * rot})
+				b := mod.Demap([]complex128{sent[j]})
+				for i := range a {
+					errs += boolBit(a[i] != b[i])
+				}
+				bits += len(a)
+			}
+			if bits > 0 && (best < 0 || errs < errors) {
+				best, errors, compared = 1, errs, bits
+			}
+		}
+	}
+	return errors, compared
+}
+
+// TestSymbolTiming demodulates synthetic BPSK and QPSK streams with a carrier offset, a
+// fractional timing offset and a 200 ppm symbol clock error, using each timing detector,
+// and counts bit errors after the loops settle.
+func TestSymbolTiming(t *testing.T) {
+	const (
+		sampleRate = 40e3
+		symbolRate = 10e3 * (1 + 200e-6)
+		offsetHz   = 120.0
+		delay      = 0.37 // Symbols
+		snrDB      = 15.0
+		symbols    = 20000
+		settle     = 2500 // Symbols ignored while the loops converge
+	)
+	rng := rand.New(rand.NewSource(39))
+
+	for _, mod := range []Modulation{ModulationBPSK, ModulationQPSK} {
+		for _, detector := range []TimingDetector{TimingGardner, TimingMuellerMuller} {
+			sent := make([]complex128, symbols)
+			for k := range sent {
+				sent[k] = mod.Decide(complex(rng.NormFloat64(), rng.NormFloat64()))
+			}
+			samples := triangularPulseStream(rng, sent, sampleRate, symbolRate, delay, offsetHz, 0.9, snrDB)
+
+			loopType := LoopCostasBPSK
+			if mod == ModulationQPSK {
+				loopType = LoopCostasQPSK
+			}
+			cs := NewCarrierSync(50)
+			cs.AddSignal(CarrierSignal{SignalID: "demod"})
+			result, err := cs.DemodulateSignal("demod", samples,
+				LoopConfig{Type: loopType, SampleRate: sampleRate, LoopBandwidth: 200},
+				TimingConfig{Detector: detector, Modulation: mod, SamplesPerSymbol: sampleRate / 10e3})
+			if err != nil {
+				t.Errorf("%v %v: %v", mod, detector, err)
+				continue
+			}
+
+			errors, compared := bestAlignment(mod, sent, result.Symbols, settle)
+			ber := float64(errors) / math.Max(1, float64(compared))
+			if !result.Timing.Locked {
+				t.Errorf("%v %v: timing loop not locked (lock %.2f)", mod, detector, result.Timing.LockIndicator)
+			}
+			if want := (symbols - 2*settle - 3) * mod.BitsPerSymbol(); compared < want {
+				t.Errorf("%v %v: compared %d bits, want at least %d", mod, detector, compared, want)
+			}
+			if ber > 1e-3 {
+				t.Errorf("%v %v: BER %.1e over %d bits, %.4f samples/symbol (true %.4f)",
+					mod, detector, ber, compared, result.Timing.SamplesPerSymbol, sampleRate/symbolRate)
+			}
+		}
+	}
+}
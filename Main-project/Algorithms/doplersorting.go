@@ -10,6 +10,13 @@ import (
 	"time"
 )
 
+// maxErrorLog is how many validation findings the sorter keeps; older ones are dropped first.
+const maxErrorLog = 10000
+
+// maxPending is how many samples AddData queues for ValidateData. A full queue is validated
+// on the next AddData and its findings go to the error log only.
+const maxPending = 10000
+
 // DopplerData represents a single data packet with its frequency and velocity.
 type DopplerData struct {
 	Frequency      float64
@@ -22,8 +29,11 @@ type DopplerData struct {
 // DopplerSorter contains methods for sorting Doppler data.
 type DopplerSorter struct {
 	data      []DopplerData
+	pending   []DopplerData // Samples added since the last ValidateData, in arrival order
 	mutex     sync.Mutex
-	errorLog  []string
+	errorLog  []ValidationError // The newest maxErrorLog findings
+	validator *Validator
+	retention *retainer[DopplerData]
 	tolerance float64
 }
 
@@ -31,15 +41,24 @@ type DopplerSorter struct {
 func NewDopplerSorter(tolerance float64) *DopplerSorter {
 	return &DopplerSorter{
 		data:      []DopplerData{},
+		validator: NewValidator(DefaultValidationRules()...),
 		tolerance: tolerance,
 	}
 }
 
-// AddData adds a new DopplerData entry to the sorter.
+// AddData adds a new DopplerData entry to the sorter, applying the retention policy when due.
 func (ds *DopplerSorter) AddData(data DopplerData) {
 	ds.mutex.Lock()
-	defer ds.mutex.Unlock()
+	if len(ds.pending) >= maxPending {
+		ds.validatePendingLocked()
+	}
 	ds.data = append(ds.data, data)
+	ds.pending = append(ds.pending, data)
+	if ds.retention != nil && ds.retention.due(data.Timestamp, len(ds.data)) {
+		ds.applyRetentionLocked()
+		return
+	}
+	ds.mutex.Unlock()
 }
 
 // SortData sorts Doppler data based on frequency and velocity.
@@ -55,26 +74,85 @@ func (ds *DopplerSorter) SortData() {
 	})
 }
 
-// ValidateData checks for anomalies in the Doppler data and logs errors if found.
-func (ds *DopplerSorter) ValidateData() {
+// SetValidationRules replaces the rules applied by ValidateData.
+// Samples that were already validated are not checked again.
+func (ds *DopplerSorter) SetValidationRules(rules ...ValidationRule) {
 	ds.mutex.Lock()
 	defer ds.mutex.Unlock()
+	ds.validator.rules = rules
+}
 
-	for _, entry := range ds.data {
-		if entry.Frequency <= 0 {
-			ds.errorLog = append(ds.errorLog, fmt.Sprintf("Invalid frequency for data ID %s at %v", entry.ID, entry.Timestamp))
-		}
-		if entry.SignalStrength < 0 {
-			ds.errorLog = append(ds.errorLog, fmt.Sprintf("Negative signal strength for data ID %s at %v", entry.ID, entry.Timestamp))
+// ValidateData checks samples added since the last call against the validation rules.
+// Each sample is validated once; the findings are returned and appended to the error log.
+func (ds *DopplerSorter) ValidateData() []ValidationError {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	return ds.validatePendingLocked()
+}
+
+// validatePendingLocked validates the pending queue, empties it and logs the findings.
+func (ds *DopplerSorter) validatePendingLocked() []ValidationError {
+	var findings []ValidationError
+	for _, entry := range ds.pending {
+		findings = append(findings, ds.validator.Validate(entry)...)
+	}
+	ds.pending = ds.pending[:0]
+	ds.errorLog = append(ds.errorLog, findings...)
+	if excess := len(ds.errorLog) - maxErrorLog; excess > 0 {
+		ds.errorLog = append(ds.errorLog[:0], ds.errorLog[excess:]...)
+	}
+	return findings
+}
+
+// dropPendingLocked removes evicted samples that were never validated from the pending queue.
+func (ds *DopplerSorter) dropPendingLocked(evicted []DopplerData) {
+	if len(ds.pending) == 0 {
+		return
+	}
+	remaining := make(map[dopplerKey]int, len(evicted))
+	for _, entry := range evicted {
+		remaining[keyOf(entry)]++
+	}
+	kept := ds.pending[:0]
+	for _, entry := range ds.pending {
+		if key := keyOf(entry); remaining[key] > 0 {
+			remaining[key]--
+			continue
 		}
+		kept = append(kept, entry)
 	}
+	ds.pending = kept
+}
+
+// dopplerKey identifies a sample by the bits of its fields, so samples holding NaN still
+// match their own copies.
+type dopplerKey struct {
+	frequency, velocity, strength uint64
+	timestamp                     time.Time
+	id                            string
+}
+
+func keyOf(d DopplerData) dopplerKey {
+	return dopplerKey{math.Float64bits(d.Frequency), math.Float64bits(d.Velocity), math.Float64bits(d.SignalStrength), d.Timestamp, d.ID}
 }
 
-// GetErrorLog returns the error log for inspection.
+// GetErrorLog returns the error log for inspection, oldest finding first.
 func (ds *DopplerSorter) GetErrorLog() []string {
 	ds.mutex.Lock()
 	defer ds.mutex.Unlock()
-	return ds.errorLog
+
+	lines := make([]string, len(ds.errorLog))
+	for i, ve := range ds.errorLog {
+		lines[i] = ve.Error()
+	}
+	return lines
+}
+
+// GetValidationErrors returns a copy of the typed validation findings.
+func (ds *DopplerSorter) GetValidationErrors() []ValidationError {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	return append([]ValidationError(nil), ds.errorLog...)
 }
 
 // SimulateRealTimeData generates mock Doppler data in real-time.
@@ -93,8 +171,8 @@ func DopplerEffect(originalFreq, velocity, speedOfLight float64) float64 {
 
 // GenerateMockData creates mock DopplerData for testing.
 func GenerateMockData() DopplerData {
-	velocity := randomFloat(-5000, 5000)             // in m/s
-	frequency := DopplerEffect(2.4e9, velocity, 3e8) // 2.4 GHz base frequency
+	velocity := randomFloat(-5000, 5000)                      // in m/s
+	frequency := DopplerEffect(2.4e9, velocity, SpeedOfLight) // 2.4 GHz base frequency
 	signalStrength := randomFloat(0, 1)
 	return DopplerData{
 		Frequency:      frequency,
@@ -166,7 +244,9 @@ func (ds *DopplerSorter) ClearData() {
 	ds.mutex.Lock()
 	defer ds.mutex.Unlock()
 	ds.data = []DopplerData{}
-	ds.errorLog = []string{}
+	ds.pending = nil
+	ds.errorLog = nil
+	ds.validator.Reset()
 }
 
 // ProcessRealTimeSorting simulates continuous data processing with sorting and validation.
@@ -203,20 +283,17 @@ func (ds *DopplerSorter) SaveErrorLog(filename string) error {
 	}
 	defer file.Close()
 
-	for _, log := range ds.errorLog {
-		file.WriteString(log + "\n")
+	for _, ve := range ds.errorLog {
+		file.WriteString(ve.Error() + "\n")
 	}
 	return nil
 }
 
-// AdvancedSorting applies dynamic sorting based on environmental parameters.
-func (ds *DopplerSorter) AdvancedSorting(environmentalFactor float64) {
-	ds.mutex.Lock()
-	defer ds.mutex.Unlock()
-
-	sort.SliceStable(ds.data, func(i, j int) bool {
-		scoreI := ds.data[i].Frequency*environmentalFactor - ds.data[i].Velocity
-		scoreJ := ds.data[j].Frequency*environmentalFactor - ds.data[j].Velocity
-		return scoreI > scoreJ
-	})
+// AdvancedSorting sorts by environment-corrected frequency, highest first, treating
+// frequencies within the sorter tolerance as equal and ordering those by velocity.
+func (ds *DopplerSorter) AdvancedSorting(env EnvironmentalConditions) {
+	ds.SortWith(MultiKeySort{Keys: []KeyOrder{
+		{Key: CorrectedFrequencyKey(env), Descending: true, Tolerance: ds.tolerance},
+		{Key: KeyVelocity},
+	}})
 }
@@ -0,0 +1,58 @@
+package communication
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// TestPendingEviction checks that evicted samples leave the validation queue, including
+// samples holding NaN, which never compare equal to themselves.
+func TestPendingEviction(t *testing.T) {
+	ds := NewDopplerSorter(10)
+	ds.SetRetentionPolicy(RetentionPolicy{MaxCount: 5}, nil)
+	start := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 100; i++ {
+		ds.AddData(DopplerData{Frequency: math.NaN(), Timestamp: start.Add(time.Duration(i) * time.Second), ID: fmt.Sprint(i)})
+	}
+	if len(ds.pending) != 5 {
+		t.Errorf("%d samples pending validation, want the 5 retained", len(ds.pending))
+	}
+}
+
+// TestPendingCap checks that the validation queue is bounded without a retention policy and
+// that every sample is still validated once.
+func TestPendingCap(t *testing.T) {
+	ds := NewDopplerSorter(10)
+	ds.SetValidationRules(DuplicateIDRule{})
+	for i := 0; i < 3*maxPending; i++ {
+		ds.AddData(DopplerData{ID: fmt.Sprint(i % (2 * maxPending))})
+		if len(ds.pending) > maxPending {
+			t.Fatalf("%d samples pending validation after %d added", len(ds.pending), i+1)
+		}
+	}
+	ds.ValidateData()
+	if got := len(ds.GetValidationErrors()); got != maxPending {
+		t.Errorf("%d duplicate IDs found, want %d", got, maxPending)
+	}
+}
+
+// TestSeenIDsBounded checks that the duplicate check forgets the oldest IDs beyond maxSeenIDs,
+// including after IDs are forgotten and seen again.
+func TestSeenIDsBounded(t *testing.T) {
+	v := NewValidator(DuplicateIDRule{})
+	for i := 0; i < 3*maxSeenIDs; i++ {
+		id := fmt.Sprint(i)
+		v.Validate(DopplerData{ID: id})
+		if i%2 == 0 {
+			v.Forget(id)
+		}
+	}
+	if len(v.state.seenIDs) > maxSeenIDs || len(v.state.seenOrder) > 2*maxSeenIDs+64 {
+		t.Errorf("remembering %d IDs in an order of %d", len(v.state.seenIDs), len(v.state.seenOrder))
+	}
+	if !v.state.Seen(fmt.Sprint(3*maxSeenIDs-1)) || v.state.Seen("1") {
+		t.Errorf("duplicate check should remember the newest IDs and forget the oldest")
+	}
+}
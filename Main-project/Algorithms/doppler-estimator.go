@@ -0,0 +1,244 @@
+package communication
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// InterpolationMethod selects how the FFT peak is refined between bins.
+type InterpolationMethod int
+
+const (
+	// InterpolationParabolic fits a parabola to the log-magnitude of the peak and its neighbours.
+	// It works with any window and zero-padding factor.
+	InterpolationParabolic InterpolationMethod = iota
+	// InterpolationQuinn uses Quinn's second estimator on the complex bins. It assumes a
+	// rectangular window without zero padding and approaches the CRLB at moderate SNR.
+	InterpolationQuinn
+)
+
+// FrequencyEstimatorConfig configures a FrequencyEstimator.
+type FrequencyEstimatorConfig struct {
+	SampleRate      float64 // Complex sample rate in Hz
+	CenterFrequency float64 // RF frequency the baseband samples were mixed down from, in Hz
+	RestFrequency   float64 // Carrier rest frequency used to derive Velocity; 0 leaves Velocity unset
+	Window          WindowType
+	ZeroPadFactor   int // FFT length is the next power of two of len(iq)*ZeroPadFactor
+	Interpolation   InterpolationMethod
+}
+
+// FrequencyEstimate is the result of one FFT frequency estimate.
+type FrequencyEstimate struct {
+	Offset     float64 // Carrier offset from CenterFrequency, in Hz
+	Frequency  float64 // Absolute carrier frequency, in Hz
+	SNR        float64 // Per-sample signal-to-noise ratio, linear
+	SNRdB      float64
+	NoiseFloor float64 // Mean noise power per FFT bin
+	FFTSize    int
+}
+
+// FrequencyEstimator estimates the carrier frequency of complex baseband IQ blocks.
+type FrequencyEstimator struct {
+	config FrequencyEstimatorConfig
+}
+
+// NewFrequencyEstimator validates the configuration and creates an estimator.
+func NewFrequencyEstimator(config FrequencyEstimatorConfig) (*FrequencyEstimator, error) {
+	if config.SampleRate <= 0 {
+		return nil, fmt.Errorf("sample rate must be positive, got %v", config.SampleRate)
+	}
+	if config.ZeroPadFactor < 1 {
+		config.ZeroPadFactor = 1
+	}
+	if config.Interpolation == InterpolationQuinn && (config.Window != WindowRectangular || config.ZeroPadFactor != 1) {
+		return nil, errors.New("Quinn interpolation requires a rectangular window and no zero padding")
+	}
+	return &FrequencyEstimator{config: config}, nil
+}
+
+// Estimate windows, zero-pads and transforms the IQ block and returns the refined peak frequency.
+func (fe *FrequencyEstimator) Estimate(iq []complex128) (FrequencyEstimate, error) {
+	n := len(iq)
+	if n < 4 {
+		return FrequencyEstimate{}, fmt.Errorf("need at least 4 IQ samples, got %d", n)
+	}
+	size := nextPowerOfTwo(n * fe.config.ZeroPadFactor)
+	if fe.config.Interpolation == InterpolationQuinn && size != n {
+		return FrequencyEstimate{}, fmt.Errorf("Quinn interpolation needs a power-of-two block, got %d samples", n)
+	}
+
+	window := Window(fe.config.Window, n)
+	buf := make([]complex128, size)
+	for i, s := range iq {
+		buf[i] = s * complex(window[i], 0)
+	}
+	if err := fftInPlace(buf, false); err != nil {
+		return FrequencyEstimate{}, err
+	}
+
+	power := make([]float64, size)
+	peak := 0
+	for i, x := range buf {
+		power[i] = real(x)*real(x) + imag(x)*imag(x)
+		if power[i] > power[peak] {
+			peak = i
+		}
+	}
+
+	var delta float64
+	switch fe.config.Interpolation {
+	case InterpolationQuinn:
+		delta = quinnDelta(buf, peak)
+	default:
+		delta = parabolicDelta(power, peak)
+	}
+
+	bin := float64(peak) + delta
+	if bin >= float64(size)/2 {
+		bin -= float64(size)
+	}
+	offset := bin * fe.config.SampleRate / float64(size)
+
+	snr, noise := spectralSNR(power, peak, fe.config.Window.mainLobeHalfWidth()*size/n+4)
+	return FrequencyEstimate{
+		Offset:     offset,
+		Frequency:  fe.config.CenterFrequency + offset,
+		SNR:        snr,
+		SNRdB:      10 * math.Log10(snr),
+		NoiseFloor: noise,
+		FFTSize:    size,
+	}, nil
+}
+
+// ToDopplerData converts an estimate into a DopplerData record. SignalStrength is the
+// fraction of received power in the carrier, SNR/(1+SNR), so it stays within [0, 1] and
+// is 1 only for a noiseless carrier.
+func (fe *FrequencyEstimator) ToDopplerData(estimate FrequencyEstimate, timestamp time.Time) DopplerData {
+	var velocity float64
+	if fe.config.RestFrequency > 0 {
+		// Inverse of DopplerEffect
+		velocity = SpeedOfLight * (estimate.Frequency/fe.config.RestFrequency - 1)
+	}
+	strength := 1.0 // Infinite SNR would make SNR/(1+SNR) NaN
+	if !math.IsInf(estimate.SNR, 1) {
+		strength = estimate.SNR / (1 + estimate.SNR)
+	}
+	return DopplerData{
+		Frequency:      estimate.Frequency,
+		Velocity:       velocity,
+		SignalStrength: strength,
+		Timestamp:      timestamp,
+		ID:             randomString(10),
+	}
+}
+
+// ProcessBlock estimates the carrier of one IQ block and adds the resulting record to the sorter.
+func (fe *FrequencyEstimator) ProcessBlock(iq []complex128, timestamp time.Time, ds *DopplerSorter) (DopplerData, error) {
+	estimate, err := fe.Estimate(iq)
+	if err != nil {
+		return DopplerData{}, err
+	}
+	data := fe.ToDopplerData(estimate, timestamp)
+	ds.AddData(data)
+	return data, nil
+}
+
+// parabolicDelta fits a parabola through the log-power of the peak and its neighbours.
+func parabolicDelta(power []float64, peak int) float64 {
+	n := len(power)
+	a := math.Log(power[(peak-1+n)%n] + 1e-300)
+	b := math.Log(power[peak] + 1e-300)
+	c := math.Log(power[(peak+1)%n] + 1e-300)
+	denom := a - 2*b + c
+	if denom == 0 {
+		return 0
+	}
+	return 0.5 * (a - c) / denom
+}
+
+// quinnDelta implements Quinn's second estimator.
+func quinnDelta(spectrum []complex128, peak int) float64 {
+	n := len(spectrum)
+	center := spectrum[peak]
+	if center == 0 {
+		return 0
+	}
+	ap := real(spectrum[(peak+1)%n] / center)
+	am := real(spectrum[(peak-1+n)%n] / center)
+	dp := -ap / (1 - ap)
+	dm := am / (1 - am)
+	return (dp+dm)/2 + quinnTau(dp*dp) - quinnTau(dm*dm)
+}
+
+func quinnTau(x float64) float64 {
+	const r = 0.816496580927726 // sqrt(2/3)
+	return 0.25*math.Log(3*x*x+6*x+1) - math.Sqrt(6)/24*math.Log((x+1-r)/(x+1+r))
+}
+
+// spectralSNR estimates the per-sample SNR from a power spectrum. The noise floor is the
+// median bin power outside the signal lobe scaled to a mean (noise bins are exponentially
+// distributed), and the signal power is the lobe energy above that floor. A zero noise
+// floor gives an infinite SNR, or zero when there is no signal either.
+func spectralSNR(power []float64, peak, halfWidth int) (snr, noiseFloor float64) {
+	n := len(power)
+	if 2*halfWidth+1 >= n {
+		halfWidth = (n - 2) / 2
+	}
+	inLobe := func(i int) bool {
+		d := i - peak
+		if d < 0 {
+			d = -d
+		}
+		if n-d < d {
+			d = n - d
+		}
+		return d <= halfWidth
+	}
+
+	var lobe float64
+	outside := make([]float64, 0, n)
+	for i, p := range power {
+		if inLobe(i) {
+			lobe += p
+		} else {
+			outside = append(outside, p)
+		}
+	}
+	sort.Float64s(outside)
+	noiseFloor = outside[len(outside)/2] / math.Ln2
+
+	signal := lobe - noiseFloor*float64(2*halfWidth+1)
+	if signal < 0 {
+		signal = 0
+	}
+	if noiseFloor == 0 {
+		if signal == 0 {
+			return 0, 0
+		}
+		return math.Inf(1), 0
+	}
+	return signal / (noiseFloor * float64(n)), noiseFloor
+}
+
+// FrequencyCRLB returns the Cramér-Rao lower bound on the standard deviation, in Hz, of any
+// unbiased frequency estimate from n samples of a complex tone with linear per-sample SNR.
+func FrequencyCRLB(sampleRate, snr float64, n int) float64 {
+	nf := float64(n)
+	variance := 6 / (snr * nf * (nf*nf - 1)) / (4 * math.Pi * math.Pi)
+	return sampleRate * math.Sqrt(variance)
+}
+
+// String returns the interpolation method name.
+func (m InterpolationMethod) String() string {
+	switch m {
+	case InterpolationParabolic:
+		return "parabolic"
+	case InterpolationQuinn:
+		return "quinn"
+	default:
+		return fmt.Sprintf("InterpolationMethod(%d)", int(m))
+	}
+}
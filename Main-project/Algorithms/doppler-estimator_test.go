@@ -0,0 +1,101 @@
+package communication
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// syntheticTone returns n samples of a unit complex tone at normalized frequency f
+// in complex white Gaussian noise with the given linear SNR.
+func syntheticTone(rng *rand.Rand, n int, f, phase, snr float64) []complex128 {
+	sigma := math.Sqrt(1 / (2 * snr))
+	iq := make([]complex128, n)
+	for i := range iq {
+		iq[i] = cmplx.Rect(1, 2*math.Pi*f*float64(i)+phase) +
+			complex(rng.NormFloat64()*sigma, rng.NormFloat64()*sigma)
+	}
+	return iq
+}
+
+// TestFrequencyEstimator compares the estimator's RMS error with the CRLB on synthetic tones.
+func TestFrequencyEstimator(t *testing.T) {
+	const (
+		sampleRate = 100e3
+		n          = 1024
+		trials     = 200
+	)
+	rng := rand.New(rand.NewSource(1))
+	configs := []FrequencyEstimatorConfig{
+		{SampleRate: sampleRate, Window: WindowRectangular, ZeroPadFactor: 1, Interpolation: InterpolationQuinn},
+		{SampleRate: sampleRate, Window: WindowHann, ZeroPadFactor: 4, Interpolation: InterpolationParabolic},
+	}
+	for _, snrDB := range []float64{0, 10, 20} {
+		snr := math.Pow(10, snrDB/10)
+		crlb := FrequencyCRLB(sampleRate, snr, n)
+		for _, config := range configs {
+			fe, err := NewFrequencyEstimator(config)
+			if err != nil {
+				t.Fatalf("%v: %v", config.Window, err)
+			}
+			var sumSq float64
+			for trial := 0; trial < trials; trial++ {
+				offset := (rng.Float64() - 0.5) * sampleRate / 4
+				iq := syntheticTone(rng, n, offset/sampleRate, rng.Float64()*2*math.Pi, snr)
+				est, err := fe.Estimate(iq)
+				if err != nil {
+					t.Fatalf("%s/%v SNR %.0f dB: %v", config.Window, config.Interpolation, snrDB, err)
+				}
+				sumSq += (est.Offset - offset) * (est.Offset - offset)
+			}
+			rmse := math.Sqrt(sumSq / trials)
+			// The parabolic fit on a padded Hann spectrum has a small interpolation bias,
+			// so it is held to a looser bound than Quinn's estimator.
+			limit := 1.5
+			if config.Interpolation == InterpolationParabolic {
+				limit = 3
+			}
+			if rmse > limit*crlb {
+				t.Errorf("%s/%v SNR %.0f dB: RMSE %.3f Hz exceeds %.1f × CRLB %.3f Hz",
+					config.Window, config.Interpolation, snrDB, rmse, limit, crlb)
+			}
+		}
+	}
+}
+
+// TestNoiselessEstimate checks that noiseless and empty blocks give finite signal strengths.
+func TestNoiselessEstimate(t *testing.T) {
+	fe, err := NewFrequencyEstimator(FrequencyEstimatorConfig{SampleRate: 100e3, Window: WindowRectangular, ZeroPadFactor: 1, Interpolation: InterpolationQuinn})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A noise floor of exactly zero gives an infinite SNR, or none without a signal
+	power := make([]float64, 64)
+	if snr, _ := spectralSNR(power, 10, 2); snr != 0 {
+		t.Errorf("empty spectrum: SNR %v, want 0", snr)
+	}
+	power[10] = 1
+	if snr, _ := spectralSNR(power, 10, 2); !math.IsInf(snr, 1) {
+		t.Errorf("noiseless spectrum: SNR %v, want +Inf", snr)
+	}
+	if got := fe.ToDopplerData(FrequencyEstimate{SNR: math.Inf(1)}, time.Time{}).SignalStrength; got != 1 {
+		t.Errorf("infinite SNR: signal strength %v, want 1", got)
+	}
+
+	tone := make([]complex128, 256)
+	for i := range tone {
+		tone[i] = cmplx.Rect(1, 2*math.Pi*16/256*float64(i))
+	}
+	for name, iq := range map[string][]complex128{"noiseless tone": tone, "silence": make([]complex128, 256)} {
+		estimate, err := fe.Estimate(iq)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := fe.ToDopplerData(estimate, time.Time{}).SignalStrength; math.IsNaN(got) || got < 0 || got > 1 {
+			t.Errorf("%s: signal strength %v (SNR %v), want a value in [0, 1]", name, got, estimate.SNR)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package communication
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// DopplerFitOptions configures FitDopplerCurve.
+type DopplerFitOptions struct {
+	PredictedTCA  time.Time // Time of closest approach predicted from the TLE; zero skips the timing comparison
+	MaxIterations int       // Levenberg-Marquardt iteration limit; 0 means 100
+}
+
+// DopplerFit holds the fitted pass parameters with their 1-sigma uncertainties.
+//
+// The model is the classic straight-line flyby: the spacecraft passes the station at constant
+// speed v with minimum range d at time TCA, so range r(t) = sqrt(d² + v²(t-TCA)²) and the
+// received frequency is f0·(1 - ṙ/c).
+type DopplerFit struct {
+	RestFrequency      float64 // f0, in Hz
+	RestFrequencySigma float64
+	TCA                time.Time // Time of closest approach
+	TCASigma           float64   // in seconds
+	MinRange           float64   // d, in m
+	MinRangeSigma      float64
+	Speed              float64 // v, in m/s
+	SpeedSigma         float64
+
+	TimingError     time.Duration // Fitted TCA minus predicted TCA; positive means the spacecraft is late
+	AlongTrackError float64       // TimingError·Speed, in m
+
+	Residuals  []float64 // Measured minus fitted frequency, in Hz, in timestamp order
+	RMS        float64   // RMS residual, in Hz
+	Iterations int
+	Converged  bool // An accepted step reduced the cost by less than fitTolerance; false if the fit stalled or hit MaxIterations
+}
+
+// fitTolerance is the relative cost reduction below which the fit has converged, the
+// square root of machine epsilon as in MINPACK.
+const fitTolerance = 1.49e-8
+
+// Fitted parameter indices.
+const (
+	fitRestFrequency = iota
+	fitTCA
+	fitMinRange
+	fitSpeed
+	fitParamCount
+)
+
+// FitPass fits the Doppler S-curve to all data currently held by the sorter.
+func (ds *DopplerSorter) FitPass(options DopplerFitOptions) (DopplerFit, error) {
+	ds.mutex.Lock()
+	snapshot := append([]DopplerData(nil), ds.data...)
+	ds.mutex.Unlock()
+	return FitDopplerCurve(snapshot, options)
+}
+
+// FitDopplerCurve estimates TCA, minimum range, speed and rest frequency from one pass
+// of Doppler measurements by Levenberg-Marquardt least squares.
+func FitDopplerCurve(data []DopplerData, options DopplerFitOptions) (DopplerFit, error) {
+	if len(data) <= fitParamCount {
+		return DopplerFit{}, fmt.Errorf("need more than %d samples to fit a pass, got %d", fitParamCount, len(data))
+	}
+	maxIterations := options.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = 100
+	}
+
+	samples := append([]DopplerData(nil), data...)
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Timestamp.Before(samples[j].Timestamp) })
+	epoch := samples[0].Timestamp
+	ts := make([]float64, len(samples))
+	fs := make([]float64, len(samples))
+	for i, s := range samples {
+		ts[i] = s.Timestamp.Sub(epoch).Seconds()
+		fs[i] = s.Frequency
+	}
+
+	params, err := initialPassGuess(ts, fs)
+	if err != nil {
+		return DopplerFit{}, err
+	}
+
+	n := len(ts)
+	residuals := make([]float64, n)
+	jacobian := make([][fitParamCount]float64, n)
+	cost := func(p [fitParamCount]float64) float64 {
+		var sum float64
+		for i, t := range ts {
+			r := fs[i] - sCurve(p, t, nil)
+			sum += r * r
+		}
+		return sum
+	}
+
+	lambda := 1e-3
+	current := cost(params)
+	fit := DopplerFit{}
+	for fit.Iterations = 1; fit.Iterations <= maxIterations; fit.Iterations++ {
+		var jtj [fitParamCount][fitParamCount]float64
+		var jtr [fitParamCount]float64
+		for i, t := range ts {
+			residuals[i] = fs[i] - sCurve(params, t, &jacobian[i])
+			for a := 0; a < fitParamCount; a++ {
+				jtr[a] += jacobian[i][a] * residuals[i]
+				for b := 0; b < fitParamCount; b++ {
+					jtj[a][b] += jacobian[i][a] * jacobian[i][b]
+				}
+			}
+		}
+
+		improved := false
+		for attempt := 0; attempt < 20 && !improved; attempt++ {
+			damped := jtj
+			for a := 0; a < fitParamCount; a++ {
+				damped[a][a] += lambda * jtj[a][a]
+			}
+			step, err := solveLinear(damped, jtr)
+			if err != nil {
+				lambda *= 10
+				continue
+			}
+			var trial [fitParamCount]float64
+			for a := range trial {
+				trial[a] = params[a] + step[a]
+			}
+			if trialCost := cost(trial); trialCost < current {
+				relative := (current - trialCost) / current
+				params, current = trial, trialCost
+				lambda /= 10
+				improved = true
+				if relative < fitTolerance {
+					fit.Converged = true
+				}
+			} else {
+				lambda *= 10
+			}
+		}
+		if !improved || fit.Converged {
+			// Without an improving step the fit has stalled; only the relative cost
+			// criterion above counts as convergence.
+			break
+		}
+	}
+
+	// Final residuals, Jacobian and covariance σ²·(JᵀJ)⁻¹ at the solution.
+	var jtj [fitParamCount][fitParamCount]float64
+	var rss float64
+	for i, t := range ts {
+		residuals[i] = fs[i] - sCurve(params, t, &jacobian[i])
+		rss += residuals[i] * residuals[i]
+		for a := 0; a < fitParamCount; a++ {
+			for b := 0; b < fitParamCount; b++ {
+				jtj[a][b] += jacobian[i][a] * jacobian[i][b]
+			}
+		}
+	}
+	covariance, err := invertMatrix(jtj)
+	if err != nil {
+		return DopplerFit{}, fmt.Errorf("pass geometry is degenerate: %w", err)
+	}
+	variance := rss / float64(n-fitParamCount)
+	sigma := func(k int) float64 { return math.Sqrt(variance * covariance[k][k]) }
+
+	fit.RestFrequency, fit.RestFrequencySigma = params[fitRestFrequency], sigma(fitRestFrequency)
+	fit.TCA, fit.TCASigma = epoch.Add(seconds(params[fitTCA])), sigma(fitTCA)
+	fit.MinRange, fit.MinRangeSigma = math.Abs(params[fitMinRange]), sigma(fitMinRange)
+	fit.Speed, fit.SpeedSigma = math.Abs(params[fitSpeed]), sigma(fitSpeed)
+	fit.Residuals = residuals
+	fit.RMS = math.Sqrt(rss / float64(n))
+	if fit.Iterations > maxIterations {
+		fit.Iterations = maxIterations
+	}
+	if !options.PredictedTCA.IsZero() {
+		fit.TimingError = fit.TCA.Sub(options.PredictedTCA)
+		fit.AlongTrackError = fit.TimingError.Seconds() * fit.Speed
+	}
+	return fit, nil
+}
+
+// sCurve evaluates the flyby model at time t (seconds from the first sample) and, when
+// jacobian is not nil, fills in the partial derivatives with respect to each parameter.
+func sCurve(p [fitParamCount]float64, t float64, jacobian *[fitParamCount]float64) float64 {
+	f0, tau, d, v := p[fitRestFrequency], t-p[fitTCA], p[fitMinRange], p[fitSpeed]
+	r := math.Sqrt(d*d + v*v*tau*tau)
+	if r == 0 {
+		r = math.SmallestNonzeroFloat64
+	}
+	rangeRate := v * v * tau / r
+	if jacobian != nil {
+		r3 := r * r * r
+		k := -f0 / SpeedOfLight
+		jacobian[fitRestFrequency] = 1 - rangeRate/SpeedOfLight
+		jacobian[fitTCA] = -k * v * v * d * d / r3
+		jacobian[fitMinRange] = -k * v * v * tau * d / r3
+		jacobian[fitSpeed] = k * v * tau * (2*d*d + v*v*tau*tau) / r3
+	}
+	return f0 * (1 - rangeRate/SpeedOfLight)
+}
+
+// initialPassGuess seeds the fit from the curve's midpoint, swing and steepest slope.
+func initialPassGuess(ts, fs []float64) ([fitParamCount]float64, error) {
+	fMin, fMax := fs[0], fs[0]
+	for _, f := range fs {
+		fMin = math.Min(fMin, f)
+		fMax = math.Max(fMax, f)
+	}
+	f0 := (fMin + fMax) / 2
+	if fMax == fMin {
+		return [fitParamCount]float64{}, errors.New("frequency is constant; no Doppler curve to fit")
+	}
+
+	// TCA is where the curve crosses its midpoint with the steepest negative slope.
+	tca, slope := ts[len(ts)/2], 0.0
+	for i := 1; i < len(ts); i++ {
+		dt := ts[i] - ts[i-1]
+		if dt <= 0 {
+			continue
+		}
+		s := (fs[i] - fs[i-1]) / dt
+		if s < slope {
+			tca, slope = (ts[i]+ts[i-1])/2, s
+		}
+	}
+	if slope == 0 {
+		return [fitParamCount]float64{}, errors.New("frequency never decreases; pass does not contain closest approach")
+	}
+
+	// Far from TCA the shift tends to ±f0·v/c, and at TCA the slope is -f0·v²/(c·d).
+	v := SpeedOfLight * (fMax - fMin) / (2 * f0)
+	d := f0 * v * v / (SpeedOfLight * -slope)
+	return [fitParamCount]float64{f0, tca, d, v}, nil
+}
+
+// solveLinear solves a·x = b by Gaussian elimination with partial pivoting.
+func solveLinear(a [fitParamCount][fitParamCount]float64, b [fitParamCount]float64) ([fitParamCount]float64, error) {
+	const n = fitParamCount
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if a[pivot][col] == 0 {
+			return b, errors.New("singular matrix")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+		for row := col + 1; row < n; row++ {
+			factor := a[row][col] / a[col][col]
+			for k := col; k < n; k++ {
+				a[row][k] -= factor * a[col][k]
+			}
+			b[row] -= factor * b[col]
+		}
+	}
+	var x [n]float64
+	for row := n - 1; row >= 0; row-- {
+		sum := b[row]
+		for k := row + 1; k < n; k++ {
+			sum -= a[row][k] * x[k]
+		}
+		x[row] = sum / a[row][row]
+	}
+	return x, nil
+}
+
+// invertMatrix inverts a by solving for each column of the identity.
+func invertMatrix(a [fitParamCount][fitParamCount]float64) ([fitParamCount][fitParamCount]float64, error) {
+	var inverse [fitParamCount][fitParamCount]float64
+	for col := 0; col < fitParamCount; col++ {
+		var e [fitParamCount]float64
+		e[col] = 1
+		x, err := solveLinear(a, e)
+		if err != nil {
+			return inverse, err
+		}
+		for row := range x {
+			inverse[row][col] = x[row]
+		}
+	}
+	return inverse, nil
+}
@@ -0,0 +1,76 @@
+package communication
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// TestDopplerCurveFit fits a synthetic LEO pass and checks the recovered parameters.
+func TestDopplerCurveFit(t *testing.T) {
+	const (
+		f0       = 2.2e9
+		minRange = 800e3
+		speed    = 7.3e3
+		noiseHz  = 5.0
+	)
+	rng := rand.New(rand.NewSource(7))
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tca := start.Add(5*time.Minute + 17*time.Second)
+	predicted := tca.Add(-2 * time.Second) // TLE running two seconds early
+
+	ds := NewDopplerSorter(1.0)
+	for i := 0; i < 600; i++ {
+		ts := start.Add(time.Duration(i) * time.Second)
+		tau := ts.Sub(tca).Seconds()
+		r := math.Hypot(minRange, speed*tau)
+		rangeRate := speed * speed * tau / r
+		ds.AddData(DopplerData{
+			Frequency:      DopplerEffect(f0, -rangeRate, SpeedOfLight) + rng.NormFloat64()*noiseHz,
+			Velocity:       -rangeRate,
+			SignalStrength: 1,
+			Timestamp:      ts,
+			ID:             randomString(10),
+		})
+	}
+
+	fit, err := ds.FitPass(DopplerFitOptions{PredictedTCA: predicted})
+	if err != nil {
+		t.Fatalf("FitPass: %v", err)
+	}
+	if !fit.Converged {
+		t.Errorf("fit did not converge after %d iterations", fit.Iterations)
+	}
+	check := func(name string, got, expected, sigma float64, unit string) {
+		if math.Abs(got-expected) > 4*sigma {
+			t.Errorf("%s: %.6g ± %.3g %s, true %.6g", name, got, sigma, unit, expected)
+		}
+	}
+	check("rest frequency", fit.RestFrequency, f0, fit.RestFrequencySigma, "Hz")
+	check("TCA offset", fit.TCA.Sub(tca).Seconds(), 0, fit.TCASigma, "s")
+	check("minimum range", fit.MinRange, minRange, fit.MinRangeSigma, "m")
+	check("speed", fit.Speed, speed, fit.SpeedSigma, "m/s")
+	check("timing error", fit.TimingError.Seconds(), 2, fit.TCASigma, "s")
+}
+
+// TestDopplerCurveFitNotConverged checks that a fit stopped early is not reported as converged.
+func TestDopplerCurveFitNotConverged(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var data []DopplerData
+	for i := 0; i < 300; i++ {
+		tau := float64(i - 150)
+		r := math.Hypot(800e3, 7.3e3*tau)
+		data = append(data, DopplerData{
+			Frequency: DopplerEffect(2.2e9, -7.3e3*7.3e3*tau/r, SpeedOfLight),
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+		})
+	}
+	fit, err := FitDopplerCurve(data, DopplerFitOptions{MaxIterations: 1})
+	if err != nil {
+		t.Fatalf("FitDopplerCurve: %v", err)
+	}
+	if fit.Converged {
+		t.Errorf("fit limited to one iteration reported convergence")
+	}
+}
@@ -0,0 +1,215 @@
+package communication
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// SpeedOfLight is the exact SI value of c in m/s.
+const SpeedOfLight = 299792458.0
+
+// Common coherent transponder turnaround ratios.
+const (
+	TurnaroundSBand  = 240.0 / 221.0
+	TurnaroundXBand  = 880.0 / 749.0
+	TurnaroundKaBand = 3344.0 / 749.0
+)
+
+// DopplerGeometry describes the motion along each leg of a Doppler link.
+// Velocities follow the DopplerEffect convention: positive means the spacecraft
+// and station are closing (range decreasing), in m/s.
+type DopplerGeometry struct {
+	UplinkVelocity   float64 // Radial closing speed on the station-to-spacecraft leg
+	DownlinkVelocity float64 // Radial closing speed on the spacecraft-to-station leg
+	SpacecraftSpeed  float64 // Total spacecraft speed for time dilation; 0 means purely radial motion
+}
+
+// DopplerModel predicts the received frequency for a transmitted frequency and link geometry.
+type DopplerModel interface {
+	Name() string
+	ReceivedFrequency(transmitFreq float64, geometry DopplerGeometry) float64
+}
+
+// ClassicalOneWayModel is the first-order formula used by DopplerEffect.
+type ClassicalOneWayModel struct {
+	SpeedOfLight float64
+}
+
+// Name returns the model name.
+func (m ClassicalOneWayModel) Name() string { return "classical-one-way" }
+
+// ReceivedFrequency applies the first-order shift for the downlink leg.
+func (m ClassicalOneWayModel) ReceivedFrequency(transmitFreq float64, geometry DopplerGeometry) float64 {
+	return DopplerEffect(transmitFreq, geometry.DownlinkVelocity, speedOfLightOrDefault(m.SpeedOfLight))
+}
+
+// RelativisticOneWayModel is the exact special-relativistic shift for a spacecraft transmitter.
+type RelativisticOneWayModel struct {
+	SpeedOfLight float64
+}
+
+// Name returns the model name.
+func (m RelativisticOneWayModel) Name() string { return "relativistic-one-way" }
+
+// ReceivedFrequency applies the relativistic shift for the downlink leg, including time dilation.
+func (m RelativisticOneWayModel) ReceivedFrequency(transmitFreq float64, geometry DopplerGeometry) float64 {
+	c := speedOfLightOrDefault(m.SpeedOfLight)
+	return transmitFreq * downlinkFactor(geometry.DownlinkVelocity, geometry.SpacecraftSpeed, c)
+}
+
+// TwoWayCoherentModel models a station uplink turned around coherently by the spacecraft
+// transponder and received back at the same station.
+type TwoWayCoherentModel struct {
+	TurnaroundRatio float64 // Transponder output/input frequency ratio, e.g. TurnaroundSBand
+	SpeedOfLight    float64
+	FirstOrder      bool // Use the first-order approximation instead of the exact relativistic one
+}
+
+// Name returns the model name.
+func (m TwoWayCoherentModel) Name() string { return "two-way-coherent" }
+
+// ReceivedFrequency applies the uplink shift, the turnaround ratio and the downlink shift.
+func (m TwoWayCoherentModel) ReceivedFrequency(transmitFreq float64, geometry DopplerGeometry) float64 {
+	return coherentReceivedFrequency(transmitFreq, m.TurnaroundRatio, geometry, speedOfLightOrDefault(m.SpeedOfLight), m.FirstOrder)
+}
+
+// ThreeWayModel is a coherent link where one station transmits and another receives.
+// The legs are described separately by the geometry, and ReceiverFrequencyOffset accounts
+// for the fractional offset between the two stations' frequency standards.
+type ThreeWayModel struct {
+	TurnaroundRatio         float64
+	SpeedOfLight            float64
+	ReceiverFrequencyOffset float64 // Fractional offset of the receiving station's reference, e.g. 1e-13
+	FirstOrder              bool
+}
+
+// Name returns the model name.
+func (m ThreeWayModel) Name() string { return "three-way" }
+
+// ReceivedFrequency returns the frequency as measured against the receiving station's reference.
+func (m ThreeWayModel) ReceivedFrequency(transmitFreq float64, geometry DopplerGeometry) float64 {
+	f := coherentReceivedFrequency(transmitFreq, m.TurnaroundRatio, geometry, speedOfLightOrDefault(m.SpeedOfLight), m.FirstOrder)
+	return f / (1 + m.ReceiverFrequencyOffset)
+}
+
+// coherentReceivedFrequency chains the uplink shift, transponder ratio and downlink shift.
+func coherentReceivedFrequency(transmitFreq, ratio float64, geometry DopplerGeometry, c float64, firstOrder bool) float64 {
+	if ratio == 0 {
+		ratio = 1
+	}
+	if firstOrder {
+		return transmitFreq * ratio * (1 + (geometry.UplinkVelocity+geometry.DownlinkVelocity)/c)
+	}
+	return transmitFreq * ratio *
+		uplinkFactor(geometry.UplinkVelocity, geometry.SpacecraftSpeed, c) *
+		downlinkFactor(geometry.DownlinkVelocity, geometry.SpacecraftSpeed, c)
+}
+
+// uplinkFactor is the relativistic shift seen by a moving spacecraft receiver.
+func uplinkFactor(closing, speed, c float64) float64 {
+	return lorentzFactor(closing, speed, c) * (1 + closing/c)
+}
+
+// downlinkFactor is the relativistic shift seen at a station from a moving spacecraft transmitter.
+func downlinkFactor(closing, speed, c float64) float64 {
+	return 1 / (lorentzFactor(closing, speed, c) * (1 - closing/c))
+}
+
+// lorentzFactor returns gamma for the spacecraft, treating the motion as radial when speed is unset.
+func lorentzFactor(closing, speed, c float64) float64 {
+	if math.Abs(speed) < math.Abs(closing) {
+		speed = closing
+	}
+	beta := speed / c
+	return 1 / math.Sqrt(1-beta*beta)
+}
+
+// speedOfLightOrDefault returns c, or SpeedOfLight when c is unset.
+func speedOfLightOrDefault(c float64) float64 {
+	if c == 0 {
+		return SpeedOfLight
+	}
+	return c
+}
+
+// RangeFunc returns the station-to-spacecraft range in metres at time t.
+type RangeFunc func(t time.Time) float64
+
+// LightTime holds the epochs of a light-time corrected two-way or three-way link.
+type LightTime struct {
+	Transmit      time.Time // Uplink transmission at the first station
+	Bounce        time.Time // Turnaround at the spacecraft
+	Receive       time.Time // Downlink reception at the second station
+	UplinkDelay   time.Duration
+	DownlinkDelay time.Duration
+}
+
+const (
+	lightTimeTolerance     = time.Nanosecond
+	lightTimeMaxIterations = 20
+)
+
+// SolveLightTime finds the delay τ satisfying τ = range(epoch - τ)/c (or range(epoch + τ)/c when
+// forward is true) by fixed-point iteration.
+func SolveLightTime(rangeAt RangeFunc, epoch time.Time, c float64, forward bool) (time.Duration, error) {
+	c = speedOfLightOrDefault(c)
+	sign := time.Duration(-1)
+	if forward {
+		sign = 1
+	}
+	delay := seconds(rangeAt(epoch) / c)
+	for i := 0; i < lightTimeMaxIterations; i++ {
+		next := seconds(rangeAt(epoch.Add(sign*delay)) / c)
+		if diff := next - delay; diff < lightTimeTolerance && diff > -lightTimeTolerance {
+			return next, nil
+		}
+		delay = next
+	}
+	return 0, fmt.Errorf("light-time solution did not converge after %d iterations", lightTimeMaxIterations)
+}
+
+// SolveTwoWayLightTime works back from the reception epoch through the downlink and uplink legs.
+// Pass the same RangeFunc twice for a two-way link.
+func SolveTwoWayLightTime(uplinkRange, downlinkRange RangeFunc, receive time.Time, c float64) (LightTime, error) {
+	down, err := SolveLightTime(downlinkRange, receive, c, false)
+	if err != nil {
+		return LightTime{}, fmt.Errorf("downlink: %w", err)
+	}
+	bounce := receive.Add(-down)
+	up, err := SolveLightTime(uplinkRange, bounce, c, false)
+	if err != nil {
+		return LightTime{}, fmt.Errorf("uplink: %w", err)
+	}
+	return LightTime{
+		Transmit:      bounce.Add(-up),
+		Bounce:        bounce,
+		Receive:       receive,
+		UplinkDelay:   up,
+		DownlinkDelay: down,
+	}, nil
+}
+
+// LightTimeGeometry solves the light time for a reception epoch and returns the closing
+// velocities of both legs evaluated at the spacecraft turnaround epoch.
+func LightTimeGeometry(uplinkRange, downlinkRange RangeFunc, receive time.Time, c float64) (DopplerGeometry, LightTime, error) {
+	lt, err := SolveTwoWayLightTime(uplinkRange, downlinkRange, receive, c)
+	if err != nil {
+		return DopplerGeometry{}, LightTime{}, err
+	}
+	return DopplerGeometry{
+		UplinkVelocity:   -rangeRate(uplinkRange, lt.Bounce),
+		DownlinkVelocity: -rangeRate(downlinkRange, lt.Bounce),
+	}, lt, nil
+}
+
+// rangeRate differentiates a RangeFunc with a central difference, in m/s.
+func rangeRate(rangeAt RangeFunc, t time.Time) float64 {
+	const h = 10 * time.Millisecond
+	return (rangeAt(t.Add(h)) - rangeAt(t.Add(-h))) / (2 * h.Seconds())
+}
+
+// seconds converts floating-point seconds to a Duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,51 @@
+package communication
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestDopplerModels checks each model against textbook reference values.
+func TestDopplerModels(t *testing.T) {
+	c := SpeedOfLight
+	cases := []struct {
+		name     string
+		got      float64
+		expected float64
+	}{
+		// Radial relativistic factors sqrt((1+β)/(1-β)): β=0.6 gives 2, β=0.8 gives 3.
+		{"relativistic β=0.6 approaching", RelativisticOneWayModel{}.ReceivedFrequency(1, DopplerGeometry{DownlinkVelocity: 0.6 * c}), 2},
+		{"relativistic β=0.8 approaching", RelativisticOneWayModel{}.ReceivedFrequency(1, DopplerGeometry{DownlinkVelocity: 0.8 * c}), 3},
+		{"relativistic β=0.6 receding", RelativisticOneWayModel{}.ReceivedFrequency(1, DopplerGeometry{DownlinkVelocity: -0.6 * c}), 0.5},
+		// Purely transverse motion at β=0.6 leaves only time dilation, 1/γ = 0.8.
+		{"relativistic transverse β=0.6", RelativisticOneWayModel{}.ReceivedFrequency(1, DopplerGeometry{SpacecraftSpeed: 0.6 * c}), 0.8},
+		{"classical 3 km/s on 2.4 GHz", ClassicalOneWayModel{}.ReceivedFrequency(2.4e9, DopplerGeometry{DownlinkVelocity: 3000}), 2.4e9 * (1 + 3000/c)},
+		// Two-way exact: M·f·(1+β)/(1-β); at rest the downlink is exactly 240/221 of the uplink.
+		{"two-way S-band at rest", TwoWayCoherentModel{TurnaroundRatio: TurnaroundSBand}.ReceivedFrequency(2.21e9, DopplerGeometry{}), 2.4e9},
+		{"two-way β=0.6 unit ratio", TwoWayCoherentModel{TurnaroundRatio: 1}.ReceivedFrequency(1, DopplerGeometry{UplinkVelocity: 0.6 * c, DownlinkVelocity: 0.6 * c}), 4},
+		{"two-way first-order 10 km/s", TwoWayCoherentModel{TurnaroundRatio: TurnaroundSBand, FirstOrder: true}.ReceivedFrequency(2.21e9, DopplerGeometry{UplinkVelocity: 1e4, DownlinkVelocity: 1e4}), 2.4e9 * (1 + 2e4/c)},
+		{"three-way 1e-12 station offset", ThreeWayModel{TurnaroundRatio: TurnaroundSBand, ReceiverFrequencyOffset: 1e-12}.ReceivedFrequency(2.21e9, DopplerGeometry{}), 2.4e9 / (1 + 1e-12)},
+	}
+	for _, tc := range cases {
+		if relErr := math.Abs(tc.got-tc.expected) / math.Abs(tc.expected); relErr > 1e-9 {
+			t.Errorf("%s: got %.10g, expected %.10g", tc.name, tc.got, tc.expected)
+		}
+	}
+}
+
+// TestLightTime checks the light-time solution for a spacecraft receding at 1 km/s from 1 AU.
+func TestLightTime(t *testing.T) {
+	const au = 1.495978707e11
+	c := SpeedOfLight
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	receding := func(t time.Time) float64 { return au + 1000*t.Sub(t0).Seconds() }
+	lt, err := SolveTwoWayLightTime(receding, receding, t0, c)
+	if err != nil {
+		t.Fatalf("SolveTwoWayLightTime: %v", err)
+	}
+	// τ = r(t-τ)/c  =>  τ = AU / (c + 1000), about 499.0 s
+	if got, want := lt.DownlinkDelay.Seconds(), au/(c+1000); math.Abs(got-want)/want > 1e-9 {
+		t.Errorf("one-way light time: got %.10g s, expected %.10g s", got, want)
+	}
+}
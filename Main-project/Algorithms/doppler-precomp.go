@@ -0,0 +1,231 @@
+package communication
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// VelocityFunc returns the predicted closing velocity, in m/s, at time t
+// (positive when the spacecraft approaches, as in DopplerEffect).
+type VelocityFunc func(t time.Time) float64
+
+// PrecompensationConfig describes the uplink tuning profile to generate.
+type PrecompensationConfig struct {
+	RestFrequency        float64       // Spacecraft receiver rest frequency, in Hz
+	Start                time.Time     // First transmit epoch
+	End                  time.Time     // Last transmit epoch
+	Step                 time.Duration // Spacing of tuning points; the uplink ramps linearly between them, and the last interval ends at End
+	MaxTuningRate        float64       // Largest uplink ramp the exciter supports, in Hz/s; 0 is unlimited
+	AcquisitionBandwidth float64       // Half-width of the receiver acquisition range, in Hz
+	SpeedOfLight         float64       // 0 means SpeedOfLight
+	// Range, if set, is used to evaluate the velocity when the uplink reaches the
+	// spacecraft rather than when it leaves the station.
+	Range RangeFunc
+}
+
+// PrecompensationStep is one time-tagged tuning point.
+type PrecompensationStep struct {
+	Time            time.Time // Transmit epoch
+	UplinkFrequency float64   // Frequency to transmit, in Hz
+	Offset          float64   // UplinkFrequency minus the rest frequency, in Hz
+	IdealOffset     float64   // Offset that would exactly cancel the Doppler shift, in Hz
+	Ramp            float64   // Ramp to the next point, in Hz/s
+	RateLimited     bool      // The tuning rate limit kept the uplink from its ideal value
+}
+
+// PrecompensationProfile is a generated uplink tuning table.
+type PrecompensationProfile struct {
+	Config      PrecompensationConfig
+	Steps       []PrecompensationStep
+	MaxResidual float64   // Largest offset from rest frequency seen by the spacecraft, in Hz
+	WorstTime   time.Time // Transmit epoch of MaxResidual
+}
+
+// residualChecksPerStep is how many points along each ramp the residual is checked at.
+const residualChecksPerStep = 10
+
+// GeneratePrecompensation builds uplink offsets that hold the spacecraft receiver at its rest
+// frequency, limits the tuning rate, and measures the residual offset along every ramp.
+func GeneratePrecompensation(config PrecompensationConfig, velocity VelocityFunc) (*PrecompensationProfile, error) {
+	if config.RestFrequency <= 0 {
+		return nil, fmt.Errorf("rest frequency must be positive, got %v", config.RestFrequency)
+	}
+	if config.Step <= 0 {
+		return nil, fmt.Errorf("step must be positive, got %v", config.Step)
+	}
+	if !config.End.After(config.Start) {
+		return nil, errors.New("profile end must be after its start")
+	}
+	c := speedOfLightOrDefault(config.SpeedOfLight)
+
+	// velocityAt returns the closing velocity seen by an uplink transmitted at t.
+	velocityAt := func(t time.Time) (float64, error) {
+		if config.Range == nil {
+			return velocity(t), nil
+		}
+		delay, err := SolveLightTime(config.Range, t, c, true)
+		if err != nil {
+			return 0, err
+		}
+		return velocity(t.Add(delay)), nil
+	}
+
+	profile := &PrecompensationProfile{Config: config}
+	times := []time.Time{config.Start}
+	for t := config.Start.Add(config.Step); t.Before(config.End); t = t.Add(config.Step) {
+		times = append(times, t)
+	}
+	times = append(times, config.End) // A final, possibly shorter, interval ends exactly at End
+
+	for _, t := range times {
+		v, err := velocityAt(t)
+		if err != nil {
+			return nil, fmt.Errorf("at %v: %w", t, err)
+		}
+		// DopplerEffect(f_up, v, c) = rest  =>  f_up = rest·c/(c+v)
+		ideal := config.RestFrequency * c / (c + v)
+		step := PrecompensationStep{Time: t, UplinkFrequency: ideal}
+		if n := len(profile.Steps); n > 0 && config.MaxTuningRate > 0 {
+			prev := profile.Steps[n-1].UplinkFrequency
+			maxChange := config.MaxTuningRate * t.Sub(profile.Steps[n-1].Time).Seconds()
+			if change := ideal - prev; math.Abs(change) > maxChange {
+				step.UplinkFrequency = prev + math.Copysign(maxChange, change)
+				step.RateLimited = true
+			}
+		}
+		step.Offset = step.UplinkFrequency - config.RestFrequency
+		step.IdealOffset = ideal - config.RestFrequency
+		profile.Steps = append(profile.Steps, step)
+	}
+
+	for i := range profile.Steps {
+		cur := &profile.Steps[i]
+		span, interval := 1, 0.0
+		if i+1 < len(profile.Steps) {
+			next := profile.Steps[i+1]
+			interval = next.Time.Sub(cur.Time).Seconds()
+			cur.Ramp = (next.UplinkFrequency - cur.UplinkFrequency) / interval
+			span = residualChecksPerStep
+		}
+		for k := 0; k < span; k++ {
+			dt := interval * float64(k) / residualChecksPerStep
+			t := cur.Time.Add(seconds(dt))
+			v, err := velocityAt(t)
+			if err != nil {
+				return nil, fmt.Errorf("at %v: %w", t, err)
+			}
+			received := DopplerEffect(cur.UplinkFrequency+cur.Ramp*dt, v, c)
+			if residual := math.Abs(received - config.RestFrequency); residual > profile.MaxResidual {
+				profile.MaxResidual, profile.WorstTime = residual, t
+			}
+		}
+	}
+	return profile, nil
+}
+
+// Verify returns an error if the residual offset ever leaves the acquisition bandwidth.
+func (p *PrecompensationProfile) Verify() error {
+	if p.Config.AcquisitionBandwidth <= 0 {
+		return errors.New("acquisition bandwidth not configured")
+	}
+	if p.MaxResidual > p.Config.AcquisitionBandwidth {
+		return fmt.Errorf("residual offset %.1f Hz at %v exceeds the ±%.1f Hz acquisition bandwidth",
+			p.MaxResidual, p.WorstTime, p.Config.AcquisitionBandwidth)
+	}
+	return nil
+}
+
+// WriteCSV writes the profile as a CSV tuning script.
+func (p *PrecompensationProfile) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"time", "uplink_frequency_hz", "offset_hz", "ramp_hz_per_s"}); err != nil {
+		return err
+	}
+	for _, s := range p.Steps {
+		if err := cw.Write([]string{
+			s.Time.UTC().Format(time.RFC3339Nano),
+			strconv.FormatFloat(s.UplinkFrequency, 'f', 3, 64),
+			strconv.FormatFloat(s.Offset, 'f', 3, 64),
+			strconv.FormatFloat(s.Ramp, 'f', 6, 64),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// tuningScript is the JSON form of a PrecompensationProfile.
+type tuningScript struct {
+	RestFrequency        float64           `json:"rest_frequency_hz"`
+	StepSeconds          float64           `json:"step_s"`
+	MaxTuningRate        float64           `json:"max_tuning_rate_hz_per_s,omitempty"`
+	AcquisitionBandwidth float64           `json:"acquisition_bandwidth_hz,omitempty"`
+	MaxResidual          float64           `json:"max_residual_hz"`
+	Entries              []tuningScriptRow `json:"entries"`
+}
+
+type tuningScriptRow struct {
+	Time            time.Time `json:"time"`
+	UplinkFrequency float64   `json:"uplink_frequency_hz"`
+	Offset          float64   `json:"offset_hz"`
+	Ramp            float64   `json:"ramp_hz_per_s"`
+	RateLimited     bool      `json:"rate_limited,omitempty"`
+}
+
+// WriteJSON writes the profile as a JSON tuning script.
+func (p *PrecompensationProfile) WriteJSON(w io.Writer) error {
+	script := tuningScript{
+		RestFrequency:        p.Config.RestFrequency,
+		StepSeconds:          p.Config.Step.Seconds(),
+		MaxTuningRate:        p.Config.MaxTuningRate,
+		AcquisitionBandwidth: p.Config.AcquisitionBandwidth,
+		MaxResidual:          p.MaxResidual,
+		Entries:              make([]tuningScriptRow, len(p.Steps)),
+	}
+	for i, s := range p.Steps {
+		script.Entries[i] = tuningScriptRow{
+			Time:            s.Time.UTC(),
+			UplinkFrequency: s.UplinkFrequency,
+			Offset:          s.Offset,
+			Ramp:            s.Ramp,
+			RateLimited:     s.RateLimited,
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(script)
+}
+
+// InterpolateVelocity builds a VelocityFunc from predicted DopplerData samples by linear
+// interpolation in time, holding the end values outside the covered span.
+func InterpolateVelocity(predicted []DopplerData) (VelocityFunc, error) {
+	if len(predicted) == 0 {
+		return nil, errors.New("no predicted samples")
+	}
+	samples := append([]DopplerData(nil), predicted...)
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Timestamp.Before(samples[j].Timestamp) })
+	return func(t time.Time) float64 {
+		i := sort.Search(len(samples), func(i int) bool { return !samples[i].Timestamp.Before(t) })
+		switch {
+		case i == 0:
+			return samples[0].Velocity
+		case i == len(samples):
+			return samples[len(samples)-1].Velocity
+		}
+		a, b := samples[i-1], samples[i]
+		span := b.Timestamp.Sub(a.Timestamp).Seconds()
+		if span == 0 {
+			return b.Velocity
+		}
+		frac := t.Sub(a.Timestamp).Seconds() / span
+		return a.Velocity + frac*(b.Velocity-a.Velocity)
+	}, nil
+}
@@ -0,0 +1,755 @@
+package communication
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DataFormat selects an on-disk encoding for Doppler datasets.
+type DataFormat int
+
+const (
+	FormatCSV DataFormat = iota
+	FormatJSONLines
+	FormatColumnar
+)
+
+// String returns the conventional file extension for the format.
+func (f DataFormat) String() string {
+	switch f {
+	case FormatCSV:
+		return "csv"
+	case FormatJSONLines:
+		return "jsonl"
+	case FormatColumnar:
+		return "dcol"
+	default:
+		return fmt.Sprintf("DataFormat(%d)", int(f))
+	}
+}
+
+// DopplerWriter streams DopplerData records to an underlying writer.
+// Close flushes buffered records but does not close the underlying writer.
+type DopplerWriter interface {
+	Write(data DopplerData) error
+	Close() error
+}
+
+// DopplerReader streams DopplerData records and returns io.EOF after the last one.
+type DopplerReader interface {
+	Read() (DopplerData, error)
+}
+
+// NewDopplerWriter returns a writer for the given format.
+func NewDopplerWriter(w io.Writer, format DataFormat) (DopplerWriter, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVDopplerWriter(w), nil
+	case FormatJSONLines:
+		return NewJSONLinesDopplerWriter(w), nil
+	case FormatColumnar:
+		return NewColumnarDopplerWriter(w, DefaultColumnarBlockSize), nil
+	default:
+		return nil, fmt.Errorf("unsupported data format %v", format)
+	}
+}
+
+// NewDopplerReader returns a reader for the given format.
+func NewDopplerReader(r io.Reader, format DataFormat) (DopplerReader, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVDopplerReader(r)
+	case FormatJSONLines:
+		return NewJSONLinesDopplerReader(r), nil
+	case FormatColumnar:
+		return NewColumnarDopplerReader(r)
+	default:
+		return nil, fmt.Errorf("unsupported data format %v", format)
+	}
+}
+
+// ExportData streams a snapshot of the sorter's data to w in its current order.
+func (ds *DopplerSorter) ExportData(w DopplerWriter) error {
+	ds.mutex.Lock()
+	snapshot := append([]DopplerData(nil), ds.data...)
+	ds.mutex.Unlock()
+
+	for _, entry := range snapshot {
+		if err := w.Write(entry); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// ImportData adds every record from r to the sorter and returns how many were read.
+func (ds *DopplerSorter) ImportData(r DopplerReader) (int, error) {
+	count := 0
+	for {
+		entry, err := r.Read()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		ds.AddData(entry)
+		count++
+	}
+}
+
+// SaveData writes the sorter's data to a file for offline post-pass analysis.
+func (ds *DopplerSorter) SaveData(filename string, format DataFormat) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	buffered := bufio.NewWriter(file)
+	w, err := NewDopplerWriter(buffered, format)
+	if err != nil {
+		return err
+	}
+	if err := ds.ExportData(w); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// LoadData reads a file written by SaveData and adds its records to the sorter.
+func (ds *DopplerSorter) LoadData(filename string, format DataFormat) (int, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	r, err := NewDopplerReader(bufio.NewReader(file), format)
+	if err != nil {
+		return 0, err
+	}
+	return ds.ImportData(r)
+}
+
+// CSV
+
+var csvHeader = []string{"id", "timestamp", "frequency_hz", "velocity_mps", "signal_strength"}
+
+// CSVDopplerWriter writes one record per CSV row with a header line.
+type CSVDopplerWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+// NewCSVDopplerWriter creates a CSV writer.
+func NewCSVDopplerWriter(w io.Writer) *CSVDopplerWriter {
+	return &CSVDopplerWriter{w: csv.NewWriter(w)}
+}
+
+// Write appends a record.
+func (cw *CSVDopplerWriter) Write(data DopplerData) error {
+	if !cw.headerWritten {
+		if err := cw.w.Write(csvHeader); err != nil {
+			return err
+		}
+		cw.headerWritten = true
+	}
+	return cw.w.Write([]string{
+		data.ID,
+		data.Timestamp.Format(time.RFC3339Nano),
+		strconv.FormatFloat(data.Frequency, 'g', -1, 64),
+		strconv.FormatFloat(data.Velocity, 'g', -1, 64),
+		strconv.FormatFloat(data.SignalStrength, 'g', -1, 64),
+	})
+}
+
+// Close flushes buffered rows, writing the header even for an empty dataset.
+func (cw *CSVDopplerWriter) Close() error {
+	if !cw.headerWritten {
+		if err := cw.w.Write(csvHeader); err != nil {
+			return err
+		}
+		cw.headerWritten = true
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// CSVDopplerReader reads records written by CSVDopplerWriter.
+type CSVDopplerReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+// NewCSVDopplerReader creates a CSV reader and consumes the header line.
+func NewCSVDopplerReader(r io.Reader) (*CSVDopplerReader, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range csvHeader {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV header missing column %q", name)
+		}
+	}
+	return &CSVDopplerReader{r: cr, columns: columns}, nil
+}
+
+// Read returns the next record.
+func (cr *CSVDopplerReader) Read() (DopplerData, error) {
+	row, err := cr.r.Read()
+	if err != nil {
+		return DopplerData{}, err
+	}
+	line, _ := cr.r.FieldPos(0)
+	field := func(name string) string { return row[cr.columns[name]] }
+
+	timestamp, err := time.Parse(time.RFC3339Nano, field("timestamp"))
+	if err != nil {
+		return DopplerData{}, fmt.Errorf("line %d: %w", line, err)
+	}
+	var values [3]float64
+	for i, name := range csvHeader[2:] {
+		if values[i], err = strconv.ParseFloat(field(name), 64); err != nil {
+			return DopplerData{}, fmt.Errorf("line %d: %s: %w", line, name, err)
+		}
+	}
+	return DopplerData{
+		ID:             field("id"),
+		Timestamp:      timestamp,
+		Frequency:      values[0],
+		Velocity:       values[1],
+		SignalStrength: values[2],
+	}, nil
+}
+
+// JSON Lines
+
+// dopplerRecord is the JSON Lines representation of DopplerData.
+type dopplerRecord struct {
+	Frequency      jsonFloat `json:"frequency_hz"`
+	Velocity       jsonFloat `json:"velocity_mps"`
+	SignalStrength jsonFloat `json:"signal_strength"`
+	Timestamp      time.Time `json:"timestamp"`
+	ID             string    `json:"id"`
+}
+
+// jsonFloat is a float64 that also encodes NaN and ±Inf, which JSON numbers cannot hold,
+// as the strings "NaN", "+Inf" and "-Inf" used by the CSV format.
+type jsonFloat float64
+
+// MarshalJSON encodes finite values as numbers and the rest as strings.
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return json.Marshal(strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON accepts a number or one of the non-finite strings.
+func (f *jsonFloat) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		var v float64
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		*f = jsonFloat(v)
+		return nil
+	}
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil || !(math.IsNaN(v) || math.IsInf(v, 0)) {
+		return fmt.Errorf("invalid non-finite value %q", text)
+	}
+	*f = jsonFloat(v)
+	return nil
+}
+
+// JSONLinesDopplerWriter writes one JSON object per line.
+type JSONLinesDopplerWriter struct {
+	enc *json.Encoder
+}
+
+// NewJSONLinesDopplerWriter creates a JSON Lines writer.
+func NewJSONLinesDopplerWriter(w io.Writer) *JSONLinesDopplerWriter {
+	return &JSONLinesDopplerWriter{enc: json.NewEncoder(w)}
+}
+
+// Write appends a record.
+func (jw *JSONLinesDopplerWriter) Write(data DopplerData) error {
+	return jw.enc.Encode(dopplerRecord{
+		Frequency:      jsonFloat(data.Frequency),
+		Velocity:       jsonFloat(data.Velocity),
+		SignalStrength: jsonFloat(data.SignalStrength),
+		Timestamp:      data.Timestamp,
+		ID:             data.ID,
+	})
+}
+
+// Close is a no-op; records are written as they arrive.
+func (jw *JSONLinesDopplerWriter) Close() error { return nil }
+
+// JSONLinesDopplerReader reads records written by JSONLinesDopplerWriter.
+type JSONLinesDopplerReader struct {
+	dec *json.Decoder
+}
+
+// NewJSONLinesDopplerReader creates a JSON Lines reader.
+func NewJSONLinesDopplerReader(r io.Reader) *JSONLinesDopplerReader {
+	return &JSONLinesDopplerReader{dec: json.NewDecoder(r)}
+}
+
+// Read returns the next record.
+func (jr *JSONLinesDopplerReader) Read() (DopplerData, error) {
+	var record dopplerRecord
+	if err := jr.dec.Decode(&record); err != nil {
+		return DopplerData{}, err
+	}
+	return DopplerData{
+		Frequency:      float64(record.Frequency),
+		Velocity:       float64(record.Velocity),
+		SignalStrength: float64(record.SignalStrength),
+		Timestamp:      record.Timestamp,
+		ID:             record.ID,
+	}, nil
+}
+
+// Columnar
+//
+// The columnar format is a magic header followed by blocks of up to blockSize records.
+// Each block starts with a uvarint record count (0 marks the end of the file) followed by
+// five length-prefixed column chunks: timestamps as delta-of-delta zigzag varints of their
+// Unix seconds and nanoseconds, frequency, velocity and signal strength as XOR-compressed
+// float streams, and IDs as length-prefixed strings. Readers only ever hold one block in
+// memory. Timestamps keep their instant but are read back in UTC.
+
+const columnarMagic = "DPLRCOL2"
+
+// DefaultColumnarBlockSize is the number of records buffered per columnar block.
+const DefaultColumnarBlockSize = 4096
+
+// Limits a reader enforces on each block before allocating for it, so corrupt or hostile
+// headers produce format errors rather than huge allocations.
+const (
+	maxColumnarBlockSize = 1 << 20  // Records per block
+	maxColumnarRecordLen = 10       // Bytes per record in a numeric column: a varint, or 77 bits of XOR stream
+	maxColumnarIDBytes   = 64 << 20 // Bytes in the ID column of one block
+)
+
+// columnarTime is a timestamp or a difference of two as Unix seconds and nanoseconds, with
+// nsec kept in [0, 1e9), so every time.Time round-trips without UnixNano's range limit.
+type columnarTime struct{ sec, nsec int64 }
+
+func (a columnarTime) add(b columnarTime) columnarTime {
+	return columnarTime{a.sec + b.sec, a.nsec + b.nsec}.normalize()
+}
+
+func (a columnarTime) sub(b columnarTime) columnarTime {
+	return columnarTime{a.sec - b.sec, a.nsec - b.nsec}.normalize()
+}
+
+func (a columnarTime) normalize() columnarTime {
+	a.sec += a.nsec / 1e9
+	if a.nsec %= 1e9; a.nsec < 0 {
+		a.sec, a.nsec = a.sec-1, a.nsec+1e9
+	}
+	return a
+}
+
+// ColumnarDopplerWriter buffers records into blocks and writes them column by column.
+type ColumnarDopplerWriter struct {
+	w             io.Writer
+	blockSize     int
+	block         []DopplerData
+	headerWritten bool
+	closed        bool
+}
+
+// NewColumnarDopplerWriter creates a columnar writer with the given block size, which is
+// capped at the largest block a reader accepts.
+func NewColumnarDopplerWriter(w io.Writer, blockSize int) *ColumnarDopplerWriter {
+	if blockSize <= 0 {
+		blockSize = DefaultColumnarBlockSize
+	}
+	if blockSize > maxColumnarBlockSize {
+		blockSize = maxColumnarBlockSize
+	}
+	return &ColumnarDopplerWriter{w: w, blockSize: blockSize, block: make([]DopplerData, 0, blockSize)}
+}
+
+// Write buffers a record, flushing a block when it is full.
+func (cw *ColumnarDopplerWriter) Write(data DopplerData) error {
+	if cw.closed {
+		return errors.New("columnar writer is closed")
+	}
+	cw.block = append(cw.block, data)
+	if len(cw.block) >= cw.blockSize {
+		return cw.flushBlock()
+	}
+	return nil
+}
+
+// Close flushes the final block and writes the end marker.
+func (cw *ColumnarDopplerWriter) Close() error {
+	if cw.closed {
+		return nil
+	}
+	if err := cw.flushBlock(); err != nil {
+		return err
+	}
+	cw.closed = true
+	_, err := cw.w.Write(binary.AppendUvarint(nil, 0))
+	return err
+}
+
+// flushBlock encodes and writes the buffered records.
+func (cw *ColumnarDopplerWriter) flushBlock() error {
+	if !cw.headerWritten {
+		if _, err := io.WriteString(cw.w, columnarMagic); err != nil {
+			return err
+		}
+		cw.headerWritten = true
+	}
+	if len(cw.block) == 0 {
+		return nil
+	}
+
+	var timestamps, ids []byte
+	var prev, prevDelta columnarTime
+	for i, entry := range cw.block {
+		t := columnarTime{entry.Timestamp.Unix(), int64(entry.Timestamp.Nanosecond())}
+		switch i {
+		case 0:
+			timestamps = binary.AppendVarint(timestamps, t.sec)
+			timestamps = binary.AppendVarint(timestamps, t.nsec)
+		default:
+			delta := t.sub(prev)
+			dod := delta.sub(prevDelta)
+			timestamps = binary.AppendVarint(timestamps, dod.sec)
+			timestamps = binary.AppendVarint(timestamps, dod.nsec)
+			prevDelta = delta
+		}
+		prev = t
+		ids = binary.AppendUvarint(ids, uint64(len(entry.ID)))
+		ids = append(ids, entry.ID...)
+	}
+	frequency := encodeFloatColumn(cw.block, func(d DopplerData) float64 { return d.Frequency })
+	velocity := encodeFloatColumn(cw.block, func(d DopplerData) float64 { return d.Velocity })
+	strength := encodeFloatColumn(cw.block, func(d DopplerData) float64 { return d.SignalStrength })
+
+	out := binary.AppendUvarint(nil, uint64(len(cw.block)))
+	for _, column := range [][]byte{timestamps, frequency, velocity, strength, ids} {
+		out = binary.AppendUvarint(out, uint64(len(column)))
+		out = append(out, column...)
+	}
+	cw.block = cw.block[:0]
+	_, err := cw.w.Write(out)
+	return err
+}
+
+// ColumnarDopplerReader reads files written by ColumnarDopplerWriter one block at a time.
+// Timestamps are returned in UTC.
+type ColumnarDopplerReader struct {
+	r     *bufio.Reader
+	block []DopplerData
+	next  int
+	done  bool
+}
+
+// NewColumnarDopplerReader creates a columnar reader and checks the file header.
+func NewColumnarDopplerReader(r io.Reader) (*ColumnarDopplerReader, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(columnarMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, fmt.Errorf("reading columnar header: %w", err)
+	}
+	if string(magic) != columnarMagic {
+		return nil, fmt.Errorf("not a columnar Doppler file (magic %q)", magic)
+	}
+	return &ColumnarDopplerReader{r: br}, nil
+}
+
+// Read returns the next record, loading the next block when needed.
+func (cr *ColumnarDopplerReader) Read() (DopplerData, error) {
+	for cr.next >= len(cr.block) {
+		if cr.done {
+			return DopplerData{}, io.EOF
+		}
+		if err := cr.readBlock(); err != nil {
+			return DopplerData{}, err
+		}
+	}
+	entry := cr.block[cr.next]
+	cr.next++
+	return entry, nil
+}
+
+// readBlock decodes the next block into memory.
+func (cr *ColumnarDopplerReader) readBlock() error {
+	count, err := binary.ReadUvarint(cr.r)
+	if err != nil {
+		return fmt.Errorf("reading block header: %w", unexpectedEOF(err))
+	}
+	if count == 0 {
+		cr.done = true
+		cr.block, cr.next = nil, 0
+		return nil
+	}
+	if count > maxColumnarBlockSize {
+		return fmt.Errorf("corrupt block header: %d records exceeds the limit of %d", count, maxColumnarBlockSize)
+	}
+
+	var columns [5][]byte
+	for i := range columns {
+		size, err := binary.ReadUvarint(cr.r)
+		if err != nil {
+			return fmt.Errorf("reading column %d size: %w", i, unexpectedEOF(err))
+		}
+		limit := count * maxColumnarRecordLen
+		switch i {
+		case 0:
+			limit *= 2 // Seconds and nanoseconds
+		case len(columns) - 1:
+			limit = maxColumnarIDBytes
+		}
+		if size > limit {
+			return fmt.Errorf("corrupt block header: column %d size %d exceeds the limit of %d for %d records", i, size, limit, count)
+		}
+		// Grow the buffer as data arrives so a truncated file cannot force a large allocation
+		var column bytes.Buffer
+		if _, err := io.CopyN(&column, cr.r, int64(size)); err != nil {
+			return fmt.Errorf("reading column %d: %w", i, unexpectedEOF(err))
+		}
+		columns[i] = column.Bytes()
+	}
+
+	n := int(count)
+	block := make([]DopplerData, n)
+	timestamps := bytes.NewReader(columns[0])
+	var t, delta columnarTime
+	for i := range block {
+		var v columnarTime
+		if v.sec, err = binary.ReadVarint(timestamps); err == nil {
+			v.nsec, err = binary.ReadVarint(timestamps)
+		}
+		if err != nil {
+			return fmt.Errorf("decoding timestamps: %w", unexpectedEOF(err))
+		}
+		switch i {
+		case 0:
+			t = v
+		default:
+			delta = delta.add(v)
+			t = t.add(delta)
+		}
+		block[i].Timestamp = time.Unix(t.sec, t.nsec).UTC()
+	}
+
+	setters := []func(*DopplerData, float64){
+		func(d *DopplerData, v float64) { d.Frequency = v },
+		func(d *DopplerData, v float64) { d.Velocity = v },
+		func(d *DopplerData, v float64) { d.SignalStrength = v },
+	}
+	for c, set := range setters {
+		values, err := decodeFloatColumn(columns[1+c], n)
+		if err != nil {
+			return fmt.Errorf("decoding column %d: %w", 1+c, err)
+		}
+		for i, v := range values {
+			set(&block[i], v)
+		}
+	}
+
+	ids := bytes.NewReader(columns[4])
+	for i := range block {
+		size, err := binary.ReadUvarint(ids)
+		if err != nil {
+			return fmt.Errorf("decoding IDs: %w", unexpectedEOF(err))
+		}
+		if size > uint64(ids.Len()) {
+			return fmt.Errorf("decoding IDs: %w", io.ErrUnexpectedEOF)
+		}
+		id := make([]byte, size)
+		if _, err := io.ReadFull(ids, id); err != nil {
+			return fmt.Errorf("decoding IDs: %w", unexpectedEOF(err))
+		}
+		block[i].ID = string(id)
+	}
+
+	cr.block, cr.next = block, 0
+	return nil
+}
+
+// unexpectedEOF converts a bare io.EOF inside a block into io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// encodeFloatColumn compresses a float column by XOR-ing each value with its predecessor
+// and storing only the meaningful bits, which suits slowly varying Doppler series.
+func encodeFloatColumn(block []DopplerData, extract func(DopplerData) float64) []byte {
+	var bw bitWriter
+	var prev uint64
+	prevLeading, prevTrailing := -1, 0
+	for i, entry := range block {
+		v := math.Float64bits(extract(entry))
+		if i == 0 {
+			bw.writeBits(v, 64)
+			prev = v
+			continue
+		}
+		xor := v ^ prev
+		prev = v
+		if xor == 0 {
+			bw.writeBit(0)
+			continue
+		}
+		bw.writeBit(1)
+		leading := bits.LeadingZeros64(xor)
+		trailing := bits.TrailingZeros64(xor)
+		if leading > 31 {
+			leading = 31
+		}
+		if prevLeading >= 0 && leading >= prevLeading && trailing >= prevTrailing {
+			bw.writeBit(0)
+			bw.writeBits(xor>>prevTrailing, 64-prevLeading-prevTrailing)
+			continue
+		}
+		significant := 64 - leading - trailing
+		bw.writeBit(1)
+		bw.writeBits(uint64(leading), 5)
+		bw.writeBits(uint64(significant&63), 6) // 64 is stored as 0
+		bw.writeBits(xor>>trailing, significant)
+		prevLeading, prevTrailing = leading, trailing
+	}
+	return bw.bytes()
+}
+
+// decodeFloatColumn reverses encodeFloatColumn for n values.
+func decodeFloatColumn(data []byte, n int) ([]float64, error) {
+	br := bitReader{data: data}
+	values := make([]float64, n)
+	var prev uint64
+	prevLeading, prevTrailing := -1, 0
+	for i := range values {
+		if i == 0 {
+			v, err := br.readBits(64)
+			if err != nil {
+				return nil, err
+			}
+			prev = v
+			values[i] = math.Float64frombits(v)
+			continue
+		}
+		changed, err := br.readBit()
+		if err != nil {
+			return nil, err
+		}
+		if changed == 1 {
+			newWindow, err := br.readBit()
+			if err != nil {
+				return nil, err
+			}
+			if newWindow == 1 {
+				leading, err := br.readBits(5)
+				if err != nil {
+					return nil, err
+				}
+				significant, err := br.readBits(6)
+				if err != nil {
+					return nil, err
+				}
+				if significant == 0 {
+					significant = 64
+				}
+				prevLeading = int(leading)
+				prevTrailing = 64 - prevLeading - int(significant)
+			} else if prevLeading < 0 {
+				return nil, errors.New("corrupt float column: missing bit window")
+			}
+			meaningful, err := br.readBits(64 - prevLeading - prevTrailing)
+			if err != nil {
+				return nil, err
+			}
+			prev ^= meaningful << prevTrailing
+		}
+		values[i] = math.Float64frombits(prev)
+	}
+	return values, nil
+}
+
+// bitWriter packs bits most-significant first.
+type bitWriter struct {
+	buf   []byte
+	nbits uint
+}
+
+func (bw *bitWriter) writeBit(bit uint64) {
+	if bw.nbits%8 == 0 {
+		bw.buf = append(bw.buf, 0)
+	}
+	if bit != 0 {
+		bw.buf[len(bw.buf)-1] |= 1 << (7 - bw.nbits%8)
+	}
+	bw.nbits++
+}
+
+func (bw *bitWriter) writeBits(value uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		bw.writeBit((value >> uint(i)) & 1)
+	}
+}
+
+func (bw *bitWriter) bytes() []byte { return bw.buf }
+
+// bitReader reads bits written by bitWriter.
+type bitReader struct {
+	data []byte
+	pos  uint
+}
+
+func (br *bitReader) readBit() (uint64, error) {
+	if br.pos/8 >= uint(len(br.data)) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	bit := (br.data[br.pos/8] >> (7 - br.pos%8)) & 1
+	br.pos++
+	return uint64(bit), nil
+}
+
+func (br *bitReader) readBits(n int) (uint64, error) {
+	var value uint64
+	for i := 0; i < n; i++ {
+		bit, err := br.readBit()
+		if err != nil {
+			return 0, err
+		}
+		value = value<<1 | bit
+	}
+	return value, nil
+}
@@ -0,0 +1,52 @@
+package communication
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestColumnarTimestamps round-trips timestamps outside UnixNano's 1678–2262 range, the zero
+// time and local times through the columnar format, which returns them in UTC.
+func TestColumnarTimestamps(t *testing.T) {
+	cest := time.FixedZone("CEST", 2*3600)
+	times := []time.Time{
+		{},
+		time.Date(1, 1, 1, 0, 0, 0, 1, time.UTC),
+		time.Date(1600, 3, 1, 12, 0, 0, 999999999, time.UTC),
+		time.Date(2026, 10, 19, 14, 0, 0, 250000000, cest),
+		time.Date(2026, 10, 19, 12, 0, 0, 500000000, time.UTC),
+		time.Date(2026, 10, 19, 12, 0, 1, 0, time.UTC),
+		time.Date(2500, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(9999, 12, 31, 23, 59, 59, 999999999, time.UTC),
+		time.Now(),
+	}
+	var buf bytes.Buffer
+	w := NewColumnarDopplerWriter(&buf, 4) // Several blocks, so each restarts the deltas
+	for _, ts := range times {
+		if err := w.Write(DopplerData{Timestamp: ts, ID: "t"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewColumnarDopplerReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range times {
+		got, err := r.Read()
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if !got.Timestamp.Equal(want) || got.Timestamp.Location() != time.UTC {
+			t.Errorf("record %d: got %v, want %v in UTC", i, got.Timestamp, want)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("after the last record: %v, want EOF", err)
+	}
+}
@@ -0,0 +1,255 @@
+package communication
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// SortKey extracts a named numeric value, with its unit, from a DopplerData sample.
+type SortKey struct {
+	Name    string
+	Unit    string
+	Extract func(DopplerData) float64
+}
+
+// String returns the key name with its unit, e.g. "frequency[Hz]".
+func (k SortKey) String() string {
+	if k.Unit == "" {
+		return k.Name
+	}
+	return fmt.Sprintf("%s[%s]", k.Name, k.Unit)
+}
+
+// Built-in keys for the DopplerData fields.
+var (
+	KeyFrequency      = SortKey{Name: "frequency", Unit: "Hz", Extract: func(d DopplerData) float64 { return d.Frequency }}
+	KeyVelocity       = SortKey{Name: "velocity", Unit: "m/s", Extract: func(d DopplerData) float64 { return d.Velocity }}
+	KeySignalStrength = SortKey{Name: "signal strength", Extract: func(d DopplerData) float64 { return d.SignalStrength }}
+	KeyTimestamp      = SortKey{Name: "timestamp", Unit: "s", Extract: func(d DopplerData) float64 { return float64(d.Timestamp.UnixNano()) / 1e9 }}
+)
+
+// SortStrategy orders DopplerData samples.
+type SortStrategy interface {
+	Name() string
+	// Comparator returns a three-way comparison for the given data set. Strategies that
+	// normalise fields compute their statistics from data here.
+	Comparator(data []DopplerData) func(a, b DopplerData) int
+}
+
+// SortWith sorts the sorter's data with the given strategy. The sort is stable.
+func (ds *DopplerSorter) SortWith(strategy SortStrategy) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	compare := strategy.Comparator(ds.data)
+	sort.SliceStable(ds.data, func(i, j int) bool {
+		return compare(ds.data[i], ds.data[j]) < 0
+	})
+}
+
+// KeyOrder is one level of a MultiKeySort.
+type KeyOrder struct {
+	Key        SortKey
+	Descending bool
+	Tolerance  float64 // Values closer than this compare equal and fall through to the next key
+}
+
+// MultiKeySort orders samples lexicographically by each key in turn.
+type MultiKeySort struct {
+	Keys []KeyOrder
+}
+
+// Name describes the key order.
+func (m MultiKeySort) Name() string {
+	parts := make([]string, len(m.Keys))
+	for i, k := range m.Keys {
+		direction := "asc"
+		if k.Descending {
+			direction = "desc"
+		}
+		parts[i] = k.Key.String() + " " + direction
+	}
+	return "multi-key(" + strings.Join(parts, ", ") + ")"
+}
+
+// Comparator compares samples key by key.
+func (m MultiKeySort) Comparator(_ []DopplerData) func(a, b DopplerData) int {
+	return func(a, b DopplerData) int {
+		for _, k := range m.Keys {
+			va, vb := k.Key.Extract(a), k.Key.Extract(b)
+			if math.Abs(va-vb) <= k.Tolerance {
+				continue
+			}
+			c := compareFloats(va, vb)
+			if k.Descending {
+				c = -c
+			}
+			return c
+		}
+		return 0
+	}
+}
+
+// WeightedKey is one term of a WeightedScoreSort.
+type WeightedKey struct {
+	Key    SortKey
+	Weight float64 // Negative weights favour low values of the key
+}
+
+// WeightedScoreSort ranks samples by a weighted sum of per-key z-scores, so keys with
+// different units contribute on the same dimensionless scale. Highest score sorts first.
+type WeightedScoreSort struct {
+	Terms []WeightedKey
+}
+
+// Name describes the weighted terms.
+func (w WeightedScoreSort) Name() string {
+	parts := make([]string, len(w.Terms))
+	for i, t := range w.Terms {
+		parts[i] = fmt.Sprintf("%+.3g·z(%s)", t.Weight, t.Key)
+	}
+	return "weighted-score(" + strings.Join(parts, " ") + ")"
+}
+
+// Comparator normalises each key over data and compares weighted scores.
+func (w WeightedScoreSort) Comparator(data []DopplerData) func(a, b DopplerData) int {
+	means := make([]float64, len(w.Terms))
+	stddevs := make([]float64, len(w.Terms))
+	for i, t := range w.Terms {
+		means[i], stddevs[i] = meanStdDev(data, t.Key.Extract)
+	}
+	score := func(d DopplerData) float64 {
+		var s float64
+		for i, t := range w.Terms {
+			if stddevs[i] > 0 {
+				s += t.Weight * (t.Key.Extract(d) - means[i]) / stddevs[i]
+			}
+		}
+		return s
+	}
+	return func(a, b DopplerData) int {
+		return compareFloats(score(b), score(a))
+	}
+}
+
+// TimeOrdered sorts samples oldest first, comparing timestamps at full resolution.
+type TimeOrdered struct{}
+
+// Name returns the strategy name.
+func (TimeOrdered) Name() string { return "time-ordered" }
+
+// Comparator compares timestamps.
+func (TimeOrdered) Comparator(_ []DopplerData) func(a, b DopplerData) int {
+	return func(a, b DopplerData) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	}
+}
+
+// SignalQualityFirst sorts the strongest signals first, breaking ties by arrival time.
+func SignalQualityFirst() SortStrategy {
+	return ThenBy(
+		MultiKeySort{Keys: []KeyOrder{{Key: KeySignalStrength, Descending: true}}},
+		TimeOrdered{},
+	)
+}
+
+// compositeSort applies strategies in turn until one distinguishes the samples.
+type compositeSort []SortStrategy
+
+// ThenBy composes strategies: later strategies only break ties left by earlier ones.
+func ThenBy(strategies ...SortStrategy) SortStrategy {
+	return compositeSort(strategies)
+}
+
+// Name lists the composed strategies.
+func (c compositeSort) Name() string {
+	parts := make([]string, len(c))
+	for i, s := range c {
+		parts[i] = s.Name()
+	}
+	return strings.Join(parts, " then ")
+}
+
+// Comparator chains the comparators of each strategy.
+func (c compositeSort) Comparator(data []DopplerData) func(a, b DopplerData) int {
+	compares := make([]func(a, b DopplerData) int, len(c))
+	for i, s := range c {
+		compares[i] = s.Comparator(data)
+	}
+	return func(a, b DopplerData) int {
+		for _, compare := range compares {
+			if r := compare(a, b); r != 0 {
+				return r
+			}
+		}
+		return 0
+	}
+}
+
+// EnvironmentalConditions are the named inputs that bias measured Doppler frequencies.
+type EnvironmentalConditions struct {
+	// TemperatureC is the receiver oscillator temperature in °C. The oscillator runs fast by
+	// OscillatorTempCoefficient·(TemperatureC-ReferenceTemperatureC) fractionally, which makes
+	// every measured frequency read low by that fraction of the carrier.
+	TemperatureC              float64
+	ReferenceTemperatureC     float64 // Temperature at which the oscillator is on frequency, usually 25 °C
+	OscillatorTempCoefficient float64 // Fractional frequency change per °C, e.g. 1e-7 for 0.1 ppm/°C
+
+	// TECRate is the rate of change of slant ionospheric total electron content, in TECU/s.
+	// A growing electron content advances the carrier phase and raises the received
+	// frequency by 40.3·ΔTEC/(c·f).
+	TECRate float64
+}
+
+// FrequencyCorrection returns the amount, in Hz, to add to a measured frequency f to remove
+// the oscillator temperature offset and the ionospheric phase-advance shift.
+func (env EnvironmentalConditions) FrequencyCorrection(f float64) float64 {
+	const tecUnit = 1e16 // electrons/m² per TECU
+	oscillator := env.OscillatorTempCoefficient * (env.TemperatureC - env.ReferenceTemperatureC) * f
+	var ionosphere float64
+	if f != 0 {
+		ionosphere = 40.3 * env.TECRate * tecUnit / (SpeedOfLight * f)
+	}
+	return oscillator - ionosphere
+}
+
+// CorrectedFrequencyKey is a frequency key with the environmental correction applied.
+func CorrectedFrequencyKey(env EnvironmentalConditions) SortKey {
+	return SortKey{
+		Name: "corrected frequency",
+		Unit: "Hz",
+		Extract: func(d DopplerData) float64 {
+			return d.Frequency + env.FrequencyCorrection(d.Frequency)
+		},
+	}
+}
+
+// compareFloats is a three-way comparison of two floats.
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// meanStdDev returns the mean and population standard deviation of a key over data.
+func meanStdDev(data []DopplerData, extract func(DopplerData) float64) (mean, stddev float64) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	for _, d := range data {
+		mean += extract(d)
+	}
+	mean /= float64(len(data))
+	for _, d := range data {
+		diff := extract(d) - mean
+		stddev += diff * diff
+	}
+	return mean, math.Sqrt(stddev / float64(len(data)))
+}
@@ -0,0 +1,401 @@
+package communication
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TrackState is the lifecycle stage of a Doppler track.
+type TrackState int
+
+const (
+	TrackTentative TrackState = iota // Started from an unassociated sample, not yet confirmed
+	TrackConfirmed                   // Updated at least ConfirmHits times
+	TrackCoasting                    // Confirmed but missing updates; predicted forward
+	TrackDropped                     // Terminated; kept only for history queries
+)
+
+// String returns the state name.
+func (s TrackState) String() string {
+	switch s {
+	case TrackTentative:
+		return "tentative"
+	case TrackConfirmed:
+		return "confirmed"
+	case TrackCoasting:
+		return "coasting"
+	case TrackDropped:
+		return "dropped"
+	default:
+		return fmt.Sprintf("TrackState(%d)", int(s))
+	}
+}
+
+// TrackerConfig tunes association gates, filtering and the track lifecycle.
+type TrackerConfig struct {
+	FrequencyGate    float64       // Largest accepted mismatch from the predicted frequency, in Hz
+	MaxFrequencyRate float64       // Largest plausible Doppler rate, in Hz/s; widens the gate for tracks without a rate yet
+	StrengthWeight   float64       // Cost weight for signal-strength mismatch relative to frequency
+	Alpha            float64       // Alpha-beta filter frequency gain
+	Beta             float64       // Alpha-beta filter rate gain
+	ConfirmHits      int           // Updates needed to confirm a tentative track
+	CoastAfter       time.Duration // Silence after which a confirmed track coasts (and a tentative one drops)
+	DropAfter        time.Duration // Silence after which a coasting track is dropped
+	MaxHistory       int           // Samples kept per track; 0 keeps everything
+	MaxDroppedTracks int           // Dropped tracks kept for queries; 0 keeps none
+}
+
+// DefaultTrackerConfig returns settings suited to LEO passes sampled at about 1 Hz.
+func DefaultTrackerConfig() TrackerConfig {
+	return TrackerConfig{
+		FrequencyGate:    2e3,
+		MaxFrequencyRate: 1e3,
+		StrengthWeight:   0.5,
+		Alpha:            0.6,
+		Beta:             0.2,
+		ConfirmHits:      3,
+		CoastAfter:       5 * time.Second,
+		DropAfter:        30 * time.Second,
+		MaxHistory:       10000,
+		MaxDroppedTracks: 32,
+	}
+}
+
+// Track is one target's Doppler history with its filtered state.
+type Track struct {
+	ID             string
+	State          TrackState
+	Frequency      float64 // Filtered frequency at LastUpdate, in Hz
+	FrequencyRate  float64 // Filtered Doppler rate, in Hz/s
+	SignalStrength float64 // Smoothed signal strength
+	Started        time.Time
+	LastUpdate     time.Time
+	Hits           int
+	history        []DopplerData
+	hasRate        bool
+}
+
+// predict returns the expected frequency at t.
+func (tr *Track) predict(t time.Time) float64 {
+	return tr.Frequency + tr.FrequencyRate*t.Sub(tr.LastUpdate).Seconds()
+}
+
+// Tracker associates Doppler samples from several emitters into tracks by global
+// nearest neighbour assignment.
+type Tracker struct {
+	mutex   sync.Mutex
+	config  TrackerConfig
+	tracks  []*Track // Live tracks in creation order
+	dropped []*Track
+	nextID  int
+}
+
+// NewTracker creates a Tracker.
+func NewTracker(config TrackerConfig) *Tracker {
+	return &Tracker{config: config}
+}
+
+// ProcessScan associates one scan of samples taken at about the same time with the live
+// tracks, starting tentative tracks for unassociated samples, and advances every track's
+// lifecycle. The returned copies carry IDs of the form "<trackID>-<n>", so the sample ID
+// identifies its track while remaining unique.
+func (tk *Tracker) ProcessScan(scan []DopplerData) []DopplerData {
+	tk.mutex.Lock()
+	defer tk.mutex.Unlock()
+
+	if len(scan) == 0 {
+		return nil
+	}
+	now := scan[0].Timestamp
+	for _, s := range scan[1:] {
+		if s.Timestamp.After(now) {
+			now = s.Timestamp
+		}
+	}
+
+	assignment := tk.assign(scan)
+	labelled := make([]DopplerData, len(scan))
+	updated := make(map[*Track]bool)
+	for i, sample := range scan {
+		tr := assignment[i]
+		if tr == nil {
+			tk.nextID++
+			tr = &Track{
+				ID:             fmt.Sprintf("T%d", tk.nextID),
+				State:          TrackTentative,
+				Frequency:      sample.Frequency,
+				SignalStrength: sample.SignalStrength,
+				Started:        sample.Timestamp,
+				LastUpdate:     sample.Timestamp,
+			}
+			tk.tracks = append(tk.tracks, tr)
+		} else {
+			tk.update(tr, sample)
+		}
+		tr.Hits++
+		sample.ID = fmt.Sprintf("%s-%d", tr.ID, tr.Hits)
+		tr.history = append(tr.history, sample)
+		if tk.config.MaxHistory > 0 && len(tr.history) > tk.config.MaxHistory {
+			tr.history = tr.history[len(tr.history)-tk.config.MaxHistory:]
+		}
+		if tr.Hits >= tk.config.ConfirmHits {
+			tr.State = TrackConfirmed
+		}
+		updated[tr] = true
+		labelled[i] = sample
+	}
+
+	live := tk.tracks[:0]
+	for _, tr := range tk.tracks {
+		if !updated[tr] {
+			silence := now.Sub(tr.LastUpdate)
+			switch {
+			case tr.State == TrackTentative && silence > tk.config.CoastAfter,
+				tr.State == TrackCoasting && silence > tk.config.DropAfter:
+				tr.State = TrackDropped
+			case tr.State == TrackConfirmed && silence > tk.config.CoastAfter:
+				tr.State = TrackCoasting
+			}
+		}
+		if tr.State == TrackDropped {
+			tk.retire(tr)
+			continue
+		}
+		live = append(live, tr)
+	}
+	tk.tracks = live
+	return labelled
+}
+
+// assign solves the gated scan-to-track assignment and returns the track for each sample,
+// or nil when the sample starts a new track.
+func (tk *Tracker) assign(scan []DopplerData) []*Track {
+	assignment := make([]*Track, len(scan))
+	if len(tk.tracks) == 0 {
+		return assignment
+	}
+
+	// Costs above 1 are outside the gate; forbidden pairs get a cost no real match can reach.
+	const forbidden = 1e9
+	cost := make([][]float64, len(scan))
+	for i, sample := range scan {
+		cost[i] = make([]float64, len(tk.tracks))
+		for j, tr := range tk.tracks {
+			cost[i][j] = forbidden
+			dt := sample.Timestamp.Sub(tr.LastUpdate).Seconds()
+			if dt < 0 {
+				continue
+			}
+			gate := tk.config.FrequencyGate
+			if !tr.hasRate {
+				gate += tk.config.MaxFrequencyRate * dt
+			}
+			residual := (sample.Frequency - tr.predict(sample.Timestamp)) / gate
+			if math.Abs(residual) > 1 {
+				continue
+			}
+			strength := sample.SignalStrength - tr.SignalStrength
+			cost[i][j] = residual*residual + tk.config.StrengthWeight*strength*strength
+		}
+	}
+
+	for i, j := range hungarian(cost) {
+		if j >= 0 && cost[i][j] < forbidden {
+			assignment[i] = tk.tracks[j]
+		}
+	}
+	return assignment
+}
+
+// update folds an associated sample into the track's alpha-beta filter.
+func (tk *Tracker) update(tr *Track, sample DopplerData) {
+	dt := sample.Timestamp.Sub(tr.LastUpdate).Seconds()
+	switch {
+	case dt <= 0:
+		tr.Frequency += tk.config.Alpha * (sample.Frequency - tr.Frequency)
+	case !tr.hasRate:
+		// Second sample: initialise the rate from the finite difference.
+		tr.FrequencyRate = (sample.Frequency - tr.Frequency) / dt
+		tr.Frequency = sample.Frequency
+		tr.hasRate = true
+	default:
+		predicted := tr.predict(sample.Timestamp)
+		residual := sample.Frequency - predicted
+		tr.Frequency = predicted + tk.config.Alpha*residual
+		tr.FrequencyRate += tk.config.Beta * residual / dt
+	}
+	tr.SignalStrength += 0.3 * (sample.SignalStrength - tr.SignalStrength)
+	if sample.Timestamp.After(tr.LastUpdate) {
+		tr.LastUpdate = sample.Timestamp
+	}
+	if tr.State == TrackCoasting {
+		tr.State = TrackConfirmed
+	}
+}
+
+// retire moves a dropped track to the bounded dropped list.
+func (tk *Tracker) retire(tr *Track) {
+	if tk.config.MaxDroppedTracks <= 0 {
+		return
+	}
+	tk.dropped = append(tk.dropped, tr)
+	if len(tk.dropped) > tk.config.MaxDroppedTracks {
+		tk.dropped = tk.dropped[len(tk.dropped)-tk.config.MaxDroppedTracks:]
+	}
+}
+
+// Tracks returns copies of the tracks in the given states, or of all tracks when none are given.
+// The copies do not include history; use History for that.
+func (tk *Tracker) Tracks(states ...TrackState) []Track {
+	tk.mutex.Lock()
+	defer tk.mutex.Unlock()
+
+	want := func(s TrackState) bool {
+		if len(states) == 0 {
+			return true
+		}
+		for _, st := range states {
+			if st == s {
+				return true
+			}
+		}
+		return false
+	}
+	var out []Track
+	for _, tr := range append(append([]*Track(nil), tk.dropped...), tk.tracks...) {
+		if want(tr.State) {
+			c := *tr
+			c.history = nil
+			out = append(out, c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Started.Before(out[j].Started) })
+	return out
+}
+
+// History returns a track's samples with timestamps in [from, to]. Zero times leave that end open.
+func (tk *Tracker) History(trackID string, from, to time.Time) ([]DopplerData, error) {
+	tk.mutex.Lock()
+	defer tk.mutex.Unlock()
+
+	for _, tr := range append(append([]*Track(nil), tk.tracks...), tk.dropped...) {
+		if tr.ID != trackID {
+			continue
+		}
+		var out []DopplerData
+		for _, s := range tr.history {
+			if (from.IsZero() || !s.Timestamp.Before(from)) && (to.IsZero() || !s.Timestamp.After(to)) {
+				out = append(out, s)
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unknown track %q", trackID)
+}
+
+// TrackIDOf returns the track part of a sample ID assigned by ProcessScan.
+func TrackIDOf(sampleID string) string {
+	if i := strings.LastIndexByte(sampleID, '-'); i > 0 {
+		return sampleID[:i]
+	}
+	return sampleID
+}
+
+// AddScan associates a scan with the tracker and adds the labelled samples to the sorter.
+func (ds *DopplerSorter) AddScan(tracker *Tracker, scan []DopplerData) {
+	for _, sample := range tracker.ProcessScan(scan) {
+		ds.AddData(sample)
+	}
+}
+
+// hungarian solves the rectangular assignment problem, minimising total cost. It returns,
+// for each row, the assigned column or -1 when there are more rows than columns.
+func hungarian(cost [][]float64) []int {
+	rows := len(cost)
+	if rows == 0 {
+		return nil
+	}
+	cols := len(cost[0])
+	transposed := rows > cols
+	if transposed {
+		t := make([][]float64, cols)
+		for j := range t {
+			t[j] = make([]float64, rows)
+			for i := range cost {
+				t[j][i] = cost[i][j]
+			}
+		}
+		cost, rows, cols = t, cols, rows
+	}
+
+	// Shortest augmenting path with potentials, 1-indexed; requires rows <= cols.
+	u := make([]float64, rows+1)
+	v := make([]float64, cols+1)
+	match := make([]int, cols+1) // match[col] = row
+	way := make([]int, cols+1)
+	for i := 1; i <= rows; i++ {
+		match[0] = i
+		j0 := 0
+		minv := make([]float64, cols+1)
+		used := make([]bool, cols+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+		for {
+			used[j0] = true
+			i0, delta, j1 := match[j0], math.Inf(1), 0
+			for j := 1; j <= cols; j++ {
+				if used[j] {
+					continue
+				}
+				if c := cost[i0-1][j-1] - u[i0] - v[j]; c < minv[j] {
+					minv[j], way[j] = c, j0
+				}
+				if minv[j] < delta {
+					delta, j1 = minv[j], j
+				}
+			}
+			for j := 0; j <= cols; j++ {
+				if used[j] {
+					u[match[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if match[j0] == 0 {
+				break
+			}
+		}
+		for j0 != 0 {
+			j1 := way[j0]
+			match[j0] = match[j1]
+			j0 = j1
+		}
+	}
+
+	if transposed {
+		result := make([]int, cols)
+		for i := range result {
+			result[i] = -1
+		}
+		for j := 1; j <= cols; j++ {
+			if match[j] > 0 {
+				result[j-1] = match[j] - 1
+			}
+		}
+		return result
+	}
+	result := make([]int, rows)
+	for j := 1; j <= cols; j++ {
+		if match[j] > 0 {
+			result[match[j]-1] = j - 1
+		}
+	}
+	return result
+}
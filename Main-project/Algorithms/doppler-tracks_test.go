@@ -0,0 +1,54 @@
+package communication
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// TestTrackAssociation separates two beacons with crossing Doppler curves plus clutter.
+func TestTrackAssociation(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	tracker := NewTracker(DefaultTrackerConfig())
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	truth := make(map[string]int) // sample ID -> emitter index
+	for step := 0; step < 300; step++ {
+		ts := start.Add(time.Duration(step) * time.Second)
+		tSec := float64(step)
+		scan := []DopplerData{
+			{Frequency: 2.2e9 + 20e3 - 120*tSec + rng.NormFloat64()*50, SignalStrength: 0.9, Timestamp: ts},
+			{Frequency: 2.2e9 - 15e3 + 100*tSec + rng.NormFloat64()*50, SignalStrength: 0.5, Timestamp: ts},
+		}
+		if step%37 == 0 {
+			scan = append(scan, DopplerData{Frequency: 2.2e9 + 80e3 + rng.Float64()*20e3, SignalStrength: 0.2, Timestamp: ts})
+		}
+		for i, s := range tracker.ProcessScan(scan) {
+			truth[s.ID] = i
+		}
+	}
+
+	// Each emitter should map to a single confirmed track.
+	owners := make([]map[string]int, 2)
+	for i := range owners {
+		owners[i] = make(map[string]int)
+	}
+	for id, emitter := range truth {
+		if emitter < 2 {
+			owners[emitter][TrackIDOf(id)]++
+		}
+	}
+	for emitter, counts := range owners {
+		best, total := 0, 0
+		for _, n := range counts {
+			total += n
+			if n > best {
+				best = n
+			}
+		}
+		if purity := float64(best) / float64(total); purity < 0.95 {
+			t.Errorf("emitter %d: %.1f%% of samples on its main track (%d tracks touched), want at least 95%%",
+				emitter, purity*100, len(counts))
+		}
+	}
+}
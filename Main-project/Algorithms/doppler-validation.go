@@ -0,0 +1,291 @@
+package communication
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Severity ranks how serious a validation finding is.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+// String returns the log label for the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "INFO"
+	case SeverityWarning:
+		return "WARNING"
+	case SeverityError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// ValidationError describes a single rule violation for one DopplerData sample.
+type ValidationError struct {
+	Rule      string
+	Severity  Severity
+	SampleID  string
+	Timestamp time.Time
+	Message   string
+}
+
+// Error formats the violation as a log line.
+func (ve ValidationError) Error() string {
+	return fmt.Sprintf("[%s] %s: %s (data ID %s at %v)", ve.Severity, ve.Rule, ve.Message, ve.SampleID, ve.Timestamp)
+}
+
+// maxSeenIDs is how many sample IDs the duplicate check remembers; the oldest are forgotten first.
+const maxSeenIDs = 100000
+
+// ValidationState exposes what a Validator has already seen to stateful rules.
+type ValidationState struct {
+	previous    DopplerData
+	hasPrevious bool
+	seenIDs     map[string]uint64 // ID to the sequence number it was last seen at
+	seenOrder   []seenID          // Oldest first; may still hold forgotten or re-seen IDs
+	seen        uint64
+}
+
+// seenID is one entry of the order in which IDs were seen.
+type seenID struct {
+	id  string
+	seq uint64
+}
+
+// remember records the ID as seen and forgets the oldest IDs beyond maxSeenIDs.
+func (vs *ValidationState) remember(id string) {
+	vs.seen++
+	vs.seenIDs[id] = vs.seen
+	vs.seenOrder = append(vs.seenOrder, seenID{id, vs.seen})
+	for len(vs.seenIDs) > maxSeenIDs {
+		oldest := vs.seenOrder[0]
+		vs.seenOrder = vs.seenOrder[1:]
+		if vs.seenIDs[oldest.id] == oldest.seq {
+			delete(vs.seenIDs, oldest.id)
+		}
+	}
+	// Drop stale entries once they make up half the order
+	if len(vs.seenOrder) > 2*len(vs.seenIDs)+64 {
+		kept := make([]seenID, 0, len(vs.seenIDs))
+		for _, e := range vs.seenOrder {
+			if vs.seenIDs[e.id] == e.seq {
+				kept = append(kept, e)
+			}
+		}
+		vs.seenOrder = kept
+	}
+}
+
+// Previous returns the last sample validated before the current one, in arrival order.
+func (vs *ValidationState) Previous() (DopplerData, bool) {
+	return vs.previous, vs.hasPrevious
+}
+
+// Seen reports whether a sample with the given ID has already been validated.
+func (vs *ValidationState) Seen(id string) bool {
+	_, ok := vs.seenIDs[id]
+	return ok
+}
+
+// ValidationRule checks one sample and returns nil when the sample passes.
+type ValidationRule interface {
+	Name() string
+	Check(sample DopplerData, state *ValidationState) *ValidationError
+}
+
+// RangeRule rejects samples whose extracted field falls outside [Min, Max].
+type RangeRule struct {
+	RuleName     string
+	Field        string
+	Extract      func(DopplerData) float64
+	Min          float64
+	Max          float64
+	MinExclusive bool // Reject values equal to Min as well
+	Severity     Severity
+}
+
+// Name returns the rule name.
+func (r RangeRule) Name() string { return r.RuleName }
+
+// Check validates the extracted field against the configured bounds.
+func (r RangeRule) Check(sample DopplerData, _ *ValidationState) *ValidationError {
+	value := r.Extract(sample)
+	tooLow := value < r.Min || (r.MinExclusive && value == r.Min)
+	if math.IsNaN(value) || tooLow || value > r.Max {
+		return newValidationError(r.RuleName, r.Severity, sample,
+			fmt.Sprintf("%s %.4g outside range [%.4g, %.4g]", r.Field, value, r.Min, r.Max))
+	}
+	return nil
+}
+
+// DopplerConsistencyRule checks that Frequency matches DopplerEffect of Velocity within Tolerance.
+type DopplerConsistencyRule struct {
+	RestFrequency float64 // Carrier frequency at zero velocity, in Hz
+	SpeedOfLight  float64 // in m/s
+	Tolerance     float64 // Allowed mismatch, in Hz
+	Severity      Severity
+}
+
+// Name returns the rule name.
+func (r DopplerConsistencyRule) Name() string { return "doppler-consistency" }
+
+// Check compares the measured frequency with the one predicted from the velocity.
+func (r DopplerConsistencyRule) Check(sample DopplerData, _ *ValidationState) *ValidationError {
+	expected := DopplerEffect(r.RestFrequency, sample.Velocity, r.SpeedOfLight)
+	if diff := math.Abs(sample.Frequency - expected); diff > r.Tolerance {
+		return newValidationError(r.Name(), r.Severity, sample,
+			fmt.Sprintf("frequency %.2f Hz differs from expected %.2f Hz by %.2f Hz", sample.Frequency, expected, diff))
+	}
+	return nil
+}
+
+// RateOfChangeRule limits how fast a field may change between consecutive samples.
+type RateOfChangeRule struct {
+	RuleName string
+	Field    string
+	Extract  func(DopplerData) float64
+	MaxRate  float64 // Maximum absolute change per second
+	Severity Severity
+}
+
+// Name returns the rule name.
+func (r RateOfChangeRule) Name() string { return r.RuleName }
+
+// Check compares the sample against the previously validated one.
+func (r RateOfChangeRule) Check(sample DopplerData, state *ValidationState) *ValidationError {
+	prev, ok := state.Previous()
+	if !ok {
+		return nil
+	}
+	dt := sample.Timestamp.Sub(prev.Timestamp).Seconds()
+	if dt <= 0 {
+		return nil // Ordering problems are reported by MonotonicTimestampRule
+	}
+	rate := math.Abs(r.Extract(sample)-r.Extract(prev)) / dt
+	if rate > r.MaxRate {
+		return newValidationError(r.RuleName, r.Severity, sample,
+			fmt.Sprintf("%s changing at %.4g/s exceeds limit %.4g/s", r.Field, rate, r.MaxRate))
+	}
+	return nil
+}
+
+// DuplicateIDRule flags samples whose ID has already been validated.
+type DuplicateIDRule struct {
+	Severity Severity
+}
+
+// Name returns the rule name.
+func (r DuplicateIDRule) Name() string { return "duplicate-id" }
+
+// Check looks the sample ID up in the validator state.
+func (r DuplicateIDRule) Check(sample DopplerData, state *ValidationState) *ValidationError {
+	if state.Seen(sample.ID) {
+		return newValidationError(r.Name(), r.Severity, sample, "duplicate sample ID")
+	}
+	return nil
+}
+
+// MonotonicTimestampRule flags samples that arrive with a timestamp not after the previous one.
+type MonotonicTimestampRule struct {
+	AllowEqual bool
+	Severity   Severity
+}
+
+// Name returns the rule name.
+func (r MonotonicTimestampRule) Name() string { return "timestamp-monotonic" }
+
+// Check compares the sample timestamp with the previously validated one.
+func (r MonotonicTimestampRule) Check(sample DopplerData, state *ValidationState) *ValidationError {
+	prev, ok := state.Previous()
+	if !ok {
+		return nil
+	}
+	if sample.Timestamp.Before(prev.Timestamp) || (!r.AllowEqual && sample.Timestamp.Equal(prev.Timestamp)) {
+		return newValidationError(r.Name(), r.Severity, sample,
+			fmt.Sprintf("timestamp not after previous sample %s at %v", prev.ID, prev.Timestamp))
+	}
+	return nil
+}
+
+// DefaultValidationRules returns the checks ValidateData has always applied.
+func DefaultValidationRules() []ValidationRule {
+	return []ValidationRule{
+		RangeRule{
+			RuleName:     "frequency-range",
+			Field:        "frequency",
+			Extract:      func(d DopplerData) float64 { return d.Frequency },
+			Min:          0,
+			Max:          math.Inf(1),
+			MinExclusive: true,
+			Severity:     SeverityError,
+		},
+		RangeRule{
+			RuleName: "signal-strength-range",
+			Field:    "signal strength",
+			Extract:  func(d DopplerData) float64 { return d.SignalStrength },
+			Min:      0,
+			Max:      math.Inf(1),
+			Severity: SeverityError,
+		},
+		DuplicateIDRule{Severity: SeverityWarning},
+	}
+}
+
+// Validator applies a set of rules to samples in arrival order.
+type Validator struct {
+	rules []ValidationRule
+	state ValidationState
+}
+
+// NewValidator creates a Validator with the given rules.
+func NewValidator(rules ...ValidationRule) *Validator {
+	return &Validator{
+		rules: rules,
+		state: ValidationState{seenIDs: make(map[string]uint64)},
+	}
+}
+
+// Validate runs every rule against the sample and records it as seen. The duplicate check
+// remembers the newest maxSeenIDs IDs.
+func (v *Validator) Validate(sample DopplerData) []ValidationError {
+	var findings []ValidationError
+	for _, rule := range v.rules {
+		if ve := rule.Check(sample, &v.state); ve != nil {
+			findings = append(findings, *ve)
+		}
+	}
+	v.state.previous = sample
+	v.state.hasPrevious = true
+	v.state.remember(sample.ID)
+	return findings
+}
+
+// Forget drops an ID from the duplicate check, e.g. once the sample has been evicted.
+func (v *Validator) Forget(id string) {
+	delete(v.state.seenIDs, id)
+}
+
+// Reset forgets every sample validated so far.
+func (v *Validator) Reset() {
+	v.state = ValidationState{seenIDs: make(map[string]uint64)}
+}
+
+// newValidationError builds a ValidationError for the given sample.
+func newValidationError(rule string, severity Severity, sample DopplerData, message string) *ValidationError {
+	return &ValidationError{
+		Rule:      rule,
+		Severity:  severity,
+		SampleID:  sample.ID,
+		Timestamp: sample.Timestamp,
+		Message:   message,
+	}
+}
@@ -0,0 +1,130 @@
+package communication
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"math/cmplx"
+)
+
+// FFT returns the discrete Fourier transform of x. The length of x must be a power of two.
+func FFT(x []complex128) ([]complex128, error) {
+	out := append([]complex128(nil), x...)
+	if err := fftInPlace(out, false); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// IFFT returns the inverse discrete Fourier transform of x, scaled by 1/len(x).
+func IFFT(x []complex128) ([]complex128, error) {
+	out := append([]complex128(nil), x...)
+	if err := fftInPlace(out, true); err != nil {
+		return nil, err
+	}
+	scale := complex(1/float64(len(out)), 0)
+	for i := range out {
+		out[i] *= scale
+	}
+	return out, nil
+}
+
+// fftInPlace is an iterative radix-2 Cooley-Tukey transform.
+func fftInPlace(x []complex128, inverse bool) error {
+	n := len(x)
+	if n == 0 || n&(n-1) != 0 {
+		return fmt.Errorf("FFT length %d is not a power of two", n)
+	}
+	shift := 64 - uint(bits.TrailingZeros(uint(n)))
+	for i := 0; i < n; i++ {
+		if j := int(bits.Reverse64(uint64(i)) >> shift); i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Rect(1, sign*2*math.Pi/float64(size))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := x[start+k], w*x[start+k+size/2]
+				x[start+k] = even + odd
+				x[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+	return nil
+}
+
+// nextPowerOfTwo returns the smallest power of two not less than n.
+func nextPowerOfTwo(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
+
+// WindowType selects a spectral window.
+type WindowType int
+
+const (
+	WindowRectangular WindowType = iota
+	WindowHann
+	WindowHamming
+	WindowBlackman
+)
+
+// String returns the window name.
+func (w WindowType) String() string {
+	switch w {
+	case WindowRectangular:
+		return "rectangular"
+	case WindowHann:
+		return "hann"
+	case WindowHamming:
+		return "hamming"
+	case WindowBlackman:
+		return "blackman"
+	default:
+		return fmt.Sprintf("WindowType(%d)", int(w))
+	}
+}
+
+// mainLobeHalfWidth is the half-width of the window's main lobe in unpadded FFT bins.
+func (w WindowType) mainLobeHalfWidth() int {
+	switch w {
+	case WindowHann, WindowHamming:
+		return 2
+	case WindowBlackman:
+		return 3
+	default:
+		return 1
+	}
+}
+
+// Window returns n coefficients of the given window.
+func Window(kind WindowType, n int) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		if n == 1 {
+			w[i] = 1
+			continue
+		}
+		x := 2 * math.Pi * float64(i) / float64(n-1)
+		switch kind {
+		case WindowHann:
+			w[i] = 0.5 - 0.5*math.Cos(x)
+		case WindowHamming:
+			w[i] = 0.54 - 0.46*math.Cos(x)
+		case WindowBlackman:
+			w[i] = 0.42 - 0.5*math.Cos(x) + 0.08*math.Cos(2*x)
+		default:
+			w[i] = 1
+		}
+	}
+	return w
+}
@@ -0,0 +1,450 @@
+package communication
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// Filter processes a stream of complex samples block by block, keeping its state between
+// calls. Decimating filters return fewer samples than they are given.
+type Filter interface {
+	Process(block []complex128) []complex128
+	Reset()
+}
+
+// FilterKind selects the frequency response of a designed filter.
+type FilterKind int
+
+const (
+	FilterLowpass FilterKind = iota
+	FilterHighpass
+	FilterBandpass
+	FilterBandstop
+)
+
+// String returns the filter kind.
+func (k FilterKind) String() string {
+	switch k {
+	case FilterLowpass:
+		return "lowpass"
+	case FilterHighpass:
+		return "highpass"
+	case FilterBandpass:
+		return "bandpass"
+	case FilterBandstop:
+		return "bandstop"
+	default:
+		return fmt.Sprintf("FilterKind(%d)", int(k))
+	}
+}
+
+// DesignFIR returns windowed-sinc FIR taps. Lowpass and highpass filters use cutoff as the
+// edge; bandpass and bandstop filters use cutoff to high. Frequencies are in Hz. Highpass and
+// bandstop filters need an odd number of taps.
+func DesignFIR(kind FilterKind, sampleRate, cutoff, high float64, taps int, window WindowType) ([]float64, error) {
+	nyquist := sampleRate / 2
+	if taps < 3 {
+		return nil, fmt.Errorf("FIR filter needs at least 3 taps, got %d", taps)
+	}
+	if cutoff <= 0 || cutoff >= nyquist {
+		return nil, fmt.Errorf("cutoff %v Hz must be in (0, %v) Hz", cutoff, nyquist)
+	}
+	if (kind == FilterBandpass || kind == FilterBandstop) && (high <= cutoff || high >= nyquist) {
+		return nil, fmt.Errorf("upper edge %v Hz must be in (%v, %v) Hz", high, cutoff, nyquist)
+	}
+	if (kind == FilterHighpass || kind == FilterBandstop) && taps%2 == 0 {
+		return nil, fmt.Errorf("%v FIR filter needs an odd number of taps, got %d", kind, taps)
+	}
+
+	w := Window(window, taps)
+	lowpass := func(fc float64) []float64 {
+		h := make([]float64, taps)
+		var sum float64
+		for n := range h {
+			x := float64(n) - float64(taps-1)/2
+			h[n] = 2 * fc / sampleRate * sinc(2*fc/sampleRate*x) * w[n]
+			sum += h[n]
+		}
+		for n := range h {
+			h[n] /= sum // Unit gain at DC
+		}
+		return h
+	}
+	// invert turns a response into its complement, delta minus h.
+	invert := func(h []float64) []float64 {
+		for n := range h {
+			h[n] = -h[n]
+		}
+		h[taps/2]++
+		return h
+	}
+
+	switch kind {
+	case FilterLowpass:
+		return lowpass(cutoff), nil
+	case FilterHighpass:
+		return invert(lowpass(cutoff)), nil
+	case FilterBandpass, FilterBandstop:
+		upper, lower := lowpass(high), lowpass(cutoff)
+		for n := range upper {
+			upper[n] -= lower[n]
+		}
+		if kind == FilterBandstop {
+			return invert(upper), nil
+		}
+		return upper, nil
+	default:
+		return nil, fmt.Errorf("unknown filter kind %v", kind)
+	}
+}
+
+// sinc is sin(πx)/(πx).
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// FIRFilter is a streaming FIR filter.
+type FIRFilter struct {
+	taps  []float64
+	delay []complex128 // Circular delay line
+	next  int
+}
+
+// NewFIRFilter builds a streaming filter from taps, for example from DesignFIR.
+func NewFIRFilter(taps []float64) *FIRFilter {
+	return &FIRFilter{
+		taps:  append([]float64(nil), taps...),
+		delay: make([]complex128, len(taps)),
+	}
+}
+
+// Taps returns a copy of the filter coefficients.
+func (f *FIRFilter) Taps() []float64 {
+	return append([]float64(nil), f.taps...)
+}
+
+// Process filters a block.
+func (f *FIRFilter) Process(block []complex128) []complex128 {
+	out := make([]complex128, len(block))
+	n := len(f.taps)
+	for i, x := range block {
+		f.delay[f.next] = x
+		var acc complex128
+		k := f.next
+		for _, h := range f.taps {
+			acc += complex(h, 0) * f.delay[k]
+			if k--; k < 0 {
+				k = n - 1
+			}
+		}
+		out[i] = acc
+		f.next = (f.next + 1) % n
+	}
+	return out
+}
+
+// Reset clears the delay line.
+func (f *FIRFilter) Reset() {
+	for i := range f.delay {
+		f.delay[i] = 0
+	}
+	f.next = 0
+}
+
+// Biquad is one second-order IIR section, H(z) = (B0 + B1 z⁻¹ + B2 z⁻²)/(1 + A1 z⁻¹ + A2 z⁻²),
+// run in transposed direct form II.
+type Biquad struct {
+	B0, B1, B2 float64
+	A1, A2     float64
+	s1, s2     complex128
+}
+
+// step filters one sample.
+func (b *Biquad) step(x complex128) complex128 {
+	y := complex(b.B0, 0)*x + b.s1
+	b.s1 = complex(b.B1, 0)*x - complex(b.A1, 0)*y + b.s2
+	b.s2 = complex(b.B2, 0)*x - complex(b.A2, 0)*y
+	return y
+}
+
+// response evaluates the section's transfer function at z.
+func (b *Biquad) response(z complex128) complex128 {
+	zi := 1 / z
+	num := complex(b.B0, 0) + complex(b.B1, 0)*zi + complex(b.B2, 0)*zi*zi
+	den := 1 + complex(b.A1, 0)*zi + complex(b.A2, 0)*zi*zi
+	return num / den
+}
+
+// IIRFilter is a streaming cascade of biquad sections.
+type IIRFilter struct {
+	Sections []Biquad
+}
+
+// Process filters a block.
+func (f *IIRFilter) Process(block []complex128) []complex128 {
+	out := make([]complex128, len(block))
+	for i, x := range block {
+		for k := range f.Sections {
+			x = f.Sections[k].step(x)
+		}
+		out[i] = x
+	}
+	return out
+}
+
+// Reset clears the section states.
+func (f *IIRFilter) Reset() {
+	for k := range f.Sections {
+		f.Sections[k].s1, f.Sections[k].s2 = 0, 0
+	}
+}
+
+// Response returns the complex frequency response at f Hz.
+func (f *IIRFilter) Response(freq, sampleRate float64) complex128 {
+	z := cmplx.Rect(1, 2*math.Pi*freq/sampleRate)
+	h := complex(1, 0)
+	for k := range f.Sections {
+		h *= f.Sections[k].response(z)
+	}
+	return h
+}
+
+// DesignButterworth returns an order-n Butterworth lowpass or highpass filter with its
+// −3 dB point at cutoff Hz. Cascade a lowpass and a highpass for a bandpass response.
+func DesignButterworth(kind FilterKind, order int, cutoff, sampleRate float64) (*IIRFilter, error) {
+	if order < 1 {
+		return nil, fmt.Errorf("filter order must be positive, got %d", order)
+	}
+	poles := make([]complex128, order)
+	for k := range poles {
+		poles[k] = cmplx.Rect(1, math.Pi*float64(2*k+order+1)/float64(2*order))
+	}
+	return bilinearSections(kind, poles, 1, cutoff, sampleRate)
+}
+
+// DesignChebyshev returns an order-n Chebyshev type I lowpass or highpass filter with
+// rippleDB of passband ripple, whose passband ends at cutoff Hz.
+func DesignChebyshev(kind FilterKind, order int, rippleDB, cutoff, sampleRate float64) (*IIRFilter, error) {
+	if order < 1 {
+		return nil, fmt.Errorf("filter order must be positive, got %d", order)
+	}
+	if rippleDB <= 0 {
+		return nil, fmt.Errorf("passband ripple must be positive, got %v dB", rippleDB)
+	}
+	epsilon := math.Sqrt(math.Pow(10, rippleDB/10) - 1)
+	mu := math.Asinh(1/epsilon) / float64(order)
+	poles := make([]complex128, order)
+	for k := range poles {
+		theta := math.Pi * float64(2*k+1) / float64(2*order)
+		poles[k] = complex(-math.Sinh(mu)*math.Sin(theta), math.Cosh(mu)*math.Cos(theta))
+	}
+	// Even orders start the passband at the bottom of the ripple.
+	gain := 1.0
+	if order%2 == 0 {
+		gain = 1 / math.Sqrt(1+epsilon*epsilon)
+	}
+	return bilinearSections(kind, poles, gain, cutoff, sampleRate)
+}
+
+// bilinearSections maps analog prototype poles (cutoff 1 rad/s) to a digital lowpass or
+// highpass cascade with the bilinear transform, prewarped so the cutoff lands at cutoff Hz.
+// Each section has unit passband gain; gain scales the first one.
+func bilinearSections(kind FilterKind, poles []complex128, gain, cutoff, sampleRate float64) (*IIRFilter, error) {
+	if kind != FilterLowpass && kind != FilterHighpass {
+		return nil, errors.New("IIR designs are lowpass or highpass; cascade them for other responses")
+	}
+	if cutoff <= 0 || cutoff >= sampleRate/2 {
+		return nil, fmt.Errorf("cutoff %v Hz must be in (0, %v) Hz", cutoff, sampleRate/2)
+	}
+	warped := 2 * sampleRate * math.Tan(math.Pi*cutoff/sampleRate)
+	// Zeros sit at z = -1 for a lowpass and z = 1 for a highpass; the passband is the other end.
+	zero, passband := -1.0, complex(1, 0)
+	if kind == FilterHighpass {
+		zero, passband = 1, -1
+	}
+
+	filter := &IIRFilter{}
+	for _, p := range poles {
+		if imag(p) < -1e-12 {
+			continue // Paired with its conjugate
+		}
+		s := p * complex(warped, 0)
+		if kind == FilterHighpass {
+			s = complex(warped, 0) / p
+		}
+		z := (1 + s/complex(2*sampleRate, 0)) / (1 - s/complex(2*sampleRate, 0))
+		var section Biquad
+		if math.Abs(imag(p)) < 1e-12 {
+			section = Biquad{B0: 1, B1: -zero, A1: -real(z)}
+		} else {
+			section = Biquad{B0: 1, B1: -2 * zero, B2: 1, A1: -2 * real(z), A2: real(z)*real(z) + imag(z)*imag(z)}
+		}
+		scale := 1 / cmplx.Abs(section.response(passband))
+		section.B0 *= scale
+		section.B1 *= scale
+		section.B2 *= scale
+		filter.Sections = append(filter.Sections, section)
+	}
+	first := &filter.Sections[0]
+	first.B0 *= gain
+	first.B1 *= gain
+	first.B2 *= gain
+	return filter, nil
+}
+
+// cicFractionBits is the fixed-point scale of CIC samples. Inputs up to about ±2^(42−bit
+// growth) survive the integrators; the wrap-around arithmetic keeps the output exact.
+const cicFractionBits = 20
+
+// CICDecimator is a Hogenauer cascaded integrator-comb decimator. It runs in wrapping int64
+// fixed point so the integrators never lose precision, and its output is scaled to unit DC gain.
+type CICDecimator struct {
+	stages, decimation, delay int
+	integrators               [][2]int64 // Per stage, I and Q
+	combs                     [][][2]int64
+	phase                     int
+	gain                      float64
+}
+
+// NewCICDecimator builds an N-stage CIC decimator with decimation factor R and differential
+// delay M (usually 1).
+func NewCICDecimator(stages, decimation, delay int) (*CICDecimator, error) {
+	if stages < 1 || decimation < 2 || delay < 1 {
+		return nil, fmt.Errorf("CIC needs at least one stage, decimation of at least 2 and a positive delay; got N=%d R=%d M=%d",
+			stages, decimation, delay)
+	}
+	growth := float64(stages) * math.Log2(float64(decimation*delay))
+	if growth > 40 {
+		return nil, fmt.Errorf("CIC bit growth of %.0f bits exceeds the 40 available", growth)
+	}
+	c := &CICDecimator{
+		stages:     stages,
+		decimation: decimation,
+		delay:      delay,
+		gain:       math.Pow(float64(decimation*delay), float64(stages)) * (1 << cicFractionBits),
+	}
+	c.Reset()
+	return c, nil
+}
+
+// Process filters and decimates a block; a block's leftover samples carry over to the next call.
+func (c *CICDecimator) Process(block []complex128) []complex128 {
+	out := make([]complex128, 0, len(block)/c.decimation+1)
+	for _, x := range block {
+		v := [2]int64{
+			int64(math.Round(real(x) * (1 << cicFractionBits))),
+			int64(math.Round(imag(x) * (1 << cicFractionBits))),
+		}
+		for s := range c.integrators {
+			c.integrators[s][0] += v[0]
+			c.integrators[s][1] += v[1]
+			v = c.integrators[s]
+		}
+		if c.phase++; c.phase < c.decimation {
+			continue
+		}
+		c.phase = 0
+		for s := range c.combs {
+			line := c.combs[s]
+			oldest := line[0]
+			copy(line, line[1:])
+			line[len(line)-1] = v
+			v = [2]int64{v[0] - oldest[0], v[1] - oldest[1]}
+		}
+		out = append(out, complex(float64(v[0])/c.gain, float64(v[1])/c.gain))
+	}
+	return out
+}
+
+// Reset clears the integrators and combs.
+func (c *CICDecimator) Reset() {
+	c.integrators = make([][2]int64, c.stages)
+	c.combs = make([][][2]int64, c.stages)
+	for s := range c.combs {
+		c.combs[s] = make([][2]int64, c.delay)
+	}
+	c.phase = 0
+}
+
+// Response returns the CIC's normalised magnitude response at freq Hz of the input rate.
+func (c *CICDecimator) Response(freq, sampleRate float64) float64 {
+	rm := float64(c.decimation * c.delay)
+	x := freq / sampleRate
+	if x == 0 {
+		return 1
+	}
+	return math.Pow(math.Abs(math.Sin(math.Pi*rm*x)/(rm*math.Sin(math.Pi*x))), float64(c.stages))
+}
+
+// AdaptiveCanceller is an LMS or NLMS noise canceller. It filters a reference input that is
+// correlated with the noise in the primary input and subtracts the result, adapting its taps
+// to minimise the output power.
+type AdaptiveCanceller struct {
+	weights    []complex128
+	delay      []complex128 // Reference history, newest first
+	stepSize   float64
+	normalized bool
+}
+
+// NewAdaptiveCanceller builds a canceller with the given number of taps. stepSize is μ; for
+// NLMS it is normalised by the reference power and should lie in (0, 2).
+func NewAdaptiveCanceller(taps int, stepSize float64, normalized bool) (*AdaptiveCanceller, error) {
+	if taps < 1 {
+		return nil, fmt.Errorf("canceller needs at least one tap, got %d", taps)
+	}
+	if stepSize <= 0 || (normalized && stepSize >= 2) {
+		return nil, fmt.Errorf("step size %v out of range", stepSize)
+	}
+	return &AdaptiveCanceller{
+		weights:    make([]complex128, taps),
+		delay:      make([]complex128, taps),
+		stepSize:   stepSize,
+		normalized: normalized,
+	}, nil
+}
+
+// Process cancels the reference-correlated noise in a block of the primary input and returns
+// the cleaned samples.
+func (a *AdaptiveCanceller) Process(primary, reference []complex128) ([]complex128, error) {
+	if len(primary) != len(reference) {
+		return nil, fmt.Errorf("primary has %d samples but reference has %d", len(primary), len(reference))
+	}
+	out := make([]complex128, len(primary))
+	for i := range primary {
+		copy(a.delay[1:], a.delay)
+		a.delay[0] = reference[i]
+		var estimate complex128
+		var power float64
+		for k, w := range a.weights {
+			estimate += cmplx.Conj(w) * a.delay[k]
+			power += real(a.delay[k])*real(a.delay[k]) + imag(a.delay[k])*imag(a.delay[k])
+		}
+		e := primary[i] - estimate
+		mu := a.stepSize
+		if a.normalized {
+			mu /= power + 1e-12
+		}
+		for k := range a.weights {
+			a.weights[k] += complex(mu, 0) * a.delay[k] * cmplx.Conj(e)
+		}
+		out[i] = e
+	}
+	return out, nil
+}
+
+// Weights returns a copy of the adapted taps.
+func (a *AdaptiveCanceller) Weights() []complex128 {
+	return append([]complex128(nil), a.weights...)
+}
+
+// Reset clears the taps and history.
+func (a *AdaptiveCanceller) Reset() {
+	for k := range a.weights {
+		a.weights[k], a.delay[k] = 0, 0
+	}
+}
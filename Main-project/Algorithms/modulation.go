@@ -0,0 +1,134 @@
+package communication
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// Modulation is a symbol constellation.
+type Modulation int
+
+const (
+	ModulationBPSK  Modulation = iota
+	ModulationQPSK             // Gray coded, symbols at odd multiples of 45°
+	ModulationOQPSK            // QPSK with Q delayed by half a symbol
+	Modulation8PSK             // Gray coded, symbols at multiples of 45°
+	ModulationGMSK             // Continuous-phase, modulation index 0.5
+)
+
+// String returns the modulation name.
+func (m Modulation) String() string {
+	switch m {
+	case ModulationBPSK:
+		return "BPSK"
+	case ModulationQPSK:
+		return "QPSK"
+	case ModulationOQPSK:
+		return "OQPSK"
+	case Modulation8PSK:
+		return "8PSK"
+	case ModulationGMSK:
+		return "GMSK"
+	default:
+		return fmt.Sprintf("Modulation(%d)", int(m))
+	}
+}
+
+// BitsPerSymbol returns the number of bits each symbol carries.
+func (m Modulation) BitsPerSymbol() int {
+	switch m {
+	case ModulationQPSK, ModulationOQPSK:
+		return 2
+	case Modulation8PSK:
+		return 3
+	default:
+		return 1
+	}
+}
+
+// Map converts bits to unit-energy symbols, most significant bit first. GMSK symbols are the
+// ±1 NRZ values that drive its frequency pulse. Trailing bits that do not fill a symbol are dropped.
+func (m Modulation) Map(bits []int) []complex128 {
+	k := m.BitsPerSymbol()
+	symbols := make([]complex128, len(bits)/k)
+	for i := range symbols {
+		b := bits[i*k : (i+1)*k]
+		switch m {
+		case ModulationQPSK, ModulationOQPSK:
+			symbols[i] = complex(nrz(b[0])/math.Sqrt2, nrz(b[1])/math.Sqrt2)
+		case Modulation8PSK:
+			position := grayDecode(b[0]<<2 | b[1]<<1 | b[2])
+			symbols[i] = cmplx.Rect(1, math.Pi/4*float64(position))
+		default:
+			symbols[i] = complex(nrz(b[0]), 0)
+		}
+	}
+	return symbols
+}
+
+// Decide returns the unit-energy constellation point nearest y. GMSK is decided on the real
+// axis like BPSK, which suits its differentially detected phase steps.
+func (m Modulation) Decide(y complex128) complex128 {
+	switch m {
+	case ModulationQPSK, ModulationOQPSK:
+		return complex(sign(real(y))/math.Sqrt2, sign(imag(y))/math.Sqrt2)
+	case Modulation8PSK:
+		return cmplx.Rect(1, math.Pi/4*float64(psk8Position(y)))
+	default:
+		return complex(sign(real(y)), 0)
+	}
+}
+
+// Demap converts symbols to bits by hard decision, most significant bit first.
+func (m Modulation) Demap(symbols []complex128) []int {
+	bits := make([]int, 0, len(symbols)*m.BitsPerSymbol())
+	for _, y := range symbols {
+		switch m {
+		case ModulationQPSK, ModulationOQPSK:
+			bits = append(bits, boolBit(real(y) < 0), boolBit(imag(y) < 0))
+		case Modulation8PSK:
+			g := grayEncode(psk8Position(y))
+			bits = append(bits, g>>2&1, g>>1&1, g&1)
+		default:
+			bits = append(bits, boolBit(real(y) < 0))
+		}
+	}
+	return bits
+}
+
+// psk8Position is the index, 0 to 7, of the 8PSK point nearest y.
+func psk8Position(y complex128) int {
+	position := int(math.Round(cmplx.Phase(y)/(math.Pi/4))) % 8
+	if position < 0 {
+		position += 8
+	}
+	return position
+}
+
+// grayEncode returns the Gray code of n.
+func grayEncode(n int) int {
+	return n ^ n>>1
+}
+
+// grayDecode returns the number whose Gray code is g.
+func grayDecode(g int) int {
+	n := 0
+	for ; g != 0; g >>= 1 {
+		n ^= g
+	}
+	return n
+}
+
+// nrz maps bit 0 to +1 and bit 1 to -1.
+func nrz(bit int) float64 {
+	return float64(1 - 2*bit)
+}
+
+// boolBit returns 1 for true and 0 for false.
+func boolBit(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
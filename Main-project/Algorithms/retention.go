@@ -0,0 +1,372 @@
+package communication
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// RetentionPolicy bounds how much history a DopplerSorter or CarrierSync keeps.
+// Ages are measured from the newest sample's Timestamp rather than the wall clock,
+// so replayed recordings are trimmed the same way as live data. Zero values disable a limit.
+//
+// Adding an entry enforces MaxCount immediately; the time-based limits are re-checked once
+// the newest timestamp has advanced by DownsampleInterval (or MaxAge/100 without downsampling).
+type RetentionPolicy struct {
+	MaxCount           int           // Keep at most this many entries, evicting the oldest first
+	MaxAge             time.Duration // Evict entries older than this
+	DownsampleAfter    time.Duration // Average entries older than this into DownsampleInterval buckets
+	DownsampleInterval time.Duration // Bucket width for downsampling, e.g. time.Second for 1 Hz averages
+}
+
+// EvictionReason says why entries left the store.
+type EvictionReason int
+
+const (
+	EvictedMaxAge EvictionReason = iota
+	EvictedMaxCount
+	EvictedDownsampled // Replaced by a bucket average
+)
+
+// String returns the reason label.
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictedMaxAge:
+		return "max-age"
+	case EvictedMaxCount:
+		return "max-count"
+	case EvictedDownsampled:
+		return "downsampled"
+	default:
+		return fmt.Sprintf("EvictionReason(%d)", int(r))
+	}
+}
+
+// eviction is a batch of entries removed for one reason.
+type eviction[T any] struct {
+	entries []T
+	reason  EvictionReason
+}
+
+// retainer applies a RetentionPolicy to a slice of entries while preserving their order.
+type retainer[T any] struct {
+	policy    RetentionPolicy
+	timestamp func(T) time.Time
+	id        func(T) string
+	group     func(T) string // Entries are only averaged with others of the same group; nil means one group
+	// average merges a bucket of entries, weighting each by the raw sample count it represents.
+	average func(bucket []T, weights []int, start time.Time) T
+	onEvict func([]T, EvictionReason)
+	weights map[string]int // Raw sample count behind each averaged entry, by ID
+	nextRun time.Time      // Newest timestamp at which the time-based limits are next due
+}
+
+// due reports whether adding an entry with timestamp ts to a store of count entries
+// should trigger a retention pass.
+func (r *retainer[T]) due(ts time.Time, count int) bool {
+	if r.policy.MaxCount > 0 && count > r.policy.MaxCount {
+		return true
+	}
+	if r.policy.MaxAge <= 0 && r.policy.DownsampleAfter <= 0 {
+		return false
+	}
+	return !ts.Before(r.nextRun)
+}
+
+// checkInterval is how far the newest timestamp advances between time-based passes.
+func (p RetentionPolicy) checkInterval() time.Duration {
+	if p.DownsampleAfter > 0 && p.DownsampleInterval > 0 {
+		return p.DownsampleInterval
+	}
+	return p.MaxAge / 100
+}
+
+// apply trims items according to the policy and returns the survivors with the evictions.
+func (r *retainer[T]) apply(items []T) ([]T, []eviction[T]) {
+	if len(items) == 0 {
+		return items, nil
+	}
+	var evictions []eviction[T]
+	evict := func(reason EvictionReason, entries []T) {
+		if len(entries) == 0 {
+			return
+		}
+		for _, e := range entries {
+			delete(r.weights, r.id(e))
+		}
+		evictions = append(evictions, eviction[T]{entries: entries, reason: reason})
+	}
+
+	newest := r.timestamp(items[0])
+	for _, item := range items[1:] {
+		if ts := r.timestamp(item); ts.After(newest) {
+			newest = ts
+		}
+	}
+	r.nextRun = newest.Add(r.policy.checkInterval())
+
+	if r.policy.MaxAge > 0 {
+		cutoff := newest.Add(-r.policy.MaxAge)
+		var kept, old []T
+		for _, item := range items {
+			if r.timestamp(item).Before(cutoff) {
+				old = append(old, item)
+			} else {
+				kept = append(kept, item)
+			}
+		}
+		items = kept
+		evict(EvictedMaxAge, old)
+	}
+
+	if r.policy.DownsampleAfter > 0 && r.policy.DownsampleInterval > 0 {
+		var replaced []T
+		items, replaced = r.downsample(items, newest.Add(-r.policy.DownsampleAfter))
+		evict(EvictedDownsampled, replaced)
+	}
+
+	if r.policy.MaxCount > 0 && len(items) > r.policy.MaxCount {
+		order := make([]int, len(items))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return r.timestamp(items[order[a]]).Before(r.timestamp(items[order[b]]))
+		})
+		drop := make(map[int]bool, len(items)-r.policy.MaxCount)
+		for _, idx := range order[:len(items)-r.policy.MaxCount] {
+			drop[idx] = true
+		}
+		var kept, old []T
+		for i, item := range items {
+			if drop[i] {
+				old = append(old, item)
+			} else {
+				kept = append(kept, item)
+			}
+		}
+		items = kept
+		evict(EvictedMaxCount, old)
+	}
+
+	return items, evictions
+}
+
+// downsample averages every complete bucket that ended before cutoff. The average takes the
+// slot of the bucket's first member so the surrounding order is preserved.
+func (r *retainer[T]) downsample(items []T, cutoff time.Time) ([]T, []T) {
+	type bucketKey struct {
+		start time.Time
+		group string
+	}
+	interval := r.policy.DownsampleInterval
+	buckets := make(map[bucketKey][]int)
+	for i, item := range items {
+		start := r.timestamp(item).Truncate(interval)
+		if !start.Add(interval).After(cutoff) {
+			key := bucketKey{start: start}
+			if r.group != nil {
+				key.group = r.group(item)
+			}
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	replacement := make(map[int]T)
+	remove := make(map[int]bool)
+	var replaced []T
+	for key, members := range buckets {
+		if len(members) < 2 {
+			continue
+		}
+		bucket := make([]T, len(members))
+		weights := make([]int, len(members))
+		for k, idx := range members {
+			bucket[k] = items[idx]
+			weights[k] = r.weight(items[idx])
+			remove[idx] = true
+		}
+		merged := r.average(bucket, weights, key.start)
+		total := 0
+		for _, w := range weights {
+			total += w
+		}
+		r.weights[r.id(merged)] = total
+		replacement[members[0]] = merged
+		replaced = append(replaced, bucket...)
+	}
+	if len(replaced) == 0 {
+		return items, nil
+	}
+
+	kept := make([]T, 0, len(items)-len(remove)+len(replacement))
+	for i, item := range items {
+		if merged, ok := replacement[i]; ok {
+			kept = append(kept, merged)
+		} else if !remove[i] {
+			kept = append(kept, item)
+		}
+	}
+	return kept, replaced
+}
+
+// weight returns the number of raw samples an entry represents.
+func (r *retainer[T]) weight(item T) int {
+	if w, ok := r.weights[r.id(item)]; ok {
+		return w
+	}
+	return 1
+}
+
+// notify passes each eviction batch to the callback, if one is set.
+func (r *retainer[T]) notify(evictions []eviction[T]) {
+	if r.onEvict == nil {
+		return
+	}
+	for _, e := range evictions {
+		r.onEvict(e.entries, e.reason)
+	}
+}
+
+// downsampledID names the average of the bucket starting at start.
+func downsampledID(start time.Time) string {
+	return fmt.Sprintf("avg-%d", start.UnixNano())
+}
+
+// SetRetentionPolicy bounds the data kept by the sorter. onEvict, if not nil, receives every
+// batch of evicted samples so they can be archived; it is called without the sorter locked.
+func (ds *DopplerSorter) SetRetentionPolicy(policy RetentionPolicy, onEvict func([]DopplerData, EvictionReason)) {
+	ds.mutex.Lock()
+	ds.retention = &retainer[DopplerData]{
+		policy:    policy,
+		timestamp: func(d DopplerData) time.Time { return d.Timestamp },
+		id:        func(d DopplerData) string { return d.ID },
+		average:   averageDopplerData,
+		onEvict:   onEvict,
+		weights:   make(map[string]int),
+	}
+	ds.mutex.Unlock()
+	ds.ApplyRetention()
+}
+
+// ApplyRetention enforces the retention policy now. AddData calls it automatically when due.
+func (ds *DopplerSorter) ApplyRetention() {
+	ds.mutex.Lock()
+	if ds.retention == nil {
+		ds.mutex.Unlock()
+		return
+	}
+	ds.applyRetentionLocked()
+}
+
+// applyRetentionLocked trims the data with the sorter locked, then unlocks it before
+// notifying the eviction callback.
+func (ds *DopplerSorter) applyRetentionLocked() {
+	var evictions []eviction[DopplerData]
+	ds.data, evictions = ds.retention.apply(ds.data)
+	for _, e := range evictions {
+		ds.dropPendingLocked(e.entries)
+		for _, entry := range e.entries {
+			ds.validator.Forget(entry.ID)
+		}
+	}
+	retention := ds.retention
+	ds.mutex.Unlock()
+	retention.notify(evictions)
+}
+
+// averageDopplerData merges a downsampling bucket into one sample.
+func averageDopplerData(bucket []DopplerData, weights []int, start time.Time) DopplerData {
+	var freq, velocity, strength, total float64
+	for i, d := range bucket {
+		w := float64(weights[i])
+		freq += w * d.Frequency
+		velocity += w * d.Velocity
+		strength += w * d.SignalStrength
+		total += w
+	}
+	return DopplerData{
+		Frequency:      freq / total,
+		Velocity:       velocity / total,
+		SignalStrength: strength / total,
+		Timestamp:      start,
+		ID:             downsampledID(start),
+	}
+}
+
+// SetRetentionPolicy bounds the signals kept for synchronization. onEvict, if not nil, receives
+// every batch of evicted signals; it is called without the CarrierSync locked.
+func (cs *CarrierSync) SetRetentionPolicy(policy RetentionPolicy, onEvict func([]CarrierSignal, EvictionReason)) {
+	cs.mutex.Lock()
+	cs.retention = &retainer[CarrierSignal]{
+		policy:    policy,
+		timestamp: func(s CarrierSignal) time.Time { return s.Timestamp },
+		id:        func(s CarrierSignal) string { return s.SignalID },
+		group:     func(s CarrierSignal) string { return s.Channel },
+		average:   averageCarrierSignals,
+		onEvict:   onEvict,
+		weights:   make(map[string]int),
+	}
+	cs.mutex.Unlock()
+	cs.ApplyRetention()
+}
+
+// ApplyRetention enforces the retention policy now. AddSignal calls it automatically when due.
+func (cs *CarrierSync) ApplyRetention() {
+	cs.mutex.Lock()
+	if cs.retention == nil {
+		cs.mutex.Unlock()
+		return
+	}
+	cs.applyRetentionLocked()
+}
+
+// applyRetentionLocked trims the signals with the CarrierSync locked, then unlocks it before
+// notifying the eviction callback.
+func (cs *CarrierSync) applyRetentionLocked() {
+	var evictions []eviction[CarrierSignal]
+	cs.signals, evictions = cs.retention.apply(cs.signals)
+	for _, e := range evictions {
+		for _, entry := range e.entries {
+			delete(cs.snr, entry.SignalID)
+			delete(cs.lowSNR, entry.SignalID)
+			delete(cs.oscillators, entry.SignalID)
+		}
+	}
+	retention := cs.retention
+	cs.mutex.Unlock()
+	retention.notify(evictions)
+}
+
+// averageCarrierSignals merges a downsampling bucket of one channel into one signal. Phase
+// is averaged on the unit circle so values either side of 0/2π do not cancel out.
+func averageCarrierSignals(bucket []CarrierSignal, weights []int, start time.Time) CarrierSignal {
+	var freq, amplitude, noise, sinSum, cosSum, total float64
+	for i, s := range bucket {
+		w := float64(weights[i])
+		freq += w * s.Frequency
+		amplitude += w * s.Amplitude
+		noise += w * s.NoiseLevel
+		sinSum += w * math.Sin(s.Phase)
+		cosSum += w * math.Cos(s.Phase)
+		total += w
+	}
+	phase := math.Atan2(sinSum, cosSum)
+	if phase < 0 {
+		phase += 2 * math.Pi
+	}
+	id := downsampledID(start)
+	if channel := bucket[0].Channel; channel != "" {
+		id += "-" + channel
+	}
+	return CarrierSignal{
+		Frequency:  freq / total,
+		Phase:      phase,
+		Amplitude:  amplitude / total,
+		NoiseLevel: noise / total,
+		SignalID:   id,
+		Channel:    bucket[0].Channel,
+		Timestamp:  start,
+	}
+}
@@ -0,0 +1,759 @@
+package communication
+
+import (
+	"bufio"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SigMF datatypes supported by SigMFReader and SigMFWriter. Integer samples are scaled so
+// that full scale is ±1.
+const (
+	SigMFComplexFloat32   = "cf32_le"
+	SigMFComplexFloat32BE = "cf32_be"
+	SigMFComplexInt16     = "ci16_le"
+	SigMFComplexInt16BE   = "ci16_be"
+	SigMFComplexUint8     = "cu8"
+)
+
+// SigMFVersion is the specification version written to new recordings.
+const SigMFVersion = "1.0.0"
+
+// SigMFMetadata is the content of a .sigmf-meta file.
+type SigMFMetadata struct {
+	Global      SigMFGlobal       `json:"global"`
+	Captures    []SigMFCapture    `json:"captures"`
+	Annotations []SigMFAnnotation `json:"annotations"`
+}
+
+// SigMFGlobal holds the recording-wide fields. Keys from extension namespaces are kept in
+// Extra and written back unchanged.
+type SigMFGlobal struct {
+	Datatype    string  `json:"core:datatype"`
+	SampleRate  float64 `json:"core:sample_rate,omitempty"` // in Hz
+	Version     string  `json:"core:version"`
+	NumChannels int     `json:"core:num_channels,omitempty"`
+	SHA512      string  `json:"core:sha512,omitempty"`
+	Description string  `json:"core:description,omitempty"`
+	Author      string  `json:"core:author,omitempty"`
+	Recorder    string  `json:"core:recorder,omitempty"`
+	HW          string  `json:"core:hw,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// SigMFCapture starts a segment of the recording with its own tuning and start time.
+type SigMFCapture struct {
+	SampleStart int64   `json:"core:sample_start"`
+	Frequency   float64 `json:"core:frequency,omitempty"` // Centre frequency, in Hz
+	Datetime    string  `json:"core:datetime,omitempty"`  // RFC 3339 time of the first sample
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// SigMFAnnotation describes a span of samples.
+type SigMFAnnotation struct {
+	SampleStart   int64   `json:"core:sample_start"`
+	SampleCount   int64   `json:"core:sample_count,omitempty"`
+	FreqLowerEdge float64 `json:"core:freq_lower_edge,omitempty"` // in Hz
+	FreqUpperEdge float64 `json:"core:freq_upper_edge,omitempty"` // in Hz
+	Label         string  `json:"core:label,omitempty"`
+	Comment       string  `json:"core:comment,omitempty"`
+	Generator     string  `json:"core:generator,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes the core fields and keeps the rest in Extra.
+func (g *SigMFGlobal) UnmarshalJSON(data []byte) error {
+	type plain SigMFGlobal
+	if err := json.Unmarshal(data, (*plain)(g)); err != nil {
+		return err
+	}
+	extra, err := sigmfExtra(data, plain{})
+	g.Extra = extra
+	return err
+}
+
+// MarshalJSON encodes the core fields together with Extra.
+func (g SigMFGlobal) MarshalJSON() ([]byte, error) {
+	type plain SigMFGlobal
+	return sigmfJoin(plain(g), g.Extra)
+}
+
+// UnmarshalJSON decodes the core fields and keeps the rest in Extra.
+func (c *SigMFCapture) UnmarshalJSON(data []byte) error {
+	type plain SigMFCapture
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	extra, err := sigmfExtra(data, plain{})
+	c.Extra = extra
+	return err
+}
+
+// MarshalJSON encodes the core fields together with Extra.
+func (c SigMFCapture) MarshalJSON() ([]byte, error) {
+	type plain SigMFCapture
+	return sigmfJoin(plain(c), c.Extra)
+}
+
+// UnmarshalJSON decodes the core fields and keeps the rest in Extra.
+func (a *SigMFAnnotation) UnmarshalJSON(data []byte) error {
+	type plain SigMFAnnotation
+	if err := json.Unmarshal(data, (*plain)(a)); err != nil {
+		return err
+	}
+	extra, err := sigmfExtra(data, plain{})
+	a.Extra = extra
+	return err
+}
+
+// MarshalJSON encodes the core fields together with Extra.
+func (a SigMFAnnotation) MarshalJSON() ([]byte, error) {
+	type plain SigMFAnnotation
+	return sigmfJoin(plain(a), a.Extra)
+}
+
+// sigmfExtra returns the keys of a JSON object that are not fields of the struct known.
+func sigmfExtra(data []byte, known interface{}) (map[string]json.RawMessage, error) {
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	t := reflect.TypeOf(known)
+	for i := 0; i < t.NumField(); i++ {
+		delete(all, strings.Split(t.Field(i).Tag.Get("json"), ",")[0])
+	}
+	if len(all) == 0 {
+		return nil, nil
+	}
+	return all, nil
+}
+
+// sigmfJoin encodes v and adds the extra keys that v does not already set.
+func sigmfJoin(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	for key, value := range extra {
+		if _, ok := all[key]; !ok {
+			all[key] = value
+		}
+	}
+	return json.Marshal(all)
+}
+
+// Time parses the capture's Datetime; ok is false when it is missing or malformed.
+func (c SigMFCapture) Time() (t time.Time, ok bool) {
+	t, err := time.Parse(time.RFC3339Nano, c.Datetime)
+	return t, err == nil
+}
+
+// Capture returns the capture segment containing the given sample.
+func (m *SigMFMetadata) Capture(sample int64) SigMFCapture {
+	var capture SigMFCapture
+	for _, c := range m.Captures {
+		if c.SampleStart > sample {
+			break
+		}
+		capture = c
+	}
+	return capture
+}
+
+// nextCapture returns the first sample of the capture after the one containing sample, or -1.
+func (m *SigMFMetadata) nextCapture(sample int64) int64 {
+	for _, c := range m.Captures {
+		if c.SampleStart > sample {
+			return c.SampleStart
+		}
+	}
+	return -1
+}
+
+// AddAnnotations appends annotations and keeps them ordered by first sample, as the
+// specification requires.
+func (m *SigMFMetadata) AddAnnotations(annotations ...SigMFAnnotation) {
+	m.Annotations = append(m.Annotations, annotations...)
+	sort.SliceStable(m.Annotations, func(i, j int) bool {
+		return m.Annotations[i].SampleStart < m.Annotations[j].SampleStart
+	})
+}
+
+// validate checks the fields the reader and writer depend on.
+func (m *SigMFMetadata) validate() error {
+	if _, err := parseSigMFDatatype(m.Global.Datatype); err != nil {
+		return err
+	}
+	if m.Global.NumChannels > 1 {
+		return fmt.Errorf("multi-channel recordings are not supported, got %d channels", m.Global.NumChannels)
+	}
+	for i := 1; i < len(m.Captures); i++ {
+		if m.Captures[i].SampleStart <= m.Captures[i-1].SampleStart {
+			return fmt.Errorf("capture %d starts at sample %d, not after the previous capture", i, m.Captures[i].SampleStart)
+		}
+	}
+	return nil
+}
+
+// ReadSigMFMetadata decodes and validates SigMF metadata.
+func ReadSigMFMetadata(r io.Reader) (SigMFMetadata, error) {
+	var meta SigMFMetadata
+	if err := json.NewDecoder(r).Decode(&meta); err != nil {
+		return SigMFMetadata{}, fmt.Errorf("decoding SigMF metadata: %w", err)
+	}
+	return meta, meta.validate()
+}
+
+// WriteSigMFMetadata encodes metadata as indented JSON.
+func WriteSigMFMetadata(w io.Writer, meta SigMFMetadata) error {
+	if meta.Captures == nil {
+		meta.Captures = []SigMFCapture{}
+	}
+	if meta.Annotations == nil {
+		meta.Annotations = []SigMFAnnotation{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(meta)
+}
+
+// LoadSigMFMetadata reads the metadata of the recording at path.
+func LoadSigMFMetadata(path string) (SigMFMetadata, error) {
+	file, err := os.Open(sigmfBase(path) + ".sigmf-meta")
+	if err != nil {
+		return SigMFMetadata{}, err
+	}
+	defer file.Close()
+	return ReadSigMFMetadata(bufio.NewReader(file))
+}
+
+// SaveSigMFMetadata replaces the metadata of the recording at path.
+func SaveSigMFMetadata(path string, meta SigMFMetadata) error {
+	file, err := os.Create(sigmfBase(path) + ".sigmf-meta")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := WriteSigMFMetadata(file, meta); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// AppendSigMFAnnotations adds annotations to the metadata of the recording at path.
+func AppendSigMFAnnotations(path string, annotations ...SigMFAnnotation) error {
+	meta, err := LoadSigMFMetadata(path)
+	if err != nil {
+		return err
+	}
+	meta.AddAnnotations(annotations...)
+	return SaveSigMFMetadata(path, meta)
+}
+
+// sigmfBase strips a SigMF extension, so either file of a recording names it.
+func sigmfBase(path string) string {
+	for _, ext := range []string{".sigmf-meta", ".sigmf-data", ".sigmf"} {
+		if strings.HasSuffix(path, ext) {
+			return strings.TrimSuffix(path, ext)
+		}
+	}
+	return path
+}
+
+// sigmfFormat is a decoded complex datatype.
+type sigmfFormat struct {
+	kind  byte // 'f', 'i' or 'u'
+	size  int  // Bytes per component
+	order binary.ByteOrder
+}
+
+// parseSigMFDatatype decodes one of the supported datatypes.
+func parseSigMFDatatype(datatype string) (sigmfFormat, error) {
+	switch datatype {
+	case SigMFComplexFloat32:
+		return sigmfFormat{'f', 4, binary.LittleEndian}, nil
+	case SigMFComplexFloat32BE:
+		return sigmfFormat{'f', 4, binary.BigEndian}, nil
+	case SigMFComplexInt16:
+		return sigmfFormat{'i', 2, binary.LittleEndian}, nil
+	case SigMFComplexInt16BE:
+		return sigmfFormat{'i', 2, binary.BigEndian}, nil
+	case SigMFComplexUint8:
+		return sigmfFormat{'u', 1, binary.LittleEndian}, nil
+	default:
+		return sigmfFormat{}, fmt.Errorf("unsupported SigMF datatype %q", datatype)
+	}
+}
+
+// sampleSize is the number of bytes per complex sample.
+func (f sigmfFormat) sampleSize() int {
+	return 2 * f.size
+}
+
+// decode converts one component.
+func (f sigmfFormat) decode(b []byte) float64 {
+	switch f.kind {
+	case 'f':
+		return float64(math.Float32frombits(f.order.Uint32(b)))
+	case 'i':
+		return float64(int16(f.order.Uint16(b))) / math.MaxInt16
+	default:
+		return (float64(b[0]) - 127.5) / 127.5
+	}
+}
+
+// encode converts one component and reports whether it had to be clipped.
+func (f sigmfFormat) encode(v float64, b []byte) (clipped bool) {
+	switch f.kind {
+	case 'f':
+		f.order.PutUint32(b, math.Float32bits(float32(v)))
+		return false
+	case 'i':
+		q := math.Round(v * math.MaxInt16)
+		clipped = q > math.MaxInt16 || q < -math.MaxInt16
+		f.order.PutUint16(b, uint16(int16(math.Max(-math.MaxInt16, math.Min(math.MaxInt16, q)))))
+		return clipped
+	default:
+		q := math.Round(v*127.5 + 127.5)
+		clipped = q > 255 || q < 0
+		b[0] = byte(math.Max(0, math.Min(255, q)))
+		return clipped
+	}
+}
+
+// SigMFReader streams the samples of a SigMF recording.
+type SigMFReader struct {
+	meta     SigMFMetadata
+	format   sigmfFormat
+	r        io.Reader
+	closer   io.Closer
+	position int64
+	buf      []byte
+}
+
+// NewSigMFReader reads samples described by meta from data.
+func NewSigMFReader(meta SigMFMetadata, data io.Reader) (*SigMFReader, error) {
+	if err := meta.validate(); err != nil {
+		return nil, err
+	}
+	format, _ := parseSigMFDatatype(meta.Global.Datatype)
+	return &SigMFReader{meta: meta, format: format, r: bufio.NewReader(data)}, nil
+}
+
+// OpenSigMF opens the recording at path, which may name either of its files or their
+// common base. Close the reader when done.
+func OpenSigMF(path string) (*SigMFReader, error) {
+	meta, err := LoadSigMFMetadata(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(sigmfBase(path) + ".sigmf-data")
+	if err != nil {
+		return nil, err
+	}
+	reader, err := NewSigMFReader(meta, file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	reader.closer = file
+	return reader, nil
+}
+
+// Metadata returns the recording's metadata.
+func (sr *SigMFReader) Metadata() SigMFMetadata {
+	return sr.meta
+}
+
+// Position returns the index of the next sample to be read.
+func (sr *SigMFReader) Position() int64 {
+	return sr.position
+}
+
+// Read fills samples and returns how many were read. It returns io.EOF once the recording is
+// exhausted and io.ErrUnexpectedEOF if it ends partway through a sample.
+func (sr *SigMFReader) Read(samples []complex128) (int, error) {
+	size := sr.format.sampleSize()
+	if need := len(samples) * size; len(sr.buf) < need {
+		sr.buf = make([]byte, need)
+	}
+	n, err := io.ReadFull(sr.r, sr.buf[:len(samples)*size])
+	count := n / size
+	for i := 0; i < count; i++ {
+		b := sr.buf[i*size:]
+		samples[i] = complex(sr.format.decode(b), sr.format.decode(b[sr.format.size:]))
+	}
+	sr.position += int64(count)
+	switch {
+	case err == io.ErrUnexpectedEOF && n%size == 0:
+		err = nil
+	case err == io.ErrUnexpectedEOF:
+		err = fmt.Errorf("recording ends inside sample %d: %w", sr.position, err)
+	case err == io.EOF && count > 0:
+		err = nil
+	}
+	return count, err
+}
+
+// Close closes the data file opened by OpenSigMF.
+func (sr *SigMFReader) Close() error {
+	if sr.closer == nil {
+		return nil
+	}
+	return sr.closer.Close()
+}
+
+// SigMFWriter writes samples in a SigMF datatype and collects the recording's metadata.
+type SigMFWriter struct {
+	meta     SigMFMetadata
+	format   sigmfFormat
+	w        *bufio.Writer
+	hash     hash.Hash
+	data     io.Closer // Data file opened by CreateSigMF
+	metaPath string
+	samples  int64
+	clipped  int64
+	buf      []byte
+}
+
+// NewSigMFWriter writes samples to data. Close flushes them but does not close data; write
+// the finished Metadata with WriteSigMFMetadata.
+func NewSigMFWriter(data io.Writer, global SigMFGlobal) (*SigMFWriter, error) {
+	if global.Version == "" {
+		global.Version = SigMFVersion
+	}
+	meta := SigMFMetadata{Global: global}
+	if err := meta.validate(); err != nil {
+		return nil, err
+	}
+	format, _ := parseSigMFDatatype(global.Datatype)
+	h := sha512.New()
+	return &SigMFWriter{meta: meta, format: format, w: bufio.NewWriter(io.MultiWriter(data, h)), hash: h}, nil
+}
+
+// CreateSigMF creates the data file of a recording at path. Close writes the metadata file
+// next to it.
+func CreateSigMF(path string, global SigMFGlobal) (*SigMFWriter, error) {
+	base := sigmfBase(path)
+	file, err := os.Create(base + ".sigmf-data")
+	if err != nil {
+		return nil, err
+	}
+	writer, err := NewSigMFWriter(file, global)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	writer.data, writer.metaPath = file, base+".sigmf-meta"
+	return writer, nil
+}
+
+// AddCapture starts a capture segment at the next sample to be written.
+func (sw *SigMFWriter) AddCapture(capture SigMFCapture) {
+	capture.SampleStart = sw.samples
+	if n := len(sw.meta.Captures); n > 0 && sw.meta.Captures[n-1].SampleStart == sw.samples {
+		sw.meta.Captures[n-1] = capture
+		return
+	}
+	sw.meta.Captures = append(sw.meta.Captures, capture)
+}
+
+// Annotate adds annotations to the recording.
+func (sw *SigMFWriter) Annotate(annotations ...SigMFAnnotation) {
+	sw.meta.AddAnnotations(annotations...)
+}
+
+// Write converts and writes samples. Integer datatypes clip components beyond full scale.
+func (sw *SigMFWriter) Write(samples []complex128) error {
+	size := sw.format.sampleSize()
+	if need := len(samples) * size; len(sw.buf) < need {
+		sw.buf = make([]byte, need)
+	}
+	for i, x := range samples {
+		b := sw.buf[i*size:]
+		re := sw.format.encode(real(x), b)
+		im := sw.format.encode(imag(x), b[sw.format.size:])
+		if re || im {
+			sw.clipped++
+		}
+	}
+	if _, err := sw.w.Write(sw.buf[:len(samples)*size]); err != nil {
+		return err
+	}
+	sw.samples += int64(len(samples))
+	return nil
+}
+
+// Samples returns the number of samples written.
+func (sw *SigMFWriter) Samples() int64 {
+	return sw.samples
+}
+
+// Clipped returns the number of samples that exceeded the datatype's full scale.
+func (sw *SigMFWriter) Clipped() int64 {
+	return sw.clipped
+}
+
+// Metadata returns the metadata for the samples written so far, including their SHA-512
+// once the writer is closed.
+func (sw *SigMFWriter) Metadata() SigMFMetadata {
+	meta := sw.meta
+	meta.Captures = append([]SigMFCapture(nil), sw.meta.Captures...)
+	meta.Annotations = append([]SigMFAnnotation(nil), sw.meta.Annotations...)
+	return meta
+}
+
+// Close flushes the samples. For a recording made with CreateSigMF it also closes the data
+// file and writes the metadata file.
+func (sw *SigMFWriter) Close() error {
+	if err := sw.w.Flush(); err != nil {
+		return err
+	}
+	sw.meta.Global.SHA512 = hex.EncodeToString(sw.hash.Sum(nil))
+	if sw.data == nil {
+		return nil
+	}
+	if err := sw.data.Close(); err != nil {
+		return err
+	}
+	return SaveSigMFMetadata(sw.metaPath, sw.meta)
+}
+
+// SigMFReplayConfig configures ReplaySigMF.
+type SigMFReplayConfig struct {
+	BlockSize    int                      // Samples per Doppler estimate; 0 means 4096
+	Estimator    FrequencyEstimatorConfig // SampleRate and CenterFrequency come from the recording
+	DetectionSNR float64                  // Per-sample SNR, in dB, from which a block counts as a detection; 0 means 0 dB
+	Acquisition  AcquisitionConfig        // Loop.SampleRate and Loop.CenterFrequency come from the recording; a zero Loop.LoopBandwidth skips lock tracking
+}
+
+// SigMFReplayResult summarises a replayed recording.
+type SigMFReplayResult struct {
+	Samples     int64
+	Doppler     []DopplerData     // One record per block, also added to the DopplerSorter
+	Signals     []string          // IDs of the CarrierSync signals added for detections
+	Annotations []SigMFAnnotation // Detections and lock events, ordered by first sample
+}
+
+// ReplaySigMF streams a recording block by block through a FrequencyEstimator into ds and
+// groups consecutive blocks above DetectionSNR into detected signals, each added to cs with
+// its measured SNR. An Acquirer follows the carrier to record lock and unlock events. Each
+// capture segment is processed with its own centre frequency and start time; segments without
+// a datetime are timed from the start of the replay. Either cs or ds may be nil. The returned
+// annotations can be written back with AppendSigMFAnnotations.
+func ReplaySigMF(reader *SigMFReader, config SigMFReplayConfig, cs *CarrierSync, ds *DopplerSorter) (SigMFReplayResult, error) {
+	meta := reader.Metadata()
+	fs := meta.Global.SampleRate
+	if fs <= 0 {
+		return SigMFReplayResult{}, errors.New("recording has no sample rate")
+	}
+	if config.BlockSize == 0 {
+		config.BlockSize = 4096
+	}
+	if config.BlockSize < 4 {
+		return SigMFReplayResult{}, fmt.Errorf("block size must be at least 4, got %d", config.BlockSize)
+	}
+	replayStart := time.Now()
+
+	var result SigMFReplayResult
+	var detection *sigmfDetection
+	finish := func() error {
+		if detection == nil {
+			return nil
+		}
+		annotation, id, err := detection.finish(cs, fs)
+		result.Annotations = append(result.Annotations, annotation)
+		if id != "" {
+			result.Signals = append(result.Signals, id)
+		}
+		detection = nil
+		return err
+	}
+
+	var (
+		segment   = int64(-1) // First sample of the current capture
+		estimator *FrequencyEstimator
+		acquirer  *Acquirer
+		start     time.Time
+	)
+	block := make([]complex128, config.BlockSize)
+	for {
+		position := reader.Position()
+		if capture := meta.Capture(position); estimator == nil || capture.SampleStart != segment {
+			if err := finish(); err != nil {
+				return result, err
+			}
+			segment = capture.SampleStart
+			var ok bool
+			if start, ok = capture.Time(); !ok {
+				start = replayStart.Add(seconds(float64(segment) / fs))
+			}
+			estimatorConfig := config.Estimator
+			estimatorConfig.SampleRate, estimatorConfig.CenterFrequency = fs, capture.Frequency
+			var err error
+			if estimator, err = NewFrequencyEstimator(estimatorConfig); err != nil {
+				return result, err
+			}
+			acquirer = nil
+			if config.Acquisition.Loop.LoopBandwidth > 0 {
+				acquisition := config.Acquisition
+				acquisition.Loop.SampleRate, acquisition.Loop.CenterFrequency = fs, capture.Frequency
+				if acquirer, err = NewAcquirer(acquisition); err != nil {
+					return result, err
+				}
+			}
+		}
+
+		// Blocks stop at capture boundaries so each is estimated with one centre frequency.
+		size := int64(len(block))
+		if next := meta.nextCapture(position); next >= 0 && next-position < size {
+			size = next - position
+		}
+		n, err := reader.Read(block[:size])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+		samples := block[:n]
+		timestamp := start.Add(seconds(float64(position-segment) / fs))
+		result.Samples += int64(n)
+
+		if acquirer != nil {
+			_, transitions := acquirer.Process(samples)
+			for _, t := range transitions {
+				if annotation, ok := lockAnnotation(t, segment, acquirer.config.Loop.CenterFrequency); ok {
+					result.Annotations = append(result.Annotations, annotation)
+				}
+			}
+		}
+		if n < 4 {
+			continue
+		}
+
+		estimate, err := estimator.Estimate(samples)
+		if err != nil {
+			return result, err
+		}
+		data := estimator.ToDopplerData(estimate, timestamp)
+		if ds != nil {
+			ds.AddData(data)
+		}
+		result.Doppler = append(result.Doppler, data)
+
+		if estimate.SNRdB < config.DetectionSNR {
+			if err := finish(); err != nil {
+				return result, err
+			}
+			continue
+		}
+		if detection == nil {
+			detection = &sigmfDetection{start: position, timestamp: timestamp, low: estimate.Frequency, high: estimate.Frequency}
+		}
+		detection.add(samples, estimate)
+	}
+	if err := finish(); err != nil {
+		return result, err
+	}
+	sort.SliceStable(result.Annotations, func(i, j int) bool {
+		return result.Annotations[i].SampleStart < result.Annotations[j].SampleStart
+	})
+	return result, nil
+}
+
+// sigmfDetection accumulates consecutive detected blocks.
+type sigmfDetection struct {
+	start         int64
+	timestamp     time.Time
+	samples       int
+	low, high     float64 // Range of block frequency estimates, in Hz
+	frequency     float64 // Sample-weighted sums
+	signal, noise float64
+}
+
+// add accumulates one block, splitting its power between carrier and noise by its SNR.
+func (d *sigmfDetection) add(samples []complex128, estimate FrequencyEstimate) {
+	var power float64
+	for _, x := range samples {
+		power += real(x)*real(x) + imag(x)*imag(x)
+	}
+	n := float64(len(samples))
+	d.signal += power * estimate.SNR / (1 + estimate.SNR)
+	d.noise += power / (1 + estimate.SNR)
+	d.frequency += estimate.Frequency * n
+	d.low = math.Min(d.low, estimate.Frequency)
+	d.high = math.Max(d.high, estimate.Frequency)
+	d.samples += len(samples)
+}
+
+// finish annotates the detection and adds it to cs with its SNR, returning the signal ID.
+func (d *sigmfDetection) finish(cs *CarrierSync, sampleRate float64) (SigMFAnnotation, string, error) {
+	n := float64(d.samples)
+	signal, noise := d.signal/n, d.noise/n
+	estimate := newSNREstimate(SNRSpectral, signal, noise, signal/noise, sampleRate, d.samples)
+	annotation := SigMFAnnotation{
+		SampleStart:   d.start,
+		SampleCount:   int64(d.samples),
+		FreqLowerEdge: d.low,
+		FreqUpperEdge: d.high,
+		Label:         "carrier",
+		Comment:       fmt.Sprintf("carrier at %.1f Hz, SNR %.1f dB, C/N0 %.1f dB-Hz", d.frequency/n, estimate.SNRdB, estimate.CN0),
+		Generator:     "ReplaySigMF",
+	}
+	if cs == nil {
+		return annotation, "", nil
+	}
+	id := randomString(10)
+	cs.AddSignal(CarrierSignal{
+		Frequency:  d.frequency / n,
+		Phase:      rand.Float64() * 2 * math.Pi,
+		Amplitude:  math.Sqrt(signal),
+		NoiseLevel: 10 * math.Log10(noise),
+		SignalID:   id,
+		Timestamp:  d.timestamp,
+	})
+	return annotation, id, cs.RecordSNR(id, estimate)
+}
+
+// lockAnnotation annotates entering or leaving the locked state; other transitions give ok false.
+func lockAnnotation(t AcquisitionTransition, segment int64, centerFrequency float64) (SigMFAnnotation, bool) {
+	var label string
+	switch {
+	case t.To == AcquisitionLocked:
+		label = "lock"
+	case t.From == AcquisitionLocked:
+		label = "unlock"
+	default:
+		return SigMFAnnotation{}, false
+	}
+	frequency := centerFrequency + t.Frequency
+	return SigMFAnnotation{
+		SampleStart:   segment + int64(t.Sample),
+		FreqLowerEdge: frequency,
+		FreqUpperEdge: frequency,
+		Label:         label,
+		Comment:       fmt.Sprintf("%v -> %v after %v at %.1f Hz", t.From, t.To, t.Dwell, frequency),
+		Generator:     "ReplaySigMF",
+	}, true
+}
@@ -0,0 +1,187 @@
+package communication
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// cmplxAbsMax is the larger absolute value of a complex number's components.
+func cmplxAbsMax(x complex128) float64 {
+	return math.Max(math.Abs(real(x)), math.Abs(imag(x)))
+}
+
+// TestSigMFRoundTrip round-trips samples through each datatype.
+func TestSigMFRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	signal, err := GenerateBaseband(BasebandConfig{Modulation: ModulationQPSK, SymbolRate: 10e3, SamplesPerSymbol: 8, Noiseless: true, Seed: 42}, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, datatype := range []string{SigMFComplexFloat32, SigMFComplexFloat32BE, SigMFComplexInt16, SigMFComplexInt16BE, SigMFComplexUint8} {
+		path := filepath.Join(dir, datatype)
+		scale := 0.5 // Keeps the RRC peaks within the integer full scale
+		writer, err := CreateSigMF(path, SigMFGlobal{Datatype: datatype, SampleRate: signal.SampleRate})
+		if err == nil {
+			writer.AddCapture(SigMFCapture{Frequency: 1.8e9})
+			scaled := make([]complex128, len(signal.Samples))
+			for i, x := range signal.Samples {
+				scaled[i] = x * complex(scale, 0)
+			}
+			if err = writer.Write(scaled); err == nil {
+				err = writer.Close()
+			}
+		}
+		if err != nil {
+			t.Errorf("%s write: %v", datatype, err)
+			continue
+		}
+		reader, err := OpenSigMF(path + ".sigmf-meta")
+		if err != nil {
+			t.Errorf("%s open: %v", datatype, err)
+			continue
+		}
+		var worst float64
+		var count int
+		buf := make([]complex128, 1000) // Not a multiple of the length, to exercise short reads
+		for {
+			n, err := reader.Read(buf)
+			for i := 0; i < n; i++ {
+				worst = math.Max(worst, cmplxAbsMax(buf[i]-signal.Samples[count+i]*complex(scale, 0)))
+			}
+			count += n
+			if err != nil {
+				break
+			}
+		}
+		reader.Close()
+		format, _ := parseSigMFDatatype(datatype)
+		limit := 1e-6
+		switch format.kind {
+		case 'i':
+			limit = 0.5 / math.MaxInt16
+		case 'u':
+			limit = 0.5 / 127.5
+		}
+		if count != len(signal.Samples) {
+			t.Errorf("%s: read %d samples, wrote %d", datatype, count, len(signal.Samples))
+		}
+		if worst > limit+1e-9 {
+			t.Errorf("%s: worst error %.2e exceeds %.2e", datatype, worst, limit)
+		}
+		if writer.Clipped() != 0 {
+			t.Errorf("%s: %d samples clipped", datatype, writer.Clipped())
+		}
+	}
+}
+
+// TestSigMFReplay writes a two-capture recording of a carrier that appears partway through,
+// replays it into a CarrierSync and DopplerSorter and writes the detections and lock events
+// back as annotations.
+func TestSigMFReplay(t *testing.T) {
+	// Capture 0 has 0.4 s of noise before the carrier appears 2 kHz above 1.8 GHz; capture 1
+	// retunes 1 kHz higher, so the carrier sits at +1 kHz.
+	const (
+		sampleRate = 100e3
+		rf         = 1.8e9
+		carrier    = rf + 2e3
+		snrDB      = 10.0
+	)
+	rng := rand.New(rand.NewSource(42))
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "replay")
+	writer, err := CreateSigMF(path, SigMFGlobal{
+		Datatype:   SigMFComplexFloat32,
+		SampleRate: sampleRate,
+		Extra:      map[string]json.RawMessage{"lab:antenna": json.RawMessage(`"dish-3"`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	noise := make([]complex128, 40960)
+	sigma := math.Sqrt(1 / (2 * math.Pow(10, snrDB/10)))
+	for i := range noise {
+		noise[i] = complex(rng.NormFloat64()*sigma, rng.NormFloat64()*sigma)
+	}
+	writer.AddCapture(SigMFCapture{Frequency: rf, Datetime: start.Format(time.RFC3339Nano)})
+	writer.Write(noise)
+	writer.Write(syntheticCarrier(rng, LoopPLL, 81920, sampleRate, sampleRate, carrier-rf, 0, snrDB))
+	writer.AddCapture(SigMFCapture{Frequency: rf + 1e3, Datetime: start.Add(2 * time.Second).Format(time.RFC3339Nano)})
+	writer.Write(syntheticCarrier(rng, LoopPLL, 81920, sampleRate, sampleRate, carrier-rf-1e3, 0, snrDB))
+	if err := writer.Close(); err != nil {
+		t.Fatalf("replay recording: %v", err)
+	}
+
+	reader, err := OpenSigMF(path)
+	if err != nil {
+		t.Fatalf("replay open: %v", err)
+	}
+	defer reader.Close()
+	cs := NewCarrierSync(10)
+	ds := NewDopplerSorter(10)
+	result, err := ReplaySigMF(reader, SigMFReplayConfig{
+		Estimator:    FrequencyEstimatorConfig{Window: WindowHann, ZeroPadFactor: 4},
+		DetectionSNR: 3,
+		Acquisition:  AcquisitionConfig{Loop: LoopConfig{Type: LoopPLL, LoopBandwidth: 50}},
+	}, cs, ds)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	var worst float64
+	var detected int
+	for _, d := range result.Doppler {
+		if d.SignalStrength > 0.5 {
+			worst = math.Max(worst, math.Abs(d.Frequency-carrier))
+			detected++
+		}
+	}
+	if result.Samples != 204800 {
+		t.Errorf("replayed %d samples, want 204800", result.Samples)
+	}
+	if len(ds.data) != len(result.Doppler) {
+		t.Errorf("sorter holds %d records, replay returned %d", len(ds.data), len(result.Doppler))
+	}
+	if detected < 38 || worst > 1 {
+		t.Errorf("%d of %d Doppler records with the carrier, worst error %.2f Hz", detected, len(result.Doppler), worst)
+	}
+	if len(result.Doppler) == 0 || !result.Doppler[len(result.Doppler)-1].Timestamp.After(start.Add(2*time.Second)) {
+		t.Errorf("capture 1 Doppler records are not timed from its datetime")
+	}
+
+	counts := make(map[string]int)
+	for _, a := range result.Annotations {
+		counts[a.Label]++
+	}
+	if counts["carrier"] != 2 || counts["lock"] < 2 {
+		t.Errorf("%d carrier detections and %d lock events, want 2 and at least 2", counts["carrier"], counts["lock"])
+	}
+	if len(result.Signals) != 2 {
+		t.Errorf("replay reported %d signals, want 2", len(result.Signals))
+	}
+	for _, s := range cs.signals {
+		if math.Abs(s.Frequency-carrier) > 1 || math.Abs(headerSNR(s)-snrDB) > 0.5 {
+			t.Errorf("CarrierSync signal %s at %.1f Hz with SNR %.2f dB, want %.1f Hz and %.1f dB",
+				s.SignalID, s.Frequency, headerSNR(s), carrier, snrDB)
+		}
+	}
+
+	if err := AppendSigMFAnnotations(path, result.Annotations...); err != nil {
+		t.Fatalf("writing annotations: %v", err)
+	}
+	meta, err := LoadSigMFMetadata(path)
+	if err != nil {
+		t.Fatalf("reloading metadata: %v", err)
+	}
+	if len(meta.Annotations) != len(result.Annotations) {
+		t.Errorf("%d annotations written back, want %d", len(meta.Annotations), len(result.Annotations))
+	}
+	if string(meta.Global.Extra["lab:antenna"]) != `"dish-3"` {
+		t.Errorf("extension key lab:antenna lost: %q", meta.Global.Extra["lab:antenna"])
+	}
+	if meta.Global.SHA512 == "" {
+		t.Errorf("recording SHA512 missing from the metadata")
+	}
+}
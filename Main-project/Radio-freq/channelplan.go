@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// LinkDirection is the direction of transmission a band is allocated for.
+type LinkDirection int
+
+const (
+	AnyDirection LinkDirection = iota // Unspecified; matches a band of any direction
+	SpaceToEarth
+	EarthToSpace
+	SpaceToSpace
+	Terrestrial
+)
+
+// String returns the direction as written in allocation tables.
+func (d LinkDirection) String() string {
+	switch d {
+	case AnyDirection:
+		return "any direction"
+	case SpaceToEarth:
+		return "space-to-Earth"
+	case EarthToSpace:
+		return "Earth-to-space"
+	case SpaceToSpace:
+		return "space-to-space"
+	case Terrestrial:
+		return "terrestrial"
+	}
+	return fmt.Sprintf("LinkDirection(%d)", int(d))
+}
+
+// Band is a frequency allocation that channels must fit inside.
+type Band struct {
+	Name      string
+	Low       float64 // Lower edge, in Hz
+	High      float64 // Upper edge, in Hz
+	Direction LinkDirection
+	Service   string
+}
+
+// BandTable is a set of allocations a channel plan is validated against.
+type BandTable []Band
+
+// SpaceBandTable returns the space research and fixed-satellite allocations used by the
+// ground station links.
+func SpaceBandTable() BandTable {
+	return BandTable{
+		{Name: "S-band", Low: 2025e6, High: 2110e6, Direction: EarthToSpace, Service: "space research"},
+		{Name: "S-band", Low: 2200e6, High: 2290e6, Direction: SpaceToEarth, Service: "space research"},
+		{Name: "X-band", Low: 7145e6, High: 7235e6, Direction: EarthToSpace, Service: "space research"},
+		{Name: "X-band", Low: 8400e6, High: 8500e6, Direction: SpaceToEarth, Service: "space research"},
+		{Name: "Ku-band", Low: 10.7e9, High: 12.75e9, Direction: SpaceToEarth, Service: "fixed-satellite"},
+		{Name: "Ku-band", Low: 13.75e9, High: 14.5e9, Direction: EarthToSpace, Service: "fixed-satellite"},
+	}
+}
+
+// ISMBandTable returns the licence-exempt ISM allocations.
+func ISMBandTable() BandTable {
+	return BandTable{
+		{Name: "ISM 2.4 GHz", Low: 2400e6, High: 2483.5e6, Direction: Terrestrial, Service: "ISM"},
+		{Name: "ISM 5.8 GHz", Low: 5725e6, High: 5875e6, Direction: Terrestrial, Service: "ISM"},
+	}
+}
+
+// String returns the band name, direction, service and frequency range.
+func (b Band) String() string {
+	return fmt.Sprintf("%s %s %s (%s)", b.Name, b.Direction, b.Service, formatRange(b.Low, b.High))
+}
+
+// RFChannel is a named channel in a channel plan.
+type RFChannel struct {
+	ID        string
+	Center    float64 // Center frequency, in Hz
+	Bandwidth float64 // Occupied bandwidth, in Hz
+	Guard     float64 // Guard band kept clear on each side, in Hz
+	Direction LinkDirection
+}
+
+// Low returns the lower edge of the channel including its guard band, in Hz.
+func (c RFChannel) Low() float64 {
+	return c.Center - c.Bandwidth/2 - c.Guard
+}
+
+// High returns the upper edge of the channel including its guard band, in Hz.
+func (c RFChannel) High() float64 {
+	return c.Center + c.Bandwidth/2 + c.Guard
+}
+
+// ChannelPlan is a validated set of non-overlapping channels, addressed by ID.
+type ChannelPlan struct {
+	channels []RFChannel // Sorted by Center
+	index    map[string]int
+}
+
+// NewChannelPlan validates the channels and builds a plan. Channels, guard bands included,
+// must not overlap and, when table is not nil, must each fit inside a band allocated for
+// their direction.
+func NewChannelPlan(table BandTable, channels ...RFChannel) (*ChannelPlan, error) {
+	if len(channels) == 0 {
+		return nil, errors.New("channel plan needs at least one channel")
+	}
+	sorted := append([]RFChannel(nil), channels...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Center < sorted[j].Center })
+
+	index := make(map[string]int, len(sorted))
+	for i, ch := range sorted {
+		_, duplicate := index[ch.ID]
+		switch {
+		case ch.ID == "":
+			return nil, fmt.Errorf("channel at %.3f MHz has no ID", ch.Center/1e6)
+		case duplicate:
+			return nil, fmt.Errorf("duplicate channel ID %q", ch.ID)
+		case ch.Center <= 0:
+			return nil, fmt.Errorf("channel %q: center frequency must be positive, got %.0f Hz", ch.ID, ch.Center)
+		case ch.Bandwidth <= 0:
+			return nil, fmt.Errorf("channel %q: bandwidth must be positive, got %.0f Hz", ch.ID, ch.Bandwidth)
+		case ch.Guard < 0:
+			return nil, fmt.Errorf("channel %q: guard band must not be negative, got %.0f Hz", ch.ID, ch.Guard)
+		case ch.Low() <= 0:
+			return nil, fmt.Errorf("channel %q: %s extends below 0 Hz", ch.ID, formatRange(ch.Low(), ch.High()))
+		}
+		if i > 0 {
+			prev := sorted[i-1]
+			if overlap := prev.High() - ch.Low(); overlap > 0 {
+				return nil, fmt.Errorf("channels %q (%s) and %q (%s) overlap by %.3f MHz including guard bands",
+					prev.ID, formatRange(prev.Low(), prev.High()), ch.ID, formatRange(ch.Low(), ch.High()), overlap/1e6)
+			}
+		}
+		if table != nil {
+			if err := table.Check(ch); err != nil {
+				return nil, err
+			}
+		}
+		index[ch.ID] = i
+	}
+	return &ChannelPlan{channels: sorted, index: index}, nil
+}
+
+// PlanFromFrequencies builds a plan with one channel per frequency point, each with the
+// given bandwidth and guard band, named by its center frequency in MHz. No band table applies.
+func PlanFromFrequencies(frequencyPoints []float64, bandwidth, guard float64) (*ChannelPlan, error) {
+	channels := make([]RFChannel, len(frequencyPoints))
+	for i, f := range frequencyPoints {
+		channels[i] = RFChannel{ID: fmt.Sprintf("CH-%gMHz", f/1e6), Center: f, Bandwidth: bandwidth, Guard: guard}
+	}
+	return NewChannelPlan(nil, channels...)
+}
+
+// Check returns a descriptive error unless the channel, guard bands included, lies inside
+// one band allocated for its direction.
+func (t BandTable) Check(ch RFChannel) error {
+	var nearest *Band
+	var distance float64
+	for i := range t {
+		band := &t[i]
+		if ch.Direction != AnyDirection && band.Direction != ch.Direction {
+			continue
+		}
+		if ch.Low() >= band.Low && ch.High() <= band.High {
+			return nil
+		}
+		d := math.Max(band.Low-ch.Low(), 0) + math.Max(ch.High()-band.High, 0)
+		if nearest == nil || d < distance {
+			nearest, distance = band, d
+		}
+	}
+
+	span := formatRange(ch.Low(), ch.High())
+	if nearest == nil {
+		return fmt.Errorf("channel %q (%s): the band table has no %s allocation", ch.ID, span, ch.Direction)
+	}
+	var problems []string
+	if below := nearest.Low - ch.Low(); below > 0 {
+		problems = append(problems, fmt.Sprintf("%.3f MHz below", below/1e6))
+	}
+	if above := ch.High() - nearest.High; above > 0 {
+		problems = append(problems, fmt.Sprintf("%.3f MHz above", above/1e6))
+	}
+	if ch.Center < nearest.Low || ch.Center > nearest.High {
+		return fmt.Errorf("channel %q (%s) is outside every %s allocation; nearest is %v",
+			ch.ID, span, ch.Direction, *nearest)
+	}
+	return fmt.Errorf("channel %q (%s) extends %s %v including guard bands",
+		ch.ID, span, strings.Join(problems, " and "), *nearest)
+}
+
+// Channels returns a copy of the plan's channels in frequency order.
+func (p *ChannelPlan) Channels() []RFChannel {
+	return append([]RFChannel(nil), p.channels...)
+}
+
+// Channel returns the channel with the given ID.
+func (p *ChannelPlan) Channel(id string) (RFChannel, error) {
+	i, ok := p.index[id]
+	if !ok {
+		return RFChannel{}, fmt.Errorf("unknown channel ID %q", id)
+	}
+	return p.channels[i], nil
+}
+
+// IDs returns the channel IDs in frequency order.
+func (p *ChannelPlan) IDs() []string {
+	ids := make([]string, len(p.channels))
+	for i, ch := range p.channels {
+		ids[i] = ch.ID
+	}
+	return ids
+}
+
+// Centers returns the channel center frequencies in frequency order.
+func (p *ChannelPlan) Centers() []float64 {
+	centers := make([]float64, len(p.channels))
+	for i, ch := range p.channels {
+		centers[i] = ch.Center
+	}
+	return centers
+}
+
+// Nearest returns the channel whose center is closest to f.
+func (p *ChannelPlan) Nearest(f float64) RFChannel {
+	i := sort.Search(len(p.channels), func(i int) bool { return p.channels[i].Center >= f })
+	switch {
+	case i == 0:
+		return p.channels[0]
+	case i == len(p.channels):
+		return p.channels[i-1]
+	}
+	if f-p.channels[i-1].Center <= p.channels[i].Center-f {
+		return p.channels[i-1]
+	}
+	return p.channels[i]
+}
+
+// containing returns the channel whose bandwidth or guard band contains f, if any.
+func (p *ChannelPlan) containing(f float64) (RFChannel, bool) {
+	i := sort.Search(len(p.channels), func(i int) bool { return p.channels[i].High() >= f })
+	if i < len(p.channels) && p.channels[i].Low() <= f {
+		return p.channels[i], true
+	}
+	return RFChannel{}, false
+}
+
+// formatRange formats a frequency range in MHz.
+func formatRange(low, high float64) string {
+	return fmt.Sprintf("%.3f–%.3f MHz", low/1e6, high/1e6)
+}
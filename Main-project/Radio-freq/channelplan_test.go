@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestChannelPlan checks plan validation against the band tables.
+func TestChannelPlan(t *testing.T) {
+	space := SpaceBandTable()
+	cases := []struct {
+		name     string
+		table    BandTable
+		channels []RFChannel
+		wantErr  string
+	}{
+		{"S-band downlink", space, []RFChannel{
+			{ID: "S-DL1", Center: 2215e6, Bandwidth: 5e6, Guard: 1e6, Direction: SpaceToEarth},
+			{ID: "S-DL2", Center: 2245e6, Bandwidth: 5e6, Guard: 1e6, Direction: SpaceToEarth},
+		}, ""},
+		{"overlapping guard bands", space, []RFChannel{
+			{ID: "S-DL1", Center: 2240e6, Bandwidth: 5e6, Guard: 1e6, Direction: SpaceToEarth},
+			{ID: "S-DL2", Center: 2246e6, Bandwidth: 5e6, Guard: 1e6, Direction: SpaceToEarth},
+		}, "overlap"},
+		{"past the band edge", space, []RFChannel{
+			{ID: "S-DL9", Center: 2287e6, Bandwidth: 5e6, Guard: 1e6, Direction: SpaceToEarth},
+		}, "extends"},
+		{"uplink in a downlink band", space, []RFChannel{
+			{ID: "S-UL1", Center: 2250e6, Bandwidth: 5e6, Guard: 1e6, Direction: EarthToSpace},
+		}, "outside every Earth-to-space allocation"},
+		{"no terrestrial allocation", space, []RFChannel{
+			{ID: "WIFI", Center: 2440e6, Bandwidth: 20e6, Direction: Terrestrial},
+		}, "no terrestrial allocation"},
+		{"duplicate ID", nil, []RFChannel{
+			{ID: "A", Center: 2.4e9, Bandwidth: 1e6},
+			{ID: "A", Center: 5.8e9, Bandwidth: 1e6},
+		}, "duplicate"},
+		{"zero bandwidth", nil, []RFChannel{{ID: "B", Center: 2.4e9}}, "bandwidth must be positive"},
+	}
+	for _, c := range cases {
+		_, err := NewChannelPlan(c.table, c.channels...)
+		switch {
+		case c.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		case c.wantErr != "" && err == nil:
+			t.Errorf("%s: no error, want one containing %q", c.name, c.wantErr)
+		case err != nil && !strings.Contains(err.Error(), c.wantErr):
+			t.Errorf("%s: error %v, want one containing %q", c.name, err, c.wantErr)
+		}
+	}
+}
+
+// TestChannelPlanHandler checks that RFHandler addresses channels by ID.
+func TestChannelPlanHandler(t *testing.T) {
+	plan, err := NewChannelPlan(SpaceBandTable(),
+		RFChannel{ID: "S-DL1", Center: 2215e6, Bandwidth: 5e6, Guard: 1e6, Direction: SpaceToEarth},
+		RFChannel{ID: "S-DL2", Center: 2245e6, Bandwidth: 5e6, Guard: 1e6, Direction: SpaceToEarth},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rfh := NewRFHandler(plan)
+	signal, err := rfh.GenerateChannelSignal("S-DL2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signal.Channel != "S-DL2" || signal.Frequency != 2245e6 {
+		t.Errorf("S-DL2: got %s at %.3f MHz", signal.Channel, signal.Frequency/1e6)
+	}
+	if _, err := rfh.GenerateChannelSignal("S-DL3"); err == nil {
+		t.Errorf("S-DL3: no error for an unknown channel ID")
+	}
+}
@@ -1,15 +1,15 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"math"
 	"math/rand"
-	"time"
 	"sync"
+	"time"
 )
 
 const (
-	SPEED_OF_LIGHT = 3e8 // m/s
+	SPEED_OF_LIGHT = 299792458.0 // m/s, exact
 )
 
 // RFSignal represents the structure of the radio frequency signal.
@@ -18,34 +18,132 @@ type RFSignal struct {
 	SignalStrength float64
 	Timestamp      time.Time
 	ID             string
+	Channel        string // Channel plan ID the signal was sent on
 	Transmission   bool
 }
 
 // RFHandler handles the sending and receiving of RF signals.
 type RFHandler struct {
-	mu             sync.Mutex
-	signals        []RFSignal
-	frequencyPoints []float64
+	mu              sync.Mutex
+	signals         []RFSignal
+	plan            *ChannelPlan
+	frequencyPoints []float64              // Channel centers, in plan order
+	hopping         *HopScheduler          // Deterministic hop schedule; nil picks frequencies at random
+	selection       *ChannelQualityTracker // Adaptive channel selection; nil picks frequencies at random
+	link            *linkModel             // Link budget over a pass; nil picks signal strengths at random
 }
 
-// NewRFHandler initializes a new RFHandler with specified frequency points.
-func NewRFHandler(frequencyPoints []float64) *RFHandler {
+// NewRFHandler initializes a new RFHandler over the channels of a validated channel plan.
+func NewRFHandler(plan *ChannelPlan) *RFHandler {
 	return &RFHandler{
-		signals:        []RFSignal{},
-		frequencyPoints: frequencyPoints,
+		signals:         []RFSignal{},
+		plan:            plan,
+		frequencyPoints: plan.Centers(),
 	}
 }
 
-// GenerateRandomSignal generates a new RFSignal with a random signal strength.
+// Plan returns the handler's channel plan.
+func (rfh *RFHandler) Plan() *ChannelPlan {
+	return rfh.plan
+}
+
+// SetHopping makes the handler follow the hop schedule derived from config over its
+// frequency points instead of picking frequencies at random.
+func (rfh *RFHandler) SetHopping(config HopConfig) error {
+	scheduler, err := NewHopScheduler(config, rfh.frequencyPoints)
+	if err != nil {
+		return err
+	}
+	rfh.mu.Lock()
+	defer rfh.mu.Unlock()
+	if rfh.selection != nil {
+		return errors.New("frequency hopping and adaptive channel selection cannot both be enabled")
+	}
+	rfh.hopping = scheduler
+	return nil
+}
+
+// Hopping returns the handler's hop schedule, or nil if it is not hopping.
+func (rfh *RFHandler) Hopping() *HopScheduler {
+	rfh.mu.Lock()
+	defer rfh.mu.Unlock()
+	return rfh.hopping
+}
+
+// SetAdaptiveSelection makes the handler transmit on the channel a quality tracker picks from
+// the outcomes reported with ReportChannelQuality, instead of picking frequencies at random.
+func (rfh *RFHandler) SetAdaptiveSelection(config ChannelQualityConfig) error {
+	tracker, err := NewChannelQualityTracker(rfh.plan, config)
+	if err != nil {
+		return err
+	}
+	rfh.mu.Lock()
+	defer rfh.mu.Unlock()
+	if rfh.hopping != nil {
+		return errors.New("frequency hopping and adaptive channel selection cannot both be enabled")
+	}
+	rfh.selection = tracker
+	return nil
+}
+
+// ChannelSelection returns the handler's channel quality tracker, or nil if adaptive
+// selection is not enabled.
+func (rfh *RFHandler) ChannelSelection() *ChannelQualityTracker {
+	rfh.mu.Lock()
+	defer rfh.mu.Unlock()
+	return rfh.selection
+}
+
+// ReportChannelQuality records the measured outcome of a transmission for adaptive selection.
+func (rfh *RFHandler) ReportChannelQuality(observation ChannelObservation) error {
+	selection := rfh.ChannelSelection()
+	if selection == nil {
+		return errors.New("adaptive channel selection is not enabled")
+	}
+	return selection.Observe(observation)
+}
+
+// GenerateRandomSignal generates a new RFSignal, with its strength from the link budget when
+// one is set and at random otherwise, on the scheduled hop channel when hopping, on the best
+// measured channel with adaptive selection, and on a random channel otherwise.
 func (rfh *RFHandler) GenerateRandomSignal() RFSignal {
 	rand.Seed(time.Now().UnixNano())
-	frequency := rfh.frequencyPoints[rand.Intn(len(rfh.frequencyPoints))]
-	signalStrength := rand.Float64()
+	now := time.Now()
+	var channel int
+	if hopping := rfh.Hopping(); hopping != nil {
+		channel = hopping.Channel(hopping.HopAt(now))
+	} else if selection := rfh.ChannelSelection(); selection != nil {
+		channel = rfh.plan.index[selection.Select(now).Channel]
+	} else {
+		channel = rand.Intn(len(rfh.frequencyPoints))
+	}
+	return rfh.newRFSignal(rfh.plan.channels[channel], now)
+}
+
+// GenerateChannelSignal generates a new RFSignal on the channel with the given ID.
+func (rfh *RFHandler) GenerateChannelSignal(id string) (RFSignal, error) {
+	ch, err := rfh.plan.Channel(id)
+	if err != nil {
+		return RFSignal{}, err
+	}
+	return rfh.newRFSignal(ch, time.Now()), nil
+}
+
+// newRFSignal creates a transmission at the center of a channel.
+func (rfh *RFHandler) newRFSignal(ch RFChannel, timestamp time.Time) RFSignal {
+	rfh.mu.Lock()
+	link := rfh.link
+	rfh.mu.Unlock()
+	strength := rand.Float64()
+	if link != nil {
+		strength = link.strength(ch, timestamp)
+	}
 	return RFSignal{
-		Frequency:      frequency,
-		SignalStrength: signalStrength,
-		Timestamp:      time.Now(),
+		Frequency:      ch.Center,
+		SignalStrength: strength,
+		Timestamp:      timestamp,
 		ID:             randomString(10),
+		Channel:        ch.ID,
 		Transmission:   true,
 	}
 }
@@ -63,8 +161,8 @@ func (rfh *RFHandler) LogSignals() {
 	defer rfh.mu.Unlock()
 
 	for _, signal := range rfh.signals {
-		fmt.Printf("ID: %s | Frequency: %.2f Hz | Signal Strength: %.2f | Timestamp: %v\n",
-			signal.ID, signal.Frequency, signal.SignalStrength, signal.Timestamp)
+		fmt.Printf("ID: %s | Channel: %s | Frequency: %.2f Hz | Signal Strength: %.2f | Timestamp: %v\n",
+			signal.ID, signal.Channel, signal.Frequency, signal.SignalStrength, signal.Timestamp)
 	}
 }
 
@@ -90,6 +188,19 @@ func (rfh *RFHandler) SimulateTransmission(duration time.Duration) {
 			default:
 				signal := rfh.GenerateRandomSignal()
 				rfh.AddSignal(signal)
+				if rfh.ChannelSelection() != nil {
+					_, noiseLevel, _, _, errorRate := generateRFMetrics()
+					err := rfh.ReportChannelQuality(ChannelObservation{
+						Channel:      signal.Channel,
+						Strength:     signal.SignalStrength,
+						ErrorRate:    errorRate / 100, // generateRFMetrics reports a percentage; observations take a fraction
+						Interference: noiseLevel > 0.8*maxNoiseLevel,
+						Timestamp:    signal.Timestamp,
+					})
+					if err != nil {
+						logEvent(WARNING, fmt.Sprintf("Channel quality report for %s dropped: %v", signal.ID, err))
+					}
+				}
 				time.Sleep(100 * time.Millisecond) // Simulate transmission interval
 			}
 		}
@@ -100,26 +211,23 @@ func (rfh *RFHandler) SimulateTransmission(duration time.Duration) {
 	fmt.Println("Signal transmission complete.")
 }
 
-// SynchronizeFrequencies ensures the transmission and reception frequencies are synchronized.
-func (rfh *RFHandler) SynchronizeFrequencies() {
+// SimulateSignalShifts applies frequency shifts to simulate RF signal movement. When hopping,
+// each signal advances one dwell and moves to the frequency scheduled for its new timestamp.
+func (rfh *RFHandler) SimulateSignalShifts() {
 	rfh.mu.Lock()
 	defer rfh.mu.Unlock()
 
 	for i := 0; i < len(rfh.signals); i++ {
-		for j := i + 1; j < len(rfh.signals); j++ {
-			if math.Abs(rfh.signals[i].Frequency-rfh.signals[j].Frequency) > 1e6 { // Synchronize if difference > 1MHz
-				fmt.Printf("Synchronizing signal: %s with %s\n", rfh.signals[i].ID, rfh.signals[j].ID)
-				// Sync the frequencies here, this is just a placeholder for your logic
-				rfh.signals[j].Frequency = rfh.signals[i].Frequency
-			}
+		signal := &rfh.signals[i]
+		var ch RFChannel
+		if rfh.hopping == nil {
+			ch = rfh.plan.channels[rand.Intn(len(rfh.plan.channels))]
+		} else {
+			signal.Timestamp = signal.Timestamp.Add(rfh.hopping.Dwell())
+			ch = rfh.plan.channels[rfh.hopping.Channel(rfh.hopping.HopAt(signal.Timestamp))]
 		}
-	}
-}
-
-// SimulateSignalShifts applies frequency shifts to simulate RF signal movement.
-func (rfh *RFHandler) SimulateSignalShifts() {
-	for i := 0; i < len(rfh.signals); i++ {
-		rfh.signals[i].Frequency = rfh.frequencyPoints[rand.Intn(len(rfh.frequencyPoints))]
+		signal.Frequency = ch.Center
+		signal.Channel = ch.ID
 	}
 }
 
@@ -184,13 +292,34 @@ func (rfh *RFHandler) MonitorSignalStrength() {
 }
 
 // RunTestSimulation runs the entire RF signal transmission and analysis simulation.
-func RunTestSimulation(frequencyPoints []float64, duration time.Duration) {
-	rfh := NewRFHandler(frequencyPoints)
+func RunTestSimulation(plan *ChannelPlan, duration time.Duration) {
+	rfh := NewRFHandler(plan)
+	if err := rfh.SetHopping(HopConfig{Generator: HopAESCTR, Seed: 1, Key: []byte("rf-link"), Dwell: 100 * time.Millisecond}); err != nil {
+		logEvent(ERROR, fmt.Sprintf("Frequency hopping disabled: %v", err))
+	}
+	// Signal strengths follow a 500 km LEO pass, starting at culmination
+	geometry := PassGeometry{AltitudeKm: 500, MaxElevationDeg: 60}
+	if budget, err := NewLinkBudget(DefaultLinkConfig()); err != nil {
+		logEvent(ERROR, fmt.Sprintf("Link budget disabled: %v", err))
+	} else if err := rfh.SetLinkBudget(budget, geometry, time.Now().Add(-geometry.Duration()/2)); err != nil {
+		logEvent(ERROR, fmt.Sprintf("Link budget disabled: %v", err))
+	} else if passes, err := budget.EvaluatePlan(plan, geometry); err == nil {
+		for _, pass := range passes {
+			logEvent(INFO, "Link budget "+pass.String())
+		}
+	}
 
 	rfh.SimulateTransmission(duration)
-	rfh.SynchronizeFrequencies()
+	fmt.Print(rfh.SynchronizeFrequencies(ReferenceWeightedMedian))
 	rfh.AnalyzeFrequencies()
 	rfh.MonitorSignalStrength()
+	if occupancy, err := rfh.ScanSpectrum(SpectrumConfig{}, 1<<15); err != nil {
+		logEvent(ERROR, fmt.Sprintf("Spectrum scan failed: %v", err))
+	} else {
+		for _, o := range occupancy {
+			logEvent(INFO, "Spectrum "+o.String())
+		}
+	}
 
 	// Simulate frequency shifts
 	rfh.SimulateSignalShifts()
@@ -202,14 +331,18 @@ func RunTestSimulation(frequencyPoints []float64, duration time.Duration) {
 }
 
 func main() {
-	// Define frequency points for simulation
-	frequencyPoints := []float64{
-		2.4e9, // 2.4 GHz
-		5.8e9, // 5.8 GHz
-		8.5e9, // 8.5 GHz
-		12.0e9, // 12 GHz
+	// Define the channel plan for simulation; these are lab channels, so no band table applies
+	plan, err := NewChannelPlan(nil,
+		RFChannel{ID: "S-2400", Center: 2.4e9, Bandwidth: 20e6, Guard: 5e6},
+		RFChannel{ID: "C-5800", Center: 5.8e9, Bandwidth: 20e6, Guard: 5e6},
+		RFChannel{ID: "X-8500", Center: 8.5e9, Bandwidth: 20e6, Guard: 5e6},
+		RFChannel{ID: "Ku-12000", Center: 12.0e9, Bandwidth: 20e6, Guard: 5e6},
+	)
+	if err != nil {
+		logEvent(ERROR, fmt.Sprintf("Invalid channel plan: %v", err))
+		return
 	}
 
 	// Run simulation for 30 seconds
-	RunTestSimulation(frequencyPoints, 30*time.Second)
+	RunTestSimulation(plan, 30*time.Second)
 }
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ReferenceMethod chooses how the reference frequency of a channel group is estimated.
+type ReferenceMethod int
+
+const (
+	ReferenceMedian         ReferenceMethod = iota // Median frequency of the group
+	ReferenceWeightedMedian                        // Median weighted by signal strength
+)
+
+// String returns the reference method name.
+func (m ReferenceMethod) String() string {
+	switch m {
+	case ReferenceMedian:
+		return "median"
+	case ReferenceWeightedMedian:
+		return "strength-weighted median"
+	}
+	return fmt.Sprintf("ReferenceMethod(%d)", int(m))
+}
+
+// outlierMADs is how many scaled median absolute deviations from the reference mark an outlier.
+const outlierMADs = 3.5
+
+// FrequencyOffset is one signal's offset from its channel group's reference.
+type FrequencyOffset struct {
+	SignalID  string
+	Frequency float64 // in Hz
+	Offset    float64 // Frequency minus the group reference, in Hz
+	Outlier   bool    // Offset is beyond outlierMADs scaled MADs
+}
+
+// ChannelSync is the consensus of the signals assigned to one channel.
+type ChannelSync struct {
+	Channel   string
+	Nominal   float64 // Channel center, in Hz
+	Reference float64 // Robust consensus frequency of the group, in Hz
+	Spread    float64 // Scaled median absolute deviation of the offsets, in Hz
+	Offsets   []FrequencyOffset
+}
+
+// SyncReport is the result of SynchronizeFrequencies.
+type SyncReport struct {
+	Method   ReferenceMethod
+	Channels []ChannelSync // In channel plan order; channels without signals are omitted
+	// Signals without a known channel that lie outside every channel and its guard band, in
+	// frequency order. Their Offset is from the nearest channel's center.
+	Unassigned []FrequencyOffset
+}
+
+// String returns the report as one summary line per channel.
+func (r SyncReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Frequency synchronization (%v reference):\n", r.Method)
+	for _, ch := range r.Channels {
+		outliers := 0
+		for _, offset := range ch.Offsets {
+			if offset.Outlier {
+				outliers++
+			}
+		}
+		fmt.Fprintf(&b, "  %s: %d signals, reference %.2f Hz (%+.2f Hz from nominal), spread %.2f Hz, %d outliers\n",
+			ch.Channel, len(ch.Offsets), ch.Reference, ch.Reference-ch.Nominal, ch.Spread, outliers)
+	}
+	if len(r.Unassigned) > 0 {
+		fmt.Fprintf(&b, "  unassigned: %d signals outside every channel\n", len(r.Unassigned))
+	}
+	return b.String()
+}
+
+// Offset returns the offset reported for a signal.
+func (r SyncReport) Offset(signalID string) (FrequencyOffset, bool) {
+	for _, ch := range r.Channels {
+		for _, offset := range ch.Offsets {
+			if offset.SignalID == signalID {
+				return offset, true
+			}
+		}
+	}
+	return FrequencyOffset{}, false
+}
+
+// SynchronizeFrequencies groups the signals by their assigned channel, or by the channel
+// whose bandwidth or guard band contains them for signals without one, estimates a robust
+// reference frequency for each group and reports every signal's offset from it. Signals
+// outside every channel are reported as unassigned. Signals are not modified, and the result
+// does not depend on their order. It runs in O(n log n).
+func (rfh *RFHandler) SynchronizeFrequencies(method ReferenceMethod) SyncReport {
+	rfh.mu.Lock()
+	defer rfh.mu.Unlock()
+
+	type member struct {
+		channel int
+		signal  RFSignal
+	}
+	report := SyncReport{Method: method}
+	members := make([]member, 0, len(rfh.signals))
+	for _, signal := range rfh.signals {
+		channel, ok := rfh.plan.index[signal.Channel]
+		if !ok {
+			ch, inside := rfh.plan.containing(signal.Frequency)
+			if !inside {
+				report.Unassigned = append(report.Unassigned, FrequencyOffset{
+					SignalID:  signal.ID,
+					Frequency: signal.Frequency,
+					Offset:    signal.Frequency - rfh.plan.Nearest(signal.Frequency).Center,
+				})
+				continue
+			}
+			channel = rfh.plan.index[ch.ID]
+		}
+		members = append(members, member{channel, signal})
+	}
+	sort.Slice(report.Unassigned, func(i, j int) bool {
+		a, b := report.Unassigned[i], report.Unassigned[j]
+		if a.Frequency != b.Frequency {
+			return a.Frequency < b.Frequency
+		}
+		return a.SignalID < b.SignalID
+	})
+	sort.Slice(members, func(i, j int) bool {
+		a, b := members[i], members[j]
+		if a.channel != b.channel {
+			return a.channel < b.channel
+		}
+		if a.signal.Frequency != b.signal.Frequency {
+			return a.signal.Frequency < b.signal.Frequency
+		}
+		return a.signal.ID < b.signal.ID
+	})
+
+	for start := 0; start < len(members); {
+		end := start
+		for end < len(members) && members[end].channel == members[start].channel {
+			end++
+		}
+		group := members[start:end]
+		frequencies := make([]float64, len(group))
+		weights := make([]float64, len(group))
+		for i, m := range group {
+			frequencies[i] = m.signal.Frequency
+			weights[i] = math.Max(m.signal.SignalStrength, 0)
+		}
+
+		ch := rfh.plan.channels[group[0].channel]
+		consensus := ChannelSync{Channel: ch.ID, Nominal: ch.Center, Reference: sortedMedian(frequencies)}
+		if method == ReferenceWeightedMedian {
+			consensus.Reference = weightedMedian(frequencies, weights)
+		}
+		deviations := make([]float64, len(group))
+		for i, f := range frequencies {
+			deviations[i] = math.Abs(f - consensus.Reference)
+		}
+		sort.Float64s(deviations)
+		consensus.Spread = 1.4826 * sortedMedian(deviations) // Consistent with the standard deviation for Gaussian offsets
+
+		consensus.Offsets = make([]FrequencyOffset, len(group))
+		for i, m := range group {
+			offset := m.signal.Frequency - consensus.Reference
+			consensus.Offsets[i] = FrequencyOffset{
+				SignalID:  m.signal.ID,
+				Frequency: m.signal.Frequency,
+				Offset:    offset,
+				Outlier:   math.Abs(offset) > outlierMADs*consensus.Spread && consensus.Spread > 0,
+			}
+		}
+		report.Channels = append(report.Channels, consensus)
+		start = end
+	}
+	return report
+}
+
+// sortedMedian returns the median of ascending values.
+func sortedMedian(values []float64) float64 {
+	n := len(values)
+	if n == 0 {
+		return math.NaN()
+	}
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// weightedMedian returns the weighted median of ascending values, falling back to the plain
+// median when every weight is zero.
+func weightedMedian(values, weights []float64) float64 {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	if total == 0 {
+		return sortedMedian(values)
+	}
+	var cumulative float64
+	for i, w := range weights {
+		cumulative += w
+		if cumulative > total/2 {
+			return values[i]
+		}
+		if cumulative == total/2 {
+			// Exactly half the weight lies at or below this value; split with the next weighted one
+			for j := i + 1; j < len(values); j++ {
+				if weights[j] > 0 {
+					return (values[i] + values[j]) / 2
+				}
+			}
+			return values[i]
+		}
+	}
+	return values[len(values)-1]
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// TestFrequencySync checks that SynchronizeFrequencies keeps channels apart, finds each
+// channel's reference despite outliers and does not depend on signal order.
+func TestFrequencySync(t *testing.T) {
+	plan, err := PlanFromFrequencies([]float64{2.4e9, 5.8e9, 8.5e9, 12.0e9}, 20e6, 5e6)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	rng := rand.New(rand.NewSource(3))
+	drift := map[string]float64{} // True offset of each channel's transmitter from nominal
+	var signals []RFSignal
+	for i, ch := range plan.Channels() {
+		drift[ch.ID] = float64(i+1) * 1500
+		for k := 0; k < 25; k++ {
+			signals = append(signals, RFSignal{
+				Frequency:      ch.Center + drift[ch.ID] + 200*rng.NormFloat64(),
+				SignalStrength: 0.5 + 0.5*rng.Float64(),
+				ID:             fmt.Sprintf("%s-%02d", ch.ID, k),
+				Channel:        ch.ID,
+			})
+		}
+		// A weak spur far off the consensus, and a signal with no channel assigned
+		signals = append(signals,
+			RFSignal{Frequency: ch.Center - 4e6, SignalStrength: 0.05, ID: ch.ID + "-spur", Channel: ch.ID},
+			RFSignal{Frequency: ch.Center + drift[ch.ID], SignalStrength: 0.9, ID: ch.ID + "-untagged"})
+	}
+	// Signals between channels, untagged or tagged with a channel the plan does not have
+	signals = append(signals,
+		RFSignal{Frequency: 3.1e9, SignalStrength: 0.9, ID: "stray"},
+		RFSignal{Frequency: 2.4e9 + 16e6, SignalStrength: 0.9, ID: "beyond-guard", Channel: "CH-X"})
+
+	for _, method := range []ReferenceMethod{ReferenceMedian, ReferenceWeightedMedian} {
+		rfh := NewRFHandler(plan)
+		for _, i := range rng.Perm(len(signals)) {
+			rfh.AddSignal(signals[i])
+		}
+		report := rfh.SynchronizeFrequencies(method)
+
+		shuffled := NewRFHandler(plan)
+		for _, i := range rng.Perm(len(signals)) {
+			shuffled.AddSignal(signals[i])
+		}
+		again := shuffled.SynchronizeFrequencies(method)
+
+		if len(report.Channels) != len(drift) {
+			t.Errorf("%v: %d channels, want %d", method, len(report.Channels), len(drift))
+		}
+		if fmt.Sprint(report) != fmt.Sprint(again) {
+			t.Errorf("%v: report depends on signal order\n%v\n%v", method, report, again)
+		}
+		if len(report.Unassigned) != 2 || report.Unassigned[0].SignalID != "beyond-guard" || report.Unassigned[1].SignalID != "stray" {
+			t.Errorf("%v: unassigned %+v, want beyond-guard and stray", method, report.Unassigned)
+		} else if report.Unassigned[0].Offset != 16e6 {
+			t.Errorf("%v: beyond-guard offset %.0f Hz from the nearest center, want 16 MHz", method, report.Unassigned[0].Offset)
+		}
+		for _, ch := range report.Channels {
+			if len(ch.Offsets) != 27 {
+				t.Errorf("%v %s: %d offsets, want 27", method, ch.Channel, len(ch.Offsets))
+			}
+			if math.Abs(ch.Reference-ch.Nominal-drift[ch.Channel]) > 150 {
+				t.Errorf("%v %s: reference %+.1f Hz from nominal, transmitter drift %+.1f Hz",
+					method, ch.Channel, ch.Reference-ch.Nominal, drift[ch.Channel])
+			}
+			if spur, _ := report.Offset(ch.Channel + "-spur"); !spur.Outlier {
+				t.Errorf("%v %s: spur not flagged as an outlier", method, ch.Channel)
+			}
+			if untagged, _ := report.Offset(ch.Channel + "-untagged"); untagged.Outlier {
+				t.Errorf("%v %s: untagged signal flagged as an outlier", method, ch.Channel)
+			}
+		}
+	}
+}
+
+// TestFrequencySyncScale checks that synchronization is n log n: 200k signals should
+// synchronize in well under a second.
+func TestFrequencySyncScale(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping timing test in short mode")
+	}
+	plan, err := PlanFromFrequencies([]float64{2.4e9, 5.8e9, 8.5e9, 12.0e9}, 20e6, 5e6)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	rng := rand.New(rand.NewSource(3))
+	rfh := NewRFHandler(plan)
+	for i := 0; i < 200000; i++ {
+		ch := plan.channels[i%len(plan.channels)]
+		rfh.AddSignal(RFSignal{Frequency: ch.Center + 1e3*rng.NormFloat64(), SignalStrength: rng.Float64(), ID: fmt.Sprint(i)})
+	}
+	start := time.Now()
+	report := rfh.SynchronizeFrequencies(ReferenceWeightedMedian)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("200000 signals synchronized in %v", elapsed.Round(time.Millisecond))
+	}
+	if len(report.Channels) != len(plan.channels) {
+		t.Errorf("%d channels, want %d", len(report.Channels), len(plan.channels))
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// HopGenerator selects how a hop sequence is derived from the shared seed and key.
+type HopGenerator int
+
+const (
+	HopAESCTR HopGenerator = iota // AES-256 in counter mode, one block per hop
+	HopLFSR                       // 64-bit Galois LFSR, 16 clocks per hop
+)
+
+// String returns the generator name.
+func (g HopGenerator) String() string {
+	switch g {
+	case HopAESCTR:
+		return "AES-CTR"
+	case HopLFSR:
+		return "LFSR"
+	}
+	return fmt.Sprintf("HopGenerator(%d)", int(g))
+}
+
+// HopConfig is the configuration shared by a frequency-hopping transmitter and receiver.
+type HopConfig struct {
+	Generator HopGenerator
+	Seed      uint64
+	Key       []byte
+	Dwell     time.Duration // Time spent on each hop; 0 means 10 ms
+	Epoch     time.Time     // Start of hop 0; zero means the Unix epoch
+}
+
+// HopSequence maps hop numbers to channel indices. Any hop can be computed directly,
+// so a receiver can jump to the hop it needs without replaying the sequence.
+type HopSequence struct {
+	generator HopGenerator
+	channels  int
+	block     cipher.Block
+	state     uint64 // LFSR state before hop 0
+}
+
+// NewHopSequence derives a hop sequence over the given number of channels from the
+// config's generator, seed and key.
+func NewHopSequence(config HopConfig, channels int) (*HopSequence, error) {
+	if channels < 2 {
+		return nil, fmt.Errorf("frequency hopping needs at least 2 channels, got %d", channels)
+	}
+
+	material := make([]byte, 8, 8+len(config.Key))
+	binary.BigEndian.PutUint64(material, config.Seed)
+	digest := sha256.Sum256(append(material, config.Key...))
+
+	sequence := &HopSequence{generator: config.Generator, channels: channels}
+	switch config.Generator {
+	case HopAESCTR:
+		block, err := aes.NewCipher(digest[:])
+		if err != nil {
+			return nil, err
+		}
+		sequence.block = block
+	case HopLFSR:
+		sequence.state = binary.BigEndian.Uint64(digest[:8])
+		if sequence.state == 0 {
+			sequence.state = 1 // The all-zero state never leaves zero
+		}
+	default:
+		return nil, fmt.Errorf("unknown hop generator %v", config.Generator)
+	}
+	return sequence, nil
+}
+
+// Channel returns the channel index for the given hop.
+func (hs *HopSequence) Channel(hop int64) int {
+	var value uint64
+	switch hs.generator {
+	case HopAESCTR:
+		var counter, output [aes.BlockSize]byte
+		binary.BigEndian.PutUint64(counter[8:], uint64(hop))
+		hs.block.Encrypt(output[:], counter[:])
+		value = binary.BigEndian.Uint64(output[:8])
+	default:
+		// Hop n uses the 16 bits clocked in after 16(n+1) steps
+		value = lfsrJump(hs.state, uint64(hop)+1) << 48
+	}
+	channel, _ := bits.Mul64(value, uint64(hs.channels))
+	return int(channel)
+}
+
+// Channels returns the number of channels the sequence hops over.
+func (hs *HopSequence) Channels() int {
+	return hs.channels
+}
+
+const lfsrTaps = 0xD800000000000000 // x^64 + x^63 + x^61 + x^60 + 1
+
+// lfsrMatrix is a linear map on the LFSR state, stored as the image of each state bit.
+type lfsrMatrix [64]uint64
+
+var (
+	lfsrJumpsOnce sync.Once
+	lfsrJumps     [64]lfsrMatrix // lfsrJumps[i] advances the LFSR by 16·2^i clocks
+)
+
+// lfsrStep clocks the Galois LFSR once.
+func lfsrStep(state uint64) uint64 {
+	return state>>1 ^ -(state&1)&lfsrTaps
+}
+
+// apply multiplies the state by the matrix over GF(2).
+func (m *lfsrMatrix) apply(state uint64) uint64 {
+	var result uint64
+	for state != 0 {
+		result ^= m[bits.TrailingZeros64(state)]
+		state &= state - 1
+	}
+	return result
+}
+
+// lfsrJump advances the state by 16·hops clocks using the precomputed power-of-two jumps.
+func lfsrJump(state, hops uint64) uint64 {
+	lfsrJumpsOnce.Do(func() {
+		for i := range lfsrJumps[0] {
+			column := uint64(1) << uint(i)
+			for clock := 0; clock < 16; clock++ {
+				column = lfsrStep(column)
+			}
+			lfsrJumps[0][i] = column
+		}
+		for j := 1; j < len(lfsrJumps); j++ {
+			for i := range lfsrJumps[j] {
+				lfsrJumps[j][i] = lfsrJumps[j-1].apply(lfsrJumps[j-1][i])
+			}
+		}
+	})
+	for hops != 0 {
+		state = lfsrJumps[bits.TrailingZeros64(hops)].apply(state)
+		hops &= hops - 1
+	}
+	return state
+}
+
+// HopScheduler places a hop sequence in time over a set of frequency points.
+type HopScheduler struct {
+	sequence *HopSequence
+	points   []float64
+	dwell    time.Duration
+	epoch    time.Time
+}
+
+// NewHopScheduler creates a scheduler that hops over the given frequency points.
+func NewHopScheduler(config HopConfig, frequencyPoints []float64) (*HopScheduler, error) {
+	if config.Dwell < 0 {
+		return nil, fmt.Errorf("hop dwell time must be positive, got %v", config.Dwell)
+	}
+	if config.Dwell == 0 {
+		config.Dwell = 10 * time.Millisecond
+	}
+	if config.Epoch.IsZero() {
+		config.Epoch = time.Unix(0, 0)
+	}
+	sequence, err := NewHopSequence(config, len(frequencyPoints))
+	if err != nil {
+		return nil, err
+	}
+	return &HopScheduler{
+		sequence: sequence,
+		points:   append([]float64(nil), frequencyPoints...),
+		dwell:    config.Dwell,
+		epoch:    config.Epoch,
+	}, nil
+}
+
+// HopAt returns the hop in progress at time t.
+func (s *HopScheduler) HopAt(t time.Time) int64 {
+	return floorDiv(t.Sub(s.epoch), s.dwell)
+}
+
+// Start returns the time the given hop begins.
+func (s *HopScheduler) Start(hop int64) time.Time {
+	return s.epoch.Add(time.Duration(hop) * s.dwell)
+}
+
+// Channel returns the index into the frequency points used by the given hop.
+func (s *HopScheduler) Channel(hop int64) int {
+	return s.sequence.Channel(hop)
+}
+
+// Frequency returns the frequency in use at time t.
+func (s *HopScheduler) Frequency(t time.Time) float64 {
+	return s.points[s.Channel(s.HopAt(t))]
+}
+
+// Dwell returns the time spent on each hop.
+func (s *HopScheduler) Dwell() time.Duration {
+	return s.dwell
+}
+
+// nearestChannel returns the index of the frequency point closest to frequency.
+func (s *HopScheduler) nearestChannel(frequency float64) int {
+	best := 0
+	for i, point := range s.points {
+		if math.Abs(point-frequency) < math.Abs(s.points[best]-frequency) {
+			best = i
+		}
+	}
+	return best
+}
+
+// floorDiv divides d by dwell, rounding towards negative infinity.
+func floorDiv(d, dwell time.Duration) int64 {
+	hop := int64(d / dwell)
+	if d%dwell < 0 {
+		hop--
+	}
+	return hop
+}
+
+// floorMod returns d modulo dwell in [0, dwell).
+func floorMod(d, dwell time.Duration) time.Duration {
+	r := d % dwell
+	if r < 0 {
+		r += dwell
+	}
+	return r
+}
+
+// HopSyncConfig tunes the receiver's hop acquisition and tracking.
+type HopSyncConfig struct {
+	Uncertainty time.Duration // Largest expected offset between receiver and transmitter clocks; 0 means 1 s
+	Window      int           // Hops observed per acquisition attempt; 0 means 32
+	MinMatch    float64       // Fraction of window hops that must match to acquire; 0 means 0.8
+	LoopGain    float64       // Timing correction applied per observed hop boundary; 0 means 0.1
+	Guard       float64       // Fraction of the dwell at each hop edge where mismatches are ignored; 0 means 0.1
+	MaxMisses   int           // Consecutive mismatches that drop lock; 0 means 8
+}
+
+// hopObservation is a channel seen by the receiver at a time on its own clock.
+type hopObservation struct {
+	time    time.Time
+	channel int
+}
+
+// HopSynchronizer acquires and tracks the transmitter's hop timing from the channels the
+// receiver observes. It shares the transmitter's HopConfig, and its offset is the time by
+// which the receiver's clock leads the transmitter's.
+type HopSynchronizer struct {
+	schedule    *HopScheduler
+	config      HopSyncConfig
+	locked      bool
+	offset      time.Duration
+	misses      int
+	buffer      []hopObservation
+	previous    *hopObservation
+	lastAttempt time.Time
+	acquired    int
+	lost        int
+}
+
+// NewHopSynchronizer creates a receiver synchronizer for the shared hop config.
+func NewHopSynchronizer(hop HopConfig, frequencyPoints []float64, config HopSyncConfig) (*HopSynchronizer, error) {
+	schedule, err := NewHopScheduler(hop, frequencyPoints)
+	if err != nil {
+		return nil, err
+	}
+	if config.Uncertainty < 0 || config.Window < 0 || config.MinMatch < 0 || config.MinMatch > 1 ||
+		config.LoopGain < 0 || config.LoopGain > 1 || config.Guard < 0 || config.Guard >= 0.5 || config.MaxMisses < 0 {
+		return nil, fmt.Errorf("invalid hop synchronizer config %+v", config)
+	}
+	if config.Uncertainty == 0 {
+		config.Uncertainty = time.Second
+	}
+	if config.Window == 0 {
+		config.Window = 32
+	}
+	if config.MinMatch == 0 {
+		config.MinMatch = 0.8
+	}
+	if config.LoopGain == 0 {
+		config.LoopGain = 0.1
+	}
+	if config.Guard == 0 {
+		config.Guard = 0.1
+	}
+	if config.MaxMisses == 0 {
+		config.MaxMisses = 8
+	}
+	return &HopSynchronizer{schedule: schedule, config: config}, nil
+}
+
+// Observe feeds the frequency seen at time t on the receiver's clock and reports whether
+// the synchronizer is locked to the hop timing afterwards.
+func (hs *HopSynchronizer) Observe(t time.Time, frequency float64) bool {
+	observation := hopObservation{time: t, channel: hs.schedule.nearestChannel(frequency)}
+	previous := hs.previous
+	hs.previous = &observation
+
+	if !hs.locked {
+		hs.buffer = append(hs.buffer, observation)
+		span := time.Duration(hs.config.Window) * hs.schedule.dwell
+		start := 0
+		for start < len(hs.buffer) && t.Sub(hs.buffer[start].time) > span {
+			start++
+		}
+		hs.buffer = hs.buffer[start:]
+		if t.Sub(hs.buffer[0].time) >= span-hs.schedule.dwell && t.Sub(hs.lastAttempt) >= hs.schedule.dwell {
+			hs.lastAttempt = t
+			hs.acquire()
+		}
+		return hs.locked
+	}
+
+	dwell := hs.schedule.dwell
+	if previous != nil && previous.channel != observation.channel {
+		boundary := previous.time.Add(t.Sub(previous.time) / 2)
+		timingError := floorMod(boundary.Sub(hs.schedule.epoch)-hs.offset+dwell/2, dwell) - dwell/2
+		hs.offset += time.Duration(hs.config.LoopGain * float64(timingError))
+	}
+
+	position := floorMod(t.Sub(hs.schedule.epoch)-hs.offset, dwell)
+	guard := time.Duration(hs.config.Guard * float64(dwell))
+	if position < guard || position > dwell-guard {
+		return true
+	}
+	hop, _ := hs.Predict(t)
+	if hs.schedule.Channel(hop) == observation.channel {
+		hs.misses = 0
+		return true
+	}
+	hs.misses++
+	if hs.misses > hs.config.MaxMisses {
+		hs.locked = false
+		hs.lost++
+		hs.buffer = []hopObservation{observation}
+		logEvent(WARNING, fmt.Sprintf("Hop synchronizer lost lock at %v after %d mismatched hops", t.Format(time.RFC3339Nano), hs.misses))
+	}
+	return hs.locked
+}
+
+// acquire estimates the hop phase from the boundaries in the buffer, then searches the
+// hop numbers allowed by the clock uncertainty for the one whose channels match best.
+func (hs *HopSynchronizer) acquire() {
+	dwell := hs.schedule.dwell
+	epoch := hs.schedule.epoch
+
+	var sumCos, sumSin float64
+	var boundaries int
+	for i := 1; i < len(hs.buffer); i++ {
+		if hs.buffer[i].channel == hs.buffer[i-1].channel {
+			continue
+		}
+		boundary := hs.buffer[i-1].time.Add(hs.buffer[i].time.Sub(hs.buffer[i-1].time) / 2)
+		angle := 2 * math.Pi * float64(floorMod(boundary.Sub(epoch), dwell)) / float64(dwell)
+		sumCos += math.Cos(angle)
+		sumSin += math.Sin(angle)
+		boundaries++
+	}
+	if boundaries < hs.config.Window/4 {
+		return
+	}
+	angle := math.Atan2(sumSin, sumCos)
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+	phase := time.Duration(angle / (2 * math.Pi) * float64(dwell))
+
+	// Take the majority channel of each local slot, skipping samples near its edges
+	guard := time.Duration(hs.config.Guard * float64(dwell))
+	var slots []int64
+	var channels []int
+	votes := make(map[int]int)
+	flush := func(slot int64) {
+		best, count := -1, 0
+		for channel, n := range votes {
+			if n > count || (n == count && channel < best) {
+				best, count = channel, n
+			}
+		}
+		if best >= 0 {
+			slots = append(slots, slot)
+			channels = append(channels, best)
+		}
+		votes = make(map[int]int)
+	}
+	current := int64(math.MinInt64)
+	for _, observation := range hs.buffer {
+		local := observation.time.Sub(epoch) - phase
+		slot := floorDiv(local, dwell)
+		if slot != current {
+			flush(current)
+			current = slot
+		}
+		if position := floorMod(local, dwell); position >= guard && position <= dwell-guard {
+			votes[observation.channel]++
+		}
+	}
+	flush(current)
+	if len(slots) < hs.config.Window/2 {
+		return
+	}
+
+	// Local slot s is transmitter hop s-m when the receiver leads by phase + m·dwell
+	span := int64(hs.config.Uncertainty/dwell) + 1
+	first := slots[0] - span
+	expected := make([]int, slots[len(slots)-1]+span-first+1)
+	for i := range expected {
+		expected[i] = hs.schedule.Channel(first + int64(i))
+	}
+	bestShift, bestScore := int64(0), -1
+	for m := -span; m <= span; m++ {
+		score := 0
+		for i, slot := range slots {
+			if expected[slot-m-first] == channels[i] {
+				score++
+			}
+		}
+		if score > bestScore {
+			bestShift, bestScore = m, score
+		}
+	}
+	if float64(bestScore) < hs.config.MinMatch*float64(len(slots)) {
+		return
+	}
+
+	hs.offset = phase + time.Duration(bestShift)*dwell
+	hs.locked = true
+	hs.misses = 0
+	hs.acquired++
+	hs.buffer = nil
+	logEvent(INFO, fmt.Sprintf("Hop synchronizer acquired: offset %v, %d/%d hops matched", hs.offset, bestScore, len(slots)))
+}
+
+// Predict returns the transmitter hop and frequency in use at receiver time t.
+func (hs *HopSynchronizer) Predict(t time.Time) (int64, float64) {
+	hop := hs.schedule.HopAt(t.Add(-hs.offset))
+	return hop, hs.schedule.points[hs.schedule.Channel(hop)]
+}
+
+// Locked reports whether the synchronizer is tracking the hop timing.
+func (hs *HopSynchronizer) Locked() bool {
+	return hs.locked
+}
+
+// Offset returns the estimated time by which the receiver's clock leads the transmitter's.
+func (hs *HopSynchronizer) Offset() time.Duration {
+	return hs.offset
+}
+
+// Acquisitions returns how many times lock was acquired and lost.
+func (hs *HopSynchronizer) Acquisitions() (acquired, lost int) {
+	return hs.acquired, hs.lost
+}
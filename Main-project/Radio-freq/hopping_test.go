@@ -0,0 +1,101 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// TestFrequencyHopping checks that hop sequences are deterministic and keyed, and that a
+// receiver with an offset, drifting clock stays aligned with the transmitter over thousands of hops.
+func TestFrequencyHopping(t *testing.T) {
+	points := []float64{2.4e9, 5.8e9, 8.5e9, 12.0e9}
+	const hops = 5000
+	const samplesPerHop = 4
+
+	for _, generator := range []HopGenerator{HopAESCTR, HopLFSR} {
+		config := HopConfig{Generator: generator, Seed: 0x5eed, Key: []byte("ground-station-7"), Dwell: 10 * time.Millisecond}
+		tx, err := NewHopScheduler(config, points)
+		if err != nil {
+			t.Errorf("%v: %v", generator, err)
+			continue
+		}
+
+		// Same seed and key give the same sequence; another key gives a different one
+		twin, _ := NewHopScheduler(config, points)
+		other := config
+		other.Key = []byte("ground-station-8")
+		stranger, _ := NewHopScheduler(other, points)
+		counts := make([]int, len(points))
+		same, agree := true, 0
+		for hop := int64(0); hop < hops; hop++ {
+			channel := tx.Channel(hop)
+			same = same && channel == twin.Channel(hop)
+			if channel == stranger.Channel(hop) {
+				agree++
+			}
+			counts[channel]++
+		}
+		if !same {
+			t.Errorf("%v: the same seed and key gave different sequences", generator)
+		}
+		for _, count := range counts {
+			if math.Abs(float64(count)-hops/float64(len(points))) >= 0.1*hops/float64(len(points)) {
+				t.Errorf("%v: unbalanced channel counts %v", generator, counts)
+				break
+			}
+		}
+		if float64(agree) > 0.3*hops {
+			t.Errorf("%v: %d/%d hops shared with another key", generator, agree, hops)
+		}
+
+		// The receiver's clock leads by 237.3 ms and runs 20 ppm fast; 1% of observations are wrong
+		rx, err := NewHopSynchronizer(config, points, HopSyncConfig{Uncertainty: 500 * time.Millisecond})
+		if err != nil {
+			t.Errorf("%v: %v", generator, err)
+			continue
+		}
+		rng := rand.New(rand.NewSource(int64(generator) + 1))
+		step := config.Dwell / samplesPerHop
+		start := tx.Start(1000).Add(step / 2)
+		const lead = 237300 * time.Microsecond
+		const ppm = 20e-6
+		lockedAt, aligned, compared := int64(-1), 0, 0
+		var truth time.Duration
+		for k := 0; k < hops*samplesPerHop; k++ {
+			elapsed := time.Duration(k)*step + time.Duration((rng.Float64()-0.5)*float64(step)/5)
+			sent := start.Add(elapsed)
+			truth = lead + time.Duration(ppm*float64(elapsed))
+			received := sent.Add(truth)
+			frequency := tx.Frequency(sent)
+			if rng.Float64() < 0.01 {
+				frequency = points[rng.Intn(len(points))]
+			}
+			if !rx.Observe(received, frequency) {
+				continue
+			}
+			hop, _ := rx.Predict(received)
+			if lockedAt < 0 {
+				lockedAt = tx.HopAt(sent) - 1000
+			}
+			compared++
+			if hop == tx.HopAt(sent) {
+				aligned++
+			}
+		}
+		_, lost := rx.Acquisitions()
+		if lockedAt < 0 || lockedAt > 64 {
+			t.Errorf("%v: locked after %d hops, want within 64", generator, lockedAt)
+		}
+		if lost > 0 {
+			t.Errorf("%v: lost lock %d times", generator, lost)
+		}
+		if float64(aligned) < 0.999*float64(compared) {
+			t.Errorf("%v: %d/%d observations aligned", generator, aligned, compared)
+		}
+		if residual := rx.Offset() - truth; math.Abs(float64(residual)) > float64(config.Dwell)/20 {
+			t.Errorf("%v: timing error %v", generator, residual)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	earthRadiusKm     = 6371.0
+	earthGM           = 398600.4418 // km^3/s^2
+	boltzmannDB       = -228.6      // dBW/K/Hz
+	referenceTemp     = 290.0       // K
+	cosmicTemp        = 2.725       // K
+	defaultRadiating  = 275.0       // Mean radiating temperature of the atmosphere and rain, in K
+	defaultWaterVapor = 7.5         // g/m^3
+)
+
+// Antenna is a transmit or receive antenna. A reflector with a Diameter has gain and
+// beamwidth that follow frequency; otherwise GainDBi and Beamwidth are fixed.
+type Antenna struct {
+	GainDBi       float64 // Peak gain when Diameter is 0, in dBi
+	Beamwidth     float64 // Half-power beamwidth when Diameter is 0, in degrees; 0 means an omnidirectional pattern
+	Diameter      float64 // Reflector diameter, in m
+	Efficiency    float64 // Aperture efficiency; 0 means 0.55
+	SidelobeLevel float64 // Floor of the pattern below the peak, in dB; 0 means 30
+	PointingError float64 // Pointing error of the main beam, in degrees
+}
+
+// PeakGain returns the boresight gain at frequency, in dBi.
+func (a Antenna) PeakGain(frequency float64) float64 {
+	if a.Diameter == 0 {
+		return a.GainDBi
+	}
+	efficiency := a.Efficiency
+	if efficiency == 0 {
+		efficiency = 0.55
+	}
+	return 10 * math.Log10(efficiency*math.Pow(math.Pi*a.Diameter*frequency/SPEED_OF_LIGHT, 2))
+}
+
+// HalfPowerBeamwidth returns the half-power beamwidth at frequency, in degrees, or 0 for
+// an omnidirectional pattern.
+func (a Antenna) HalfPowerBeamwidth(frequency float64) float64 {
+	if a.Diameter == 0 {
+		return a.Beamwidth
+	}
+	return 70 * SPEED_OF_LIGHT / frequency / a.Diameter
+}
+
+// Gain returns the gain at an angle off boresight, in dBi, from a parabolic main beam that
+// falls to the sidelobe floor.
+func (a Antenna) Gain(frequency, offAxis float64) float64 {
+	peak := a.PeakGain(frequency)
+	beamwidth := a.HalfPowerBeamwidth(frequency)
+	if beamwidth == 0 {
+		return peak
+	}
+	floor := a.SidelobeLevel
+	if floor == 0 {
+		floor = 30
+	}
+	return peak - math.Min(12*math.Pow(offAxis/beamwidth, 2), floor)
+}
+
+// LinkConfig describes a space-to-ground link.
+type LinkConfig struct {
+	TxPowerW           float64
+	TxLossDB           float64 // Transmitter losses between amplifier and antenna
+	TxAntenna          Antenna
+	TxNadirPointing    bool // Transmit antenna points at nadir, so the ground station is off boresight by the nadir angle
+	RxAntenna          Antenna
+	FeedLossDB         float64 // Receive losses between antenna and LNA
+	NoiseFigureDB      float64 // Receiver noise figure
+	SpilloverTemp      float64 // Ground noise picked up by the receive antenna, in K
+	PolarizationLossDB float64
+	DataRate           float64 // in bit/s
+	RequiredEbN0dB     float64
+	ImplementationDB   float64 // Demodulator implementation loss
+
+	StationAltitudeKm  float64
+	StationLatitudeDeg float64
+	WaterVapourDensity float64 // Surface water vapour density, in g/m^3; 0 means 7.5
+	RainRate           float64 // Rain rate exceeded 0.01% of an average year, in mm/h; 0 means no rain
+	RainExceedance     float64 // Percentage of time the rain attenuation is exceeded; 0 means 0.01
+	RadiatingTemp      float64 // Mean radiating temperature of the absorbing atmosphere, in K; 0 means 275
+}
+
+// DefaultLinkConfig is a 2 W LEO downlink with a broad-beam spacecraft antenna received on
+// a 3.7 m tracking dish at a mid-latitude station.
+func DefaultLinkConfig() LinkConfig {
+	return LinkConfig{
+		TxPowerW:           2,
+		TxLossDB:           1,
+		TxAntenna:          Antenna{GainDBi: 3, Beamwidth: 120, SidelobeLevel: 15},
+		TxNadirPointing:    true,
+		RxAntenna:          Antenna{Diameter: 3.7, Efficiency: 0.55, PointingError: 0.1},
+		FeedLossDB:         0.3,
+		NoiseFigureDB:      0.8,
+		SpilloverTemp:      15,
+		PolarizationLossDB: 0.2,
+		DataRate:           1e6,
+		RequiredEbN0dB:     9.6, // BPSK at a bit error rate of 1e-5
+		ImplementationDB:   1,
+		StationAltitudeKm:  0.1,
+		StationLatitudeDeg: 45,
+		RainRate:           35,
+		RainExceedance:     0.1,
+	}
+}
+
+// PassGeometry is an overhead pass of a satellite in a circular orbit, ignoring Earth rotation.
+type PassGeometry struct {
+	AltitudeKm      float64
+	MaxElevationDeg float64
+	MinElevationDeg float64       // Elevation of acquisition and loss of signal; 0 means 5
+	Step            time.Duration // Time between pass samples; 0 means 10 s
+}
+
+// validate checks the geometry and fills in defaults.
+func (g PassGeometry) validate() (PassGeometry, error) {
+	if g.MinElevationDeg == 0 {
+		g.MinElevationDeg = 5
+	}
+	if g.Step == 0 {
+		g.Step = 10 * time.Second
+	}
+	switch {
+	case g.AltitudeKm <= 0:
+		return g, fmt.Errorf("pass altitude must be positive, got %g km", g.AltitudeKm)
+	case g.MinElevationDeg < 0 || g.MaxElevationDeg > 90 || g.MaxElevationDeg < g.MinElevationDeg:
+		return g, fmt.Errorf("pass elevations must satisfy 0 <= minimum (%g°) <= maximum (%g°) <= 90", g.MinElevationDeg, g.MaxElevationDeg)
+	case g.Step < 0:
+		return g, fmt.Errorf("pass step must be positive, got %v", g.Step)
+	}
+	return g, nil
+}
+
+// centralAngle returns the Earth-central angle between station and satellite seen at elevation e.
+func (g PassGeometry) centralAngle(e float64) float64 {
+	return math.Acos(earthRadiusKm*math.Cos(e)/(earthRadiusKm+g.AltitudeKm)) - e
+}
+
+// Duration returns the time from acquisition to loss of signal.
+func (g PassGeometry) Duration() time.Duration {
+	g, _ = g.validate()
+	orbit := earthRadiusKm + g.AltitudeKm
+	rate := math.Sqrt(earthGM / (orbit * orbit * orbit))
+	closest := math.Cos(g.centralAngle(g.MaxElevationDeg * math.Pi / 180))
+	edge := math.Cos(g.centralAngle(g.MinElevationDeg * math.Pi / 180))
+	half := math.Acos(math.Min(edge/closest, 1)) / rate
+	return time.Duration(2 * half * float64(time.Second))
+}
+
+// At returns the elevation and nadir angle, in degrees, and slant range, in km, at time t
+// after acquisition of signal.
+func (g PassGeometry) At(t time.Duration) (elevationDeg, rangeKm, nadirDeg float64) {
+	g, _ = g.validate()
+	orbit := earthRadiusKm + g.AltitudeKm
+	rate := math.Sqrt(earthGM / (orbit * orbit * orbit))
+	closest := g.centralAngle(g.MaxElevationDeg * math.Pi / 180)
+	fromCulmination := (t - g.Duration()/2).Seconds()
+
+	lambda := math.Acos(math.Cos(closest) * math.Cos(rate*fromCulmination))
+	elevation := math.Atan2(math.Cos(lambda)-earthRadiusKm/orbit, math.Sin(lambda))
+	rangeKm = math.Sqrt(earthRadiusKm*earthRadiusKm + orbit*orbit - 2*earthRadiusKm*orbit*math.Cos(lambda))
+	nadir := math.Asin(earthRadiusKm * math.Cos(elevation) / orbit)
+	return elevation * 180 / math.Pi, rangeKm, nadir * 180 / math.Pi
+}
+
+// LinkSample is the link budget at one point of a pass.
+type LinkSample struct {
+	Time           time.Duration // Since acquisition of signal
+	ElevationDeg   float64
+	RangeKm        float64
+	EIRPdBW        float64 // Toward the station, including the transmit pattern
+	PathLossDB     float64 // Free-space path loss
+	GasLossDB      float64 // Oxygen and water vapour absorption
+	RainLossDB     float64
+	PointingLossDB float64 // Receive antenna pointing loss
+	RxGainDBi      float64
+	SystemTempK    float64 // At the LNA input
+	ReceivedDBW    float64
+	CN0dBHz        float64
+	EbN0dB         float64
+	MarginDB       float64 // EbN0dB less the required Eb/N0 and implementation loss
+}
+
+// LinkPass is the link budget of one frequency over a pass.
+type LinkPass struct {
+	Channel     string
+	Frequency   float64
+	Samples     []LinkSample
+	MinMarginDB float64
+	MaxMarginDB float64
+	Closed      time.Duration // Time with a non-negative margin
+}
+
+// Closes reports whether the link has a non-negative margin for the whole pass.
+func (p LinkPass) Closes() bool {
+	return p.MinMarginDB >= 0
+}
+
+// LinkBudget computes link budgets from a validated LinkConfig.
+type LinkBudget struct {
+	config LinkConfig
+}
+
+// NewLinkBudget validates the config and fills in defaults.
+func NewLinkBudget(config LinkConfig) (*LinkBudget, error) {
+	if config.WaterVapourDensity == 0 {
+		config.WaterVapourDensity = defaultWaterVapor
+	}
+	if config.RainExceedance == 0 {
+		config.RainExceedance = 0.01
+	}
+	if config.RadiatingTemp == 0 {
+		config.RadiatingTemp = defaultRadiating
+	}
+	switch {
+	case config.TxPowerW <= 0:
+		return nil, fmt.Errorf("transmit power must be positive, got %g W", config.TxPowerW)
+	case config.DataRate <= 0:
+		return nil, fmt.Errorf("data rate must be positive, got %g bit/s", config.DataRate)
+	case config.TxLossDB < 0 || config.FeedLossDB < 0 || config.NoiseFigureDB < 0 || config.PolarizationLossDB < 0 || config.ImplementationDB < 0:
+		return nil, errors.New("losses and noise figure must not be negative")
+	case config.SpilloverTemp < 0 || config.WaterVapourDensity < 0 || config.RainRate < 0:
+		return nil, errors.New("spillover temperature, water vapour density and rain rate must not be negative")
+	case config.RainExceedance < 0.001 || config.RainExceedance > 5:
+		return nil, fmt.Errorf("rain exceedance must be between 0.001%% and 5%%, got %g%%", config.RainExceedance)
+	case config.StationLatitudeDeg < -90 || config.StationLatitudeDeg > 90:
+		return nil, fmt.Errorf("station latitude must be in [-90, 90], got %g", config.StationLatitudeDeg)
+	}
+	for _, a := range []Antenna{config.TxAntenna, config.RxAntenna} {
+		if a.Diameter < 0 || a.Beamwidth < 0 || a.Efficiency < 0 || a.Efficiency > 1 || a.SidelobeLevel < 0 {
+			return nil, fmt.Errorf("invalid antenna %+v", a)
+		}
+	}
+	return &LinkBudget{config: config}, nil
+}
+
+// Evaluate returns the link budget at a frequency, elevation, slant range and nadir angle.
+func (lb *LinkBudget) Evaluate(frequency, elevationDeg, rangeKm, nadirDeg float64) LinkSample {
+	c := lb.config
+	sample := LinkSample{ElevationDeg: elevationDeg, RangeKm: rangeKm}
+
+	offAxis := 0.0
+	if c.TxNadirPointing {
+		offAxis = nadirDeg
+	}
+	sample.EIRPdBW = 10*math.Log10(c.TxPowerW) - c.TxLossDB + c.TxAntenna.Gain(frequency, offAxis)
+	sample.PathLossDB = FreeSpacePathLoss(frequency, rangeKm*1e3)
+	sample.GasLossDB = GaseousAttenuation(frequency, elevationDeg, c.WaterVapourDensity)
+	sample.RainLossDB = RainAttenuation(frequency, elevationDeg, c.RainRate, c.RainExceedance, c.StationLatitudeDeg, c.StationAltitudeKm)
+	sample.RxGainDBi = c.RxAntenna.PeakGain(frequency)
+	sample.PointingLossDB = sample.RxGainDBi - c.RxAntenna.Gain(frequency, c.RxAntenna.PointingError)
+
+	// The absorbing atmosphere radiates in proportion to what it absorbs
+	transmission := math.Pow(10, -(sample.GasLossDB+sample.RainLossDB)/10)
+	antennaTemp := cosmicTemp*transmission + c.RadiatingTemp*(1-transmission) + c.SpilloverTemp
+	feed := math.Pow(10, c.FeedLossDB/10)
+	receiverTemp := referenceTemp * (math.Pow(10, c.NoiseFigureDB/10) - 1)
+	sample.SystemTempK = antennaTemp/feed + referenceTemp*(1-1/feed) + receiverTemp
+
+	sample.ReceivedDBW = sample.EIRPdBW - sample.PathLossDB - sample.GasLossDB - sample.RainLossDB - c.PolarizationLossDB +
+		sample.RxGainDBi - sample.PointingLossDB - c.FeedLossDB
+	sample.CN0dBHz = sample.ReceivedDBW - boltzmannDB - 10*math.Log10(sample.SystemTempK)
+	sample.EbN0dB = sample.CN0dBHz - 10*math.Log10(c.DataRate)
+	sample.MarginDB = sample.EbN0dB - c.RequiredEbN0dB - c.ImplementationDB
+	return sample
+}
+
+// EvaluatePass returns the link budget of a frequency at every step of a pass.
+func (lb *LinkBudget) EvaluatePass(frequency float64, geometry PassGeometry) (LinkPass, error) {
+	geometry, err := geometry.validate()
+	if err != nil {
+		return LinkPass{}, err
+	}
+	pass := LinkPass{Frequency: frequency, MinMarginDB: math.Inf(1), MaxMarginDB: math.Inf(-1)}
+	duration := geometry.Duration()
+	for t := time.Duration(0); ; t += geometry.Step {
+		if t > duration {
+			t = duration
+		}
+		elevation, rangeKm, nadir := geometry.At(t)
+		sample := lb.Evaluate(frequency, elevation, rangeKm, nadir)
+		sample.Time = t
+		pass.Samples = append(pass.Samples, sample)
+		pass.MinMarginDB = math.Min(pass.MinMarginDB, sample.MarginDB)
+		pass.MaxMarginDB = math.Max(pass.MaxMarginDB, sample.MarginDB)
+		if t == duration {
+			break
+		}
+	}
+	for i := 1; i < len(pass.Samples); i++ {
+		if pass.Samples[i-1].MarginDB >= 0 && pass.Samples[i].MarginDB >= 0 {
+			pass.Closed += pass.Samples[i].Time - pass.Samples[i-1].Time
+		}
+	}
+	return pass, nil
+}
+
+// EvaluatePlan returns the pass link budget of every channel in a plan at its center frequency.
+func (lb *LinkBudget) EvaluatePlan(plan *ChannelPlan, geometry PassGeometry) ([]LinkPass, error) {
+	passes := make([]LinkPass, 0, len(plan.channels))
+	for _, ch := range plan.channels {
+		pass, err := lb.EvaluatePass(ch.Center, geometry)
+		if err != nil {
+			return nil, err
+		}
+		pass.Channel = ch.ID
+		passes = append(passes, pass)
+	}
+	return passes, nil
+}
+
+// String returns the pass summary: whether the link closes, its margin range and how
+// long it stays closed.
+func (p LinkPass) String() string {
+	closes := "closes"
+	if !p.Closes() {
+		closes = "does not close"
+	}
+	return fmt.Sprintf("%s (%.3f GHz): %s, margin %.1f to %.1f dB, closed for %v",
+		p.Channel, p.Frequency/1e9, closes, p.MinMarginDB, p.MaxMarginDB, p.Closed.Round(time.Second))
+}
+
+// FreeSpacePathLoss returns the free-space path loss over a distance in m, in dB.
+func FreeSpacePathLoss(frequency, distance float64) float64 {
+	return 20 * math.Log10(4*math.Pi*distance*frequency/SPEED_OF_LIGHT)
+}
+
+// GaseousAttenuation returns the oxygen and water vapour attenuation along a slant path,
+// in dB, from the simplified specific attenuations and equivalent heights of ITU-R P.676
+// Annex 2 for frequencies below 57 GHz.
+func GaseousAttenuation(frequency, elevationDeg, waterVapour float64) float64 {
+	f := frequency / 1e9
+	oxygen := (7.19e-3 + 6.09/(f*f+0.227) + 4.81/((f-57)*(f-57)+1.50)) * f * f * 1e-3
+	water := (0.050 + 0.0021*waterVapour + 3.6/((f-22.2)*(f-22.2)+8.5) +
+		10.6/((f-183.3)*(f-183.3)+9.0) + 8.9/((f-325.4)*(f-325.4)+26.3)) * f * f * waterVapour * 1e-4
+	oxygenHeight := 6.0
+	waterHeight := 1.6 * (1 + 3/((f-22.2)*(f-22.2)+5))
+	zenith := oxygen*oxygenHeight + water*waterHeight
+	return zenith / math.Sin(math.Max(elevationDeg, 5)*math.Pi/180)
+}
+
+// rainCoefficients are the ITU-R P.838-3 k and α for horizontal and vertical polarization.
+var rainCoefficients = []struct{ f, kH, aH, kV, aV float64 }{
+	{1, 0.0000259, 0.9691, 0.0000308, 0.8592},
+	{2, 0.0000847, 1.0664, 0.0000998, 0.9490},
+	{4, 0.0001071, 1.6009, 0.0002461, 1.2476},
+	{6, 0.0007056, 1.5900, 0.0004878, 1.5728},
+	{8, 0.004115, 1.3905, 0.003450, 1.3797},
+	{10, 0.01217, 1.2571, 0.01129, 1.2156},
+	{12, 0.02386, 1.1825, 0.02455, 1.1216},
+	{15, 0.04481, 1.1233, 0.05008, 1.0440},
+	{20, 0.09164, 1.0568, 0.09611, 0.9847},
+	{30, 0.2403, 0.9485, 0.2291, 0.9129},
+}
+
+// RainSpecificAttenuation returns the specific attenuation of rain for circular
+// polarization, in dB/km, interpolating the ITU-R P.838-3 coefficients in log frequency.
+func RainSpecificAttenuation(frequency, rainRate float64) float64 {
+	f := math.Min(math.Max(frequency/1e9, rainCoefficients[0].f), rainCoefficients[len(rainCoefficients)-1].f)
+	i := sort.Search(len(rainCoefficients), func(i int) bool { return rainCoefficients[i].f >= f })
+	if i == 0 {
+		i = 1
+	}
+	lo, hi := rainCoefficients[i-1], rainCoefficients[i]
+	t := math.Log(f/lo.f) / math.Log(hi.f/lo.f)
+	interpolate := func(a, b float64) float64 { return a + t*(b-a) }
+	kH := math.Exp(interpolate(math.Log(lo.kH), math.Log(hi.kH)))
+	kV := math.Exp(interpolate(math.Log(lo.kV), math.Log(hi.kV)))
+	aH, aV := interpolate(lo.aH, hi.aH), interpolate(lo.aV, hi.aV)
+	k := (kH + kV) / 2
+	alpha := (kH*aH + kV*aV) / (2 * k)
+	return k * math.Pow(rainRate, alpha)
+}
+
+// RainAttenuation returns the rain attenuation exceeded for the given percentage of time
+// along a slant path, in dB, by the simplified ITU-R P.618 method: the slant path below the
+// rain height, a horizontal reduction factor, and scaling from the 0.01% value.
+func RainAttenuation(frequency, elevationDeg, rainRate, exceedance, latitudeDeg, stationKm float64) float64 {
+	if rainRate <= 0 {
+		return 0
+	}
+	elevation := math.Max(elevationDeg, 5) * math.Pi / 180
+	rainHeight := 5.0 // Freezing height plus 0.36 km, after the earlier ITU-R P.839 model
+	if latitude := math.Abs(latitudeDeg); latitude > 23 {
+		rainHeight = math.Max(5-0.075*(latitude-23), 0)
+	}
+	if rainHeight <= stationKm {
+		return 0
+	}
+	slant := (rainHeight - stationKm) / math.Sin(elevation)
+	horizontal := slant * math.Cos(elevation)
+	specific := RainSpecificAttenuation(frequency, rainRate)
+	reduction := 1 / (1 + 0.78*math.Sqrt(horizontal*specific/(frequency/1e9)) - 0.38*(1-math.Exp(-2*horizontal)))
+	a001 := specific * slant * reduction
+	p := exceedance
+	exponent := 0.655 + 0.033*math.Log(p) - 0.045*math.Log(a001)
+	return a001 * math.Pow(p/0.01, -exponent)
+}
+
+// linkModel drives RFSignal strengths from a link budget over repeating passes.
+type linkModel struct {
+	budget   *LinkBudget
+	geometry PassGeometry
+	start    time.Time
+}
+
+// strength returns C/(C+N) in the channel bandwidth at time t. Passes repeat back to back
+// from start, so there is no gap between them.
+func (lm *linkModel) strength(ch RFChannel, t time.Time) float64 {
+	duration := lm.geometry.Duration()
+	if duration <= 0 {
+		return 0
+	}
+	elapsed := floorMod(t.Sub(lm.start), duration)
+	elevation, rangeKm, nadir := lm.geometry.At(elapsed)
+	sample := lm.budget.Evaluate(ch.Center, elevation, rangeKm, nadir)
+	cn := sample.CN0dBHz - 10*math.Log10(ch.Bandwidth)
+	return 1 / (1 + math.Pow(10, -cn/10))
+}
+
+// SetLinkBudget derives the strength of generated signals from the link budget of their
+// channel over back-to-back passes starting at start, instead of at random. SignalStrength
+// becomes C/(C+N) in the channel bandwidth, so 0.8 corresponds to a C/N of 6 dB.
+func (rfh *RFHandler) SetLinkBudget(budget *LinkBudget, geometry PassGeometry, start time.Time) error {
+	geometry, err := geometry.validate()
+	if err != nil {
+		return err
+	}
+	rfh.mu.Lock()
+	defer rfh.mu.Unlock()
+	rfh.link = &linkModel{budget: budget, geometry: geometry, start: start}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestPropagationModels checks the propagation models against hand-computed values.
+func TestPropagationModels(t *testing.T) {
+	// 20log10(4π·1e6·2.4e9/299792458) = 160.0520 dB
+	if fspl := FreeSpacePathLoss(2.4e9, 1000e3); math.Abs(fspl-160.0520) > 1e-4 {
+		t.Errorf("FSPL at 2.4 GHz over 1000 km: %.4f dB, want 160.0520 dB", fspl)
+	}
+	// Clear-sky Ku-band zenith absorption is well under 0.1 dB, and grows at low elevation
+	gas := GaseousAttenuation(12e9, 90, 7.5)
+	if gas < 0.03 || gas > 0.1 {
+		t.Errorf("Ku zenith gas %.3f dB, want 0.03 to 0.1 dB", gas)
+	}
+	if low := GaseousAttenuation(12e9, 10, 7.5); low <= gas {
+		t.Errorf("Ku gas at 10° %.3f dB, not above zenith %.3f dB", low, gas)
+	}
+	if rainS := RainAttenuation(2.4e9, 30, 42, 0.01, 45, 0); rainS > 0.5 {
+		t.Errorf("S-band rain at 30° %.2f dB, want at most 0.5 dB", rainS)
+	}
+	rainKu := RainAttenuation(12e9, 30, 42, 0.01, 45, 0)
+	if rainKu < 3 || rainKu > 15 {
+		t.Errorf("Ku-band rain at 30° %.2f dB, want 3 to 15 dB", rainKu)
+	}
+	if often := RainAttenuation(12e9, 30, 42, 0.1, 45, 0); often >= rainKu {
+		t.Errorf("Ku-band rain exceeded 0.1%% of the time %.2f dB, not below the 0.01%% value %.2f dB", often, rainKu)
+	}
+}
+
+// TestLinkBudget checks that margins follow the pass geometry and are the sum of the budget terms.
+func TestLinkBudget(t *testing.T) {
+	budget, err := NewLinkBudget(DefaultLinkConfig())
+	if err != nil {
+		t.Fatalf("link budget: %v", err)
+	}
+	geometry := PassGeometry{AltitudeKm: 500, MaxElevationDeg: 70}
+	plan, _ := PlanFromFrequencies([]float64{2.4e9, 5.8e9, 8.5e9, 12.0e9}, 20e6, 5e6)
+	passes, err := budget.EvaluatePlan(plan, geometry)
+	if err != nil {
+		t.Fatalf("pass: %v", err)
+	}
+	for i, pass := range passes {
+		// Low in the sky, rain makes the higher channels the weaker ones
+		if i > 0 && pass.MinMarginDB >= passes[i-1].MinMarginDB {
+			t.Errorf("%v: minimum margin not below the lower channel's\n%v", pass, passes[i-1])
+		}
+		first, middle := pass.Samples[0], pass.Samples[len(pass.Samples)/2]
+		if math.Abs(first.ElevationDeg-5) > 0.01 || math.Abs(middle.ElevationDeg-70) > 1 {
+			t.Errorf("%v: elevation %.2f° at acquisition and %.2f° mid-pass", pass, first.ElevationDeg, middle.ElevationDeg)
+		}
+		if middle.MarginDB <= first.MarginDB {
+			t.Errorf("%v: mid-pass margin %.2f dB not above the acquisition margin %.2f dB", pass, middle.MarginDB, first.MarginDB)
+		}
+		sum := middle.EIRPdBW - middle.PathLossDB - middle.GasLossDB - middle.RainLossDB - 0.2 + middle.RxGainDBi -
+			middle.PointingLossDB - 0.3 - boltzmannDB - 10*math.Log10(middle.SystemTempK) - 60 - 9.6 - 1
+		if math.Abs(sum-middle.MarginDB) > 1e-9 {
+			t.Errorf("%v: margin %.6f dB, budget terms sum to %.6f dB", pass, middle.MarginDB, sum)
+		}
+	}
+}
+
+// TestLinkSignalStrength checks that RFHandler derives signal strength from the link: weak
+// at acquisition, strong at culmination.
+func TestLinkSignalStrength(t *testing.T) {
+	budget, err := NewLinkBudget(DefaultLinkConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	geometry := PassGeometry{AltitudeKm: 500, MaxElevationDeg: 70}
+	plan, _ := PlanFromFrequencies([]float64{2.4e9, 5.8e9, 8.5e9, 12.0e9}, 20e6, 5e6)
+	rfh := NewRFHandler(plan)
+	start := time.Now()
+	if err := rfh.SetLinkBudget(budget, geometry, start); err != nil {
+		t.Fatal(err)
+	}
+	low := rfh.link.strength(plan.channels[0], start)
+	high := rfh.link.strength(plan.channels[0], start.Add(geometry.Duration()/2))
+	if !(low < high) || high > 1 || low < 0 {
+		t.Errorf("signal strength %.3f at acquisition, %.3f at culmination", low, high)
+	}
+	// Passes repeat back to back, so the next one starts as the last ends
+	if next := rfh.link.strength(plan.channels[0], start.Add(geometry.Duration())); math.Abs(next-low) > 1e-12 {
+		t.Errorf("signal strength %.3f at the start of the next pass, want %.3f", next, low)
+	}
+	signal, err := rfh.GenerateChannelSignal(plan.channels[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := rfh.link.strength(plan.channels[0], signal.Timestamp); math.Abs(signal.SignalStrength-want) > 1e-6 {
+		t.Errorf("generated signal strength %.6f, link gives %.6f", signal.SignalStrength, want)
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SelectionPolicy chooses how the next transmission channel is picked from channel quality.
+type SelectionPolicy int
+
+const (
+	PolicyUCB       SelectionPolicy = iota // UCB1 bandit on the per-transmission reward
+	PolicyThompson                         // Thompson sampling from a Beta posterior on the reward
+	PolicyBlacklist                        // Best EWMA score among channels that pass the thresholds
+)
+
+// String returns the policy name.
+func (p SelectionPolicy) String() string {
+	switch p {
+	case PolicyUCB:
+		return "UCB"
+	case PolicyThompson:
+		return "Thompson"
+	case PolicyBlacklist:
+		return "blacklist"
+	}
+	return fmt.Sprintf("SelectionPolicy(%d)", int(p))
+}
+
+// ChannelQualityConfig configures channel quality tracking and selection.
+type ChannelQualityConfig struct {
+	Policy          SelectionPolicy
+	Alpha           float64       // EWMA weight of each new observation; 0 means 0.2
+	StrengthScale   float64       // Signal strength that earns full reward; 0 means 1
+	MinStrength     float64       // EWMA strength below which a channel is excluded; 0 disables
+	MaxErrorRate    float64       // EWMA error rate above which a channel is excluded; 0 means 0.1
+	MaxInterference float64       // EWMA fraction of observations with interference above which a channel is excluded; 0 means 0.3
+	MinSamples      int           // Observations before thresholds apply; 0 means 5
+	Exploration     float64       // UCB exploration weight; 0 means 1
+	Discount        float64       // Weight past rewards keep at each observation, for UCB and Thompson; 0 means 0.995
+	HistoryLength   int           // Interference events kept per channel; 0 means 32
+	Seed            int64         // Seed for Thompson sampling
+	Reprobe         time.Duration // Time after its last observation when an excluded channel is tried again; 0 means 30 s
+}
+
+// ChannelObservation is the measured outcome of one transmission on a channel.
+type ChannelObservation struct {
+	Channel      string
+	Strength     float64
+	ErrorRate    float64 // Fraction of frames or bits in error, in [0, 1]
+	Interference bool
+	Timestamp    time.Time
+}
+
+// ChannelQuality is the tracked quality of one channel.
+type ChannelQuality struct {
+	Channel      string
+	Samples      int
+	Strength     float64 // EWMA signal strength
+	ErrorRate    float64 // EWMA error rate
+	Interference float64 // EWMA fraction of observations with interference
+	Reward       float64 // Discounted mean per-transmission reward in [0, 1]
+	Weight       float64 // Discounted number of observations behind Reward
+	LastSeen     time.Time
+	History      []time.Time // Most recent interference events, oldest first
+}
+
+// Score returns the channel's EWMA quality in [0, 1].
+func (q ChannelQuality) Score(strengthScale float64) float64 {
+	return clamp01(q.Strength/strengthScale) * (1 - q.ErrorRate) * (1 - q.Interference)
+}
+
+// ChannelAssessment explains how one channel was treated by a selection.
+type ChannelAssessment struct {
+	Quality  ChannelQuality
+	Index    float64 // Policy index the channel was ranked by
+	Excluded bool
+	Reprobe  bool // Excluded but unused for the reprobe interval, so tried ahead of the ranking
+	Reason   string
+}
+
+// SelectionDecision is a channel choice and the assessment of every channel behind it.
+type SelectionDecision struct {
+	Channel     string
+	Policy      SelectionPolicy
+	Fallback    bool // Every channel was excluded and the least bad one was chosen
+	Assessments []ChannelAssessment
+}
+
+// String returns the chosen channel followed by one line per channel assessment.
+func (d SelectionDecision) String() string {
+	lines := []string{fmt.Sprintf("Selected %s by %v", d.Channel, d.Policy)}
+	if d.Fallback {
+		lines[0] += " (all channels excluded; least bad)"
+	}
+	for _, a := range d.Assessments {
+		status := "candidate"
+		switch {
+		case a.Excluded:
+			status = "excluded"
+		case a.Quality.Channel == d.Channel:
+			status = "chosen"
+		case a.Reprobe:
+			status = "due for re-probe"
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s, index %.3f, %s", a.Quality.Channel, status, a.Index, a.Reason))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ChannelQualityTracker keeps per-channel quality history and picks the best channel for
+// the next transmission.
+type ChannelQualityTracker struct {
+	mu       sync.Mutex
+	config   ChannelQualityConfig
+	ids      []string
+	quality  map[string]*ChannelQuality
+	rewards  map[string]float64 // Discounted reward sum; with Weight it gives UCB means and Thompson posteriors
+	rng      *rand.Rand
+	observed float64 // Discounted observation count over all channels
+}
+
+// NewChannelQualityTracker creates a tracker over the channels of a plan.
+func NewChannelQualityTracker(plan *ChannelPlan, config ChannelQualityConfig) (*ChannelQualityTracker, error) {
+	switch {
+	case config.Policy < PolicyUCB || config.Policy > PolicyBlacklist:
+		return nil, fmt.Errorf("unknown selection policy %v", config.Policy)
+	case config.Alpha < 0 || config.Alpha > 1:
+		return nil, fmt.Errorf("EWMA weight must be in [0, 1], got %g", config.Alpha)
+	case config.Discount < 0 || config.Discount > 1:
+		return nil, fmt.Errorf("discount must be in [0, 1], got %g", config.Discount)
+	case config.StrengthScale < 0 || config.MinStrength < 0 || config.Exploration < 0:
+		return nil, errors.New("strength scale, minimum strength and exploration weight must not be negative")
+	case config.MaxErrorRate < 0 || config.MaxErrorRate > 1 || config.MaxInterference < 0 || config.MaxInterference > 1:
+		return nil, errors.New("error rate and interference thresholds must be in [0, 1]")
+	case config.MinSamples < 0 || config.HistoryLength < 0 || config.Reprobe < 0:
+		return nil, errors.New("minimum samples, history length and reprobe interval must not be negative")
+	}
+	if config.Alpha == 0 {
+		config.Alpha = 0.2
+	}
+	if config.Discount == 0 {
+		config.Discount = 0.995
+	}
+	if config.StrengthScale == 0 {
+		config.StrengthScale = 1
+	}
+	if config.MaxErrorRate == 0 {
+		config.MaxErrorRate = 0.1
+	}
+	if config.MaxInterference == 0 {
+		config.MaxInterference = 0.3
+	}
+	if config.MinSamples == 0 {
+		config.MinSamples = 5
+	}
+	if config.Exploration == 0 {
+		config.Exploration = 1
+	}
+	if config.HistoryLength == 0 {
+		config.HistoryLength = 32
+	}
+	if config.Reprobe == 0 {
+		config.Reprobe = 30 * time.Second
+	}
+
+	tracker := &ChannelQualityTracker{
+		config:  config,
+		ids:     plan.IDs(),
+		quality: make(map[string]*ChannelQuality),
+		rewards: make(map[string]float64),
+		rng:     rand.New(rand.NewSource(config.Seed)),
+	}
+	for _, id := range tracker.ids {
+		tracker.quality[id] = &ChannelQuality{Channel: id}
+	}
+	return tracker, nil
+}
+
+// Observe records the outcome of a transmission.
+func (t *ChannelQualityTracker) Observe(observation ChannelObservation) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	q, ok := t.quality[observation.Channel]
+	if !ok {
+		return fmt.Errorf("unknown channel ID %q", observation.Channel)
+	}
+	if observation.ErrorRate < 0 || observation.ErrorRate > 1 {
+		return fmt.Errorf("channel %q: error rate must be in [0, 1], got %g", observation.Channel, observation.ErrorRate)
+	}
+
+	interference := 0.0
+	if observation.Interference {
+		interference = 1
+		q.History = append(q.History, observation.Timestamp)
+		if len(q.History) > t.config.HistoryLength {
+			q.History = q.History[len(q.History)-t.config.HistoryLength:]
+		}
+	}
+	if q.Samples == 0 {
+		q.Strength, q.ErrorRate, q.Interference = observation.Strength, observation.ErrorRate, interference
+	} else {
+		a := t.config.Alpha
+		q.Strength += a * (observation.Strength - q.Strength)
+		q.ErrorRate += a * (observation.ErrorRate - q.ErrorRate)
+		q.Interference += a * (interference - q.Interference)
+	}
+
+	// Every channel's reward history fades at each observation, so the bandit policies
+	// follow channels whose quality changes instead of trusting old outcomes forever
+	reward := clamp01(observation.Strength/t.config.StrengthScale) * (1 - observation.ErrorRate) * (1 - interference)
+	for _, other := range t.quality {
+		other.Weight *= t.config.Discount
+		t.rewards[other.Channel] *= t.config.Discount
+	}
+	q.Weight++
+	t.rewards[q.Channel] += reward
+	q.Reward = t.rewards[q.Channel] / q.Weight
+	q.Samples++
+	q.LastSeen = observation.Timestamp
+	t.observed = t.observed*t.config.Discount + 1
+	return nil
+}
+
+// Quality returns the tracked quality of a channel.
+func (t *ChannelQualityTracker) Quality(id string) (ChannelQuality, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	q, ok := t.quality[id]
+	if !ok {
+		return ChannelQuality{}, fmt.Errorf("unknown channel ID %q", id)
+	}
+	return q.copy(), nil
+}
+
+// Select picks the channel for the next transmission at time now and explains the choice.
+func (t *ChannelQualityTracker) Select(now time.Time) SelectionDecision {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	decision := SelectionDecision{Policy: t.config.Policy}
+	best, reprobe, fallback := -1, -1, -1
+	for _, id := range t.ids {
+		q := t.quality[id]
+		assessment := ChannelAssessment{Quality: q.copy()}
+		assessment.Excluded, assessment.Reprobe, assessment.Reason = t.exclusion(q, now)
+		assessment.Index, assessment.Reason = t.index(q, assessment.Reason)
+		decision.Assessments = append(decision.Assessments, assessment)
+
+		i := len(decision.Assessments) - 1
+		score := q.Score(t.config.StrengthScale)
+		if fallback < 0 || score > decision.Assessments[fallback].Quality.Score(t.config.StrengthScale) {
+			fallback = i
+		}
+		switch {
+		case assessment.Reprobe:
+			if reprobe < 0 || q.LastSeen.Before(decision.Assessments[reprobe].Quality.LastSeen) {
+				reprobe = i
+			}
+		case !assessment.Excluded && (best < 0 || assessment.Index > decision.Assessments[best].Index):
+			best = i
+		}
+	}
+	if reprobe >= 0 {
+		best = reprobe
+	}
+	if best < 0 {
+		best = fallback
+		decision.Fallback = true
+	}
+	decision.Channel = decision.Assessments[best].Quality.Channel
+	return decision
+}
+
+// exclusion reports whether the thresholds exclude a channel or it is due for a re-probe,
+// and why. Excluded channels are tried again once they have gone unused for the reprobe
+// interval, so stale exclusions expire.
+func (t *ChannelQualityTracker) exclusion(q *ChannelQuality, now time.Time) (excluded, reprobe bool, reason string) {
+	if q.Samples < t.config.MinSamples {
+		return false, false, fmt.Sprintf("%d/%d samples, still exploring", q.Samples, t.config.MinSamples)
+	}
+	var reasons []string
+	if t.config.MinStrength > 0 && q.Strength < t.config.MinStrength {
+		reasons = append(reasons, fmt.Sprintf("strength %.2f below %.2f", q.Strength, t.config.MinStrength))
+	}
+	if q.ErrorRate > t.config.MaxErrorRate {
+		reasons = append(reasons, fmt.Sprintf("error rate %.3f above %.3f", q.ErrorRate, t.config.MaxErrorRate))
+	}
+	if q.Interference > t.config.MaxInterference {
+		reasons = append(reasons, fmt.Sprintf("interference in %.0f%% of recent observations, above %.0f%%", 100*q.Interference, 100*t.config.MaxInterference))
+	}
+	if len(reasons) == 0 {
+		return false, false, fmt.Sprintf("strength %.2f, error rate %.3f, interference %.0f%%", q.Strength, q.ErrorRate, 100*q.Interference)
+	}
+	if n := len(q.History); n > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d interference events, last %v ago", n, now.Sub(q.History[n-1]).Round(time.Millisecond)))
+	}
+	if since := now.Sub(q.LastSeen); since >= t.config.Reprobe {
+		return false, true, fmt.Sprintf("%s; re-probing after %v unused", strings.Join(reasons, "; "), since.Round(time.Millisecond))
+	}
+	return true, false, strings.Join(reasons, "; ")
+}
+
+// index returns the policy's ranking index for a channel and extends the reason with it.
+func (t *ChannelQualityTracker) index(q *ChannelQuality, reason string) (float64, string) {
+	switch t.config.Policy {
+	case PolicyUCB:
+		if q.Samples == 0 {
+			return math.Inf(1), reason + "; untried"
+		}
+		bonus := t.config.Exploration * math.Sqrt(2*math.Log(math.Max(t.observed, 1))/q.Weight)
+		return q.Reward + bonus, fmt.Sprintf("%s; mean reward %.3f + exploration %.3f", reason, q.Reward, bonus)
+	case PolicyThompson:
+		a, b := 1+t.rewards[q.Channel], 1+q.Weight-t.rewards[q.Channel]
+		sample := betaSample(t.rng, a, b)
+		return sample, fmt.Sprintf("%s; sampled %.3f from Beta(%.1f, %.1f)", reason, sample, a, b)
+	default:
+		if q.Samples < t.config.MinSamples {
+			// Unexplored channels rank above every measured score, least sampled first
+			return 2 - float64(q.Samples)/float64(t.config.MinSamples), reason
+		}
+		score := q.Score(t.config.StrengthScale)
+		return score, fmt.Sprintf("%s; score %.3f", reason, score)
+	}
+}
+
+// copy returns a snapshot of the quality that does not share its history.
+func (q *ChannelQuality) copy() ChannelQuality {
+	snapshot := *q
+	snapshot.History = append([]time.Time(nil), q.History...)
+	return snapshot
+}
+
+// clamp01 limits x to [0, 1].
+func clamp01(x float64) float64 {
+	return math.Max(0, math.Min(1, x))
+}
+
+// betaSample draws from Beta(a, b) as a ratio of gamma variates.
+func betaSample(rng *rand.Rand, a, b float64) float64 {
+	x := gammaSample(rng, a)
+	y := gammaSample(rng, b)
+	return x / (x + y)
+}
+
+// gammaSample draws from Gamma(shape, 1) by the Marsaglia–Tsang method.
+func gammaSample(rng *rand.Rand, shape float64) float64 {
+	if shape < 1 {
+		return gammaSample(rng, shape+1) * math.Pow(rng.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
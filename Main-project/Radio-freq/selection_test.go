@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestChannelSelection checks that each policy settles on the best channel of a simulated
+// plan, that a channel with heavy interference is excluded with a reason, and that each
+// policy moves to the next best channel once the best one fades.
+func TestChannelSelection(t *testing.T) {
+	plan, err := PlanFromFrequencies([]float64{2.4e9, 5.8e9, 8.5e9, 12.0e9}, 20e6, 5e6)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	ids := plan.IDs()
+	// True mean strength, error rate and interference probability of each channel
+	type channelModel struct{ strength, errorRate, interference float64 }
+	models := map[string]channelModel{
+		ids[0]: {0.55, 0.02, 0.02},
+		ids[1]: {0.90, 0.03, 0.60}, // Strong but jammed
+		ids[2]: {0.80, 0.01, 0.02}, // Best
+		ids[3]: {0.40, 0.05, 0.05},
+	}
+	const rounds = 3000
+
+	for _, policy := range []SelectionPolicy{PolicyUCB, PolicyThompson, PolicyBlacklist} {
+		tracker, err := NewChannelQualityTracker(plan, ChannelQualityConfig{Policy: policy, Seed: 7, MinStrength: 0.3, Exploration: 0.3, Reprobe: 5 * time.Second})
+		if err != nil {
+			t.Errorf("%v: %v", policy, err)
+			continue
+		}
+		rng := rand.New(rand.NewSource(int64(policy) + 11))
+		start := time.Unix(1700000000, 0)
+		picks := make(map[string]int)
+		faded := make(map[string]int)
+		var settled SelectionDecision
+		for round := 0; round < 2*rounds; round++ {
+			now := start.Add(time.Duration(round) * 100 * time.Millisecond)
+			decision := tracker.Select(now)
+			model := models[decision.Channel]
+			if round >= rounds && decision.Channel == ids[2] {
+				model.strength = 0.4 // Fades below the first channel but stays above MinStrength
+			}
+			observation := ChannelObservation{
+				Channel:      decision.Channel,
+				Strength:     clamp01(model.strength + 0.1*rng.NormFloat64()),
+				ErrorRate:    clamp01(model.errorRate * 2 * rng.Float64()),
+				Interference: rng.Float64() < model.interference,
+				Timestamp:    now,
+			}
+			if observation.Interference {
+				observation.ErrorRate = clamp01(observation.ErrorRate + 0.3)
+			}
+			tracker.Observe(observation)
+			switch {
+			case round >= 3*rounds/2:
+				faded[decision.Channel]++
+			case round >= rounds/2 && round < rounds:
+				picks[decision.Channel]++
+			}
+			if round == rounds-1 {
+				settled = decision
+			}
+		}
+
+		if float64(picks[ids[2]]) < 0.85*rounds/2 || settled.Channel != ids[2] {
+			t.Errorf("%v: best channel picked %d/%d times in the second half %v, settled on %s",
+				policy, picks[ids[2]], rounds/2, picks, settled.Channel)
+		}
+		if float64(faded[ids[0]]) < 0.7*rounds/2 {
+			t.Errorf("%v: next best channel picked %d/%d times after the best faded %v",
+				policy, faded[ids[0]], rounds/2, faded)
+		}
+		if jammed := settled.Assessments[1]; !(jammed.Excluded || jammed.Reprobe) || !strings.Contains(jammed.Reason, "interference") {
+			t.Errorf("%v: jammed channel not excluded for interference: %+v", policy, jammed)
+		}
+	}
+}
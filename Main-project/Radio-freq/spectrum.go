@@ -0,0 +1,512 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"sort"
+)
+
+// SpectrumConfig configures a spectrum monitor tuned to one span.
+type SpectrumConfig struct {
+	CenterFrequency    float64 // Tuned center, in Hz
+	SampleRate         float64 // Complex sample rate, and so the monitored span, in Hz
+	FFTSize            int     // Welch segment length, a power of two; 0 means 1024
+	Overlap            float64 // Fraction of each segment shared with the next; 0 means 0.5
+	Averages           int     // Periodograms averaged into each PSD frame; 0 means 8
+	WaterfallRows      int     // PSD frames kept in the waterfall; 0 means 256
+	MergeBins          int     // Undetected bins bridged inside one detection; 0 means 2
+	FalseAlarm         float64 // CFAR false alarm probability per bin; 0 means 1e-4
+	NarrowbandFraction float64 // Detections narrower than this fraction of a channel's bandwidth are interferers; 0 means 0.1
+	NoiseFloor         float64 // Noise power across the span when synthesizing IQ from RFSignals; 0 means 1e-3
+	Seed               int64   // Seed for synthesized noise and phases
+}
+
+// DetectionKind classifies a spectral detection.
+type DetectionKind int
+
+const (
+	DetectionOccupied   DetectionKind = iota // Fills a significant part of a channel
+	DetectionInterferer                      // Narrowband, or outside every channel
+)
+
+// String returns the detection kind.
+func (k DetectionKind) String() string {
+	switch k {
+	case DetectionOccupied:
+		return "occupied"
+	case DetectionInterferer:
+		return "narrowband interferer"
+	}
+	return fmt.Sprintf("DetectionKind(%d)", int(k))
+}
+
+// SpectralDetection is a run of adjacent bins that crossed the CFAR threshold in one frame.
+type SpectralDetection struct {
+	Kind      DetectionKind
+	Channel   string  // Channel containing the peak, guard bands included; empty if none
+	Frequency float64 // Peak frequency, in Hz
+	Low       float64 // Lower edge of the run, in Hz
+	High      float64 // Upper edge of the run, in Hz
+	PowerDB   float64 // Peak power spectral density, in dB relative to 1 per Hz
+	SNRdB     float64 // Peak PSD over the CFAR noise estimate
+}
+
+// Bandwidth returns the width of the detection, in Hz.
+func (d SpectralDetection) Bandwidth() float64 {
+	return d.High - d.Low
+}
+
+// SpectrumFrame is one Welch PSD and the detections made on it.
+type SpectrumFrame struct {
+	Index      int
+	PSD        []float64 // In dB relative to 1 per Hz, lowest frequency first
+	NoiseDB    float64   // Global noise floor estimate
+	Detections []SpectralDetection
+}
+
+// ChannelOccupancy is the occupancy of one channel over the frames a monitor has processed.
+type ChannelOccupancy struct {
+	Channel            string
+	Frames             int
+	OccupiedFrames     int
+	InterferenceFrames int     // Frames with a narrowband interferer inside the channel or its guard bands
+	Occupancy          float64 // OccupiedFrames / Frames
+	MeanPowerDB        float64 // Mean in-band power, in dB relative to 1
+	NoiseFloorDB       float64 // Mean noise floor, in dB relative to 1 per Hz
+}
+
+// spectrumChannel is a channel inside the monitored span and its running statistics.
+type spectrumChannel struct {
+	RFChannel
+	low, high           int // Bin range of the channel bandwidth, inclusive
+	frames, occupied    int
+	interference        int
+	power, noise        float64 // Sums of linear in-band power and noise floor
+	occupiedThisFrame   bool
+	interferedThisFrame bool
+}
+
+// SpectrumMonitor computes a running Welch PSD over IQ samples, keeps a waterfall of PSD
+// frames, detects occupied channels and narrowband interferers with a CFAR detector, and
+// accumulates per-channel occupancy.
+type SpectrumMonitor struct {
+	config    SpectrumConfig
+	window    []float64
+	scale     float64 // Periodogram normalization to power spectral density
+	alpha     float64 // CFAR threshold factor
+	lowestQ   float64 // Expected mean of the lowest quartile of noise-only bins, relative to the mean
+	channels  []*spectrumChannel
+	rng       *rand.Rand
+	pending   []complex128
+	sum       []float64
+	segments  int
+	frames    int
+	latest    SpectrumFrame
+	waterfall [][]float64
+}
+
+// NewSpectrumMonitor creates a monitor for the channels of plan that lie inside the span.
+func NewSpectrumMonitor(plan *ChannelPlan, config SpectrumConfig) (*SpectrumMonitor, error) {
+	if config.FFTSize == 0 {
+		config.FFTSize = 1024
+	}
+	if config.Overlap == 0 {
+		config.Overlap = 0.5
+	}
+	if config.Averages == 0 {
+		config.Averages = 8
+	}
+	if config.WaterfallRows == 0 {
+		config.WaterfallRows = 256
+	}
+	if config.MergeBins == 0 {
+		config.MergeBins = 2
+	}
+	if config.FalseAlarm == 0 {
+		config.FalseAlarm = 1e-4
+	}
+	if config.NarrowbandFraction == 0 {
+		config.NarrowbandFraction = 0.1
+	}
+	if config.NoiseFloor == 0 {
+		config.NoiseFloor = 1e-3
+	}
+	switch {
+	case config.CenterFrequency <= 0 || config.SampleRate <= 0:
+		return nil, fmt.Errorf("spectrum monitor needs a positive center frequency and sample rate, got %g Hz and %g Hz", config.CenterFrequency, config.SampleRate)
+	case config.FFTSize < 16 || config.FFTSize&(config.FFTSize-1) != 0:
+		return nil, fmt.Errorf("FFT size must be a power of two of at least 16, got %d", config.FFTSize)
+	case config.Overlap < 0 || config.Overlap >= 1:
+		return nil, fmt.Errorf("overlap must be in [0, 1), got %g", config.Overlap)
+	case config.Averages < 0 || config.WaterfallRows < 0 || config.MergeBins < 0:
+		return nil, errors.New("averages, waterfall rows and merge bins must not be negative")
+	case config.FalseAlarm < 0 || config.FalseAlarm >= 1:
+		return nil, fmt.Errorf("false alarm probability must be in (0, 1), got %g", config.FalseAlarm)
+	}
+
+	n := config.FFTSize
+	m := &SpectrumMonitor{
+		config: config,
+		window: make([]float64, n),
+		rng:    rand.New(rand.NewSource(config.Seed)),
+		sum:    make([]float64, n),
+	}
+	var energy float64
+	for i := range m.window {
+		m.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n)) // Periodic Hann
+		energy += m.window[i] * m.window[i]
+	}
+	m.scale = 1 / (config.SampleRate * energy)
+
+	// Averaged periodogram bins of noise are close to Gamma(K, 1/K) relative to their mean,
+	// with K reduced by the correlation between overlapping segments
+	step := int(float64(n) * (1 - config.Overlap))
+	correlation := 0.0
+	for j := 1; j < config.Averages && j*step < n; j++ {
+		var shared float64
+		for i := 0; i+j*step < n; i++ {
+			shared += m.window[i] * m.window[i+j*step]
+		}
+		rho := shared / energy
+		correlation += 2 * (1 - float64(j)/float64(config.Averages)) * rho * rho
+	}
+	k := float64(config.Averages) / (1 + correlation)
+	m.alpha = gammaQuantile(k, 1-config.FalseAlarm)
+	for i := 0; i < 50; i++ {
+		m.lowestQ += gammaQuantile(k, 0.25*(float64(i)+0.5)/50) / 50
+	}
+
+	binWidth := config.SampleRate / float64(n)
+	low := config.CenterFrequency - config.SampleRate/2
+	for _, ch := range plan.channels {
+		first := int(math.Ceil((ch.Center - ch.Bandwidth/2 - low) / binWidth))
+		last := int(math.Floor((ch.Center + ch.Bandwidth/2 - low) / binWidth))
+		if first < 0 || last >= n || last < first {
+			continue // Not inside the span
+		}
+		m.channels = append(m.channels, &spectrumChannel{RFChannel: ch, low: first, high: last})
+	}
+	return m, nil
+}
+
+// ProcessIQ feeds complex baseband samples and returns the PSD frames they completed.
+func (m *SpectrumMonitor) ProcessIQ(samples []complex128) []SpectrumFrame {
+	n := m.config.FFTSize
+	step := int(float64(n) * (1 - m.config.Overlap))
+	if step < 1 {
+		step = 1
+	}
+	m.pending = append(m.pending, samples...)
+
+	var frames []SpectrumFrame
+	segment := make([]complex128, n)
+	for len(m.pending) >= n {
+		for i := range segment {
+			segment[i] = m.pending[i] * complex(m.window[i], 0)
+		}
+		fft(segment, false)
+		for i, x := range segment {
+			// Shift so that bin 0 is the lowest frequency
+			m.sum[(i+n/2)%n] += real(x)*real(x) + imag(x)*imag(x)
+		}
+		m.pending = m.pending[step:]
+		m.segments++
+		if m.segments == m.config.Averages {
+			frames = append(frames, m.frame())
+		}
+	}
+	m.pending = append([]complex128(nil), m.pending...)
+	return frames
+}
+
+// ProcessSignals synthesizes about the given number of IQ samples containing the signals
+// inside the span and processes them. Signals on a channel of the plan fill its bandwidth;
+// others are synthesized as tones. SignalStrength is the signal power relative to 1.
+func (m *SpectrumMonitor) ProcessSignals(signals []RFSignal, samples int) ([]SpectrumFrame, error) {
+	if samples <= 0 {
+		return nil, fmt.Errorf("sample count must be positive, got %d", samples)
+	}
+	n := 1
+	for n < samples {
+		n <<= 1
+	}
+	spectrum := make([]complex128, n)
+	resolution := m.config.SampleRate / float64(n)
+	bin := func(f float64) int {
+		return int(math.Round((f-m.config.CenterFrequency)/resolution)) + n/2
+	}
+	add := func(k int, power float64) {
+		if k < 0 || k >= n {
+			return
+		}
+		spectrum[(k+n/2)%n] += cmplx.Rect(math.Sqrt(power)*float64(n), 2*math.Pi*m.rng.Float64())
+	}
+	for _, signal := range signals {
+		bandwidth := 0.0
+		for _, ch := range m.channels {
+			if ch.ID == signal.Channel {
+				bandwidth = ch.Bandwidth
+			}
+		}
+		if bandwidth == 0 {
+			add(bin(signal.Frequency), signal.SignalStrength)
+			continue
+		}
+		first, last := bin(signal.Frequency-bandwidth/2), bin(signal.Frequency+bandwidth/2)
+		for k := first; k <= last; k++ {
+			add(k, signal.SignalStrength/float64(last-first+1))
+		}
+	}
+	fft(spectrum, true)
+	sigma := math.Sqrt(m.config.NoiseFloor / 2)
+	for i := range spectrum {
+		spectrum[i] += complex(sigma*m.rng.NormFloat64(), sigma*m.rng.NormFloat64())
+	}
+	return m.ProcessIQ(spectrum[:samples]), nil
+}
+
+// frame completes a Welch average, runs detection and updates the waterfall and occupancy.
+func (m *SpectrumMonitor) frame() SpectrumFrame {
+	n := m.config.FFTSize
+	psd := make([]float64, n)
+	for i, s := range m.sum {
+		psd[i] = s * m.scale / float64(m.segments)
+		m.sum[i] = 0
+	}
+	m.segments = 0
+
+	frame := SpectrumFrame{Index: m.frames, PSD: make([]float64, n)}
+	for i, p := range psd {
+		frame.PSD[i] = 10 * math.Log10(p)
+	}
+	noise := m.noiseFloor(psd)
+	frame.NoiseDB = 10 * math.Log10(noise)
+	frame.Detections = m.detect(psd, noise)
+	m.frames++
+
+	m.waterfall = append(m.waterfall, frame.PSD)
+	if len(m.waterfall) > m.config.WaterfallRows {
+		m.waterfall = m.waterfall[len(m.waterfall)-m.config.WaterfallRows:]
+	}
+
+	binWidth := m.config.SampleRate / float64(n)
+	for _, ch := range m.channels {
+		ch.frames++
+		for k := ch.low; k <= ch.high; k++ {
+			ch.power += psd[k] * binWidth
+		}
+		ch.noise += noise
+		if ch.occupiedThisFrame {
+			ch.occupied++
+		}
+		if ch.interferedThisFrame {
+			ch.interference++
+		}
+		ch.occupiedThisFrame, ch.interferedThisFrame = false, false
+	}
+	m.latest = frame
+	return frame
+}
+
+// noiseFloor estimates the mean noise PSD from the lowest quarter of the bins, so that
+// occupied channels covering most of the span do not raise it.
+func (m *SpectrumMonitor) noiseFloor(psd []float64) float64 {
+	sorted := append([]float64(nil), psd...)
+	sort.Float64s(sorted)
+	quarter := sorted[:len(sorted)/4]
+	var sum float64
+	for _, p := range quarter {
+		sum += p
+	}
+	return sum / float64(len(quarter)) / m.lowestQ
+}
+
+// detect runs an ordered-statistic CFAR over the PSD and groups the detected bins into runs.
+// The whole frame is the reference window and its lowest quarter the ordered statistic, so
+// channels wider than any local window, or filling most of the span, are still detected.
+func (m *SpectrumMonitor) detect(psd []float64, noise float64) []SpectralDetection {
+	n := len(psd)
+	detected := make([]bool, n)
+	for i, p := range psd {
+		detected[i] = p > m.alpha*noise
+	}
+
+	binWidth := m.config.SampleRate / float64(n)
+	low := m.config.CenterFrequency - m.config.SampleRate/2
+	var detections []SpectralDetection
+	for i := 0; i < n; {
+		if !detected[i] {
+			i++
+			continue
+		}
+		// Extend the run across gaps of up to MergeBins bins
+		end, peak := i, i
+		for j := i; j < n && j-end <= m.config.MergeBins+1; j++ {
+			if detected[j] {
+				end = j
+				if psd[j] > psd[peak] {
+					peak = j
+				}
+			}
+		}
+		detection := SpectralDetection{
+			Kind:      DetectionInterferer,
+			Frequency: low + (float64(peak)+0.5)*binWidth,
+			Low:       low + float64(i)*binWidth,
+			High:      low + float64(end+1)*binWidth,
+			PowerDB:   10 * math.Log10(psd[peak]),
+			SNRdB:     10 * math.Log10(psd[peak]/noise),
+		}
+		for _, ch := range m.channels {
+			if detection.Frequency < ch.Low() || detection.Frequency > ch.High() {
+				continue
+			}
+			detection.Channel = ch.ID
+			if detection.Bandwidth() >= m.config.NarrowbandFraction*ch.Bandwidth {
+				detection.Kind = DetectionOccupied
+				ch.occupiedThisFrame = true
+			} else {
+				ch.interferedThisFrame = true
+			}
+		}
+		detections = append(detections, detection)
+		i = end + 1
+	}
+	return detections
+}
+
+// PSD returns the latest PSD frame, or an empty frame if none has completed.
+func (m *SpectrumMonitor) PSD() SpectrumFrame {
+	return m.latest
+}
+
+// Frequencies returns the center frequency of each PSD bin, lowest first.
+func (m *SpectrumMonitor) Frequencies() []float64 {
+	n := m.config.FFTSize
+	binWidth := m.config.SampleRate / float64(n)
+	frequencies := make([]float64, n)
+	for i := range frequencies {
+		frequencies[i] = m.config.CenterFrequency + (float64(i-n/2)+0.5)*binWidth
+	}
+	return frequencies
+}
+
+// Waterfall returns the kept PSD frames in dB, oldest first.
+func (m *SpectrumMonitor) Waterfall() [][]float64 {
+	return append([][]float64(nil), m.waterfall...)
+}
+
+// Occupancy returns the statistics of each channel inside the span, in frequency order.
+func (m *SpectrumMonitor) Occupancy() []ChannelOccupancy {
+	occupancy := make([]ChannelOccupancy, len(m.channels))
+	for i, ch := range m.channels {
+		o := ChannelOccupancy{Channel: ch.ID, Frames: ch.frames, OccupiedFrames: ch.occupied, InterferenceFrames: ch.interference}
+		o.MeanPowerDB, o.NoiseFloorDB = math.Inf(-1), math.Inf(-1)
+		if ch.frames > 0 {
+			o.Occupancy = float64(ch.occupied) / float64(ch.frames)
+			o.MeanPowerDB = 10 * math.Log10(ch.power/float64(ch.frames))
+			o.NoiseFloorDB = 10 * math.Log10(ch.noise/float64(ch.frames))
+		}
+		occupancy[i] = o
+	}
+	return occupancy
+}
+
+// String returns the channel occupancy summary.
+func (o ChannelOccupancy) String() string {
+	return fmt.Sprintf("%s: occupied %.0f%% of %d frames, interference in %d, mean power %.1f dB, noise floor %.1f dB/Hz",
+		o.Channel, 100*o.Occupancy, o.Frames, o.InterferenceFrames, o.MeanPowerDB, o.NoiseFloorDB)
+}
+
+// ScanSpectrum tunes a monitor to each channel in turn, with a span of twice the channel
+// and its guard bands unless config sets a sample rate, synthesizes the given number of IQ
+// samples from the current signals and returns every channel's occupancy in plan order.
+func (rfh *RFHandler) ScanSpectrum(config SpectrumConfig, samples int) ([]ChannelOccupancy, error) {
+	rfh.mu.Lock()
+	signals := append([]RFSignal(nil), rfh.signals...)
+	rfh.mu.Unlock()
+
+	occupancy := make([]ChannelOccupancy, 0, len(rfh.plan.channels))
+	for i, ch := range rfh.plan.channels {
+		tuned := config
+		tuned.CenterFrequency = ch.Center
+		if tuned.SampleRate == 0 {
+			tuned.SampleRate = 2 * (ch.High() - ch.Low())
+		}
+		tuned.Seed = config.Seed + int64(i)
+		monitor, err := NewSpectrumMonitor(rfh.plan, tuned)
+		if err != nil {
+			return nil, fmt.Errorf("channel %q: %v", ch.ID, err)
+		}
+		if _, err := monitor.ProcessSignals(signals, samples); err != nil {
+			return nil, err
+		}
+		for _, o := range monitor.Occupancy() {
+			if o.Channel == ch.ID {
+				occupancy = append(occupancy, o)
+			}
+		}
+	}
+	return occupancy, nil
+}
+
+// fft computes the discrete Fourier transform of x in place; the inverse is scaled by 1/n.
+// The length of x must be a power of two.
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	sign := -1.0
+	if inverse {
+		sign = 1
+	}
+	for size := 2; size <= n; size <<= 1 {
+		half := size / 2
+		for k := 0; k < half; k++ {
+			w := cmplx.Rect(1, sign*2*math.Pi*float64(k)/float64(size))
+			for start := k; start < n; start += size {
+				t := w * x[start+half]
+				x[start+half] = x[start] - t
+				x[start] += t
+			}
+		}
+	}
+	if inverse {
+		for i := range x {
+			x[i] /= complex(float64(n), 0)
+		}
+	}
+}
+
+// gammaQuantile returns the p quantile of Gamma(k, 1/k), which has mean 1, by the
+// Wilson–Hilferty approximation.
+func gammaQuantile(k, p float64) float64 {
+	c := 1 / (9 * k)
+	q := 1 - c + normalQuantile(p)*math.Sqrt(c)
+	return math.Max(q*q*q, 0)
+}
+
+// normalQuantile returns the p quantile of the standard normal distribution.
+func normalQuantile(p float64) float64 {
+	low, high := -40.0, 40.0
+	for i := 0; i < 100; i++ {
+		mid := (low + high) / 2
+		if 0.5*math.Erfc(-mid/math.Sqrt2) < p {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// spectrumTestPlan is three 100 kHz channels spaced 250 kHz apart around 2.2 GHz.
+func spectrumTestPlan(t *testing.T) *ChannelPlan {
+	plan, err := NewChannelPlan(nil,
+		RFChannel{ID: "A", Center: 2.2e9 - 250e3, Bandwidth: 100e3, Guard: 25e3},
+		RFChannel{ID: "B", Center: 2.2e9, Bandwidth: 100e3, Guard: 25e3},
+		RFChannel{ID: "C", Center: 2.2e9 + 250e3, Bandwidth: 100e3, Guard: 25e3},
+	)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	return plan
+}
+
+// TestSpectrumFalseAlarm checks that on noise only the fraction of bins detected is near
+// the configured CFAR false alarm rate.
+func TestSpectrumFalseAlarm(t *testing.T) {
+	config := SpectrumConfig{CenterFrequency: 2.2e9, SampleRate: 1e6, FalseAlarm: 1e-3, WaterfallRows: 16, Seed: 5}
+	monitor, err := NewSpectrumMonitor(spectrumTestPlan(t), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(9))
+	samples := make([]complex128, 1<<19)
+	for i := range samples {
+		samples[i] = complex(rng.NormFloat64(), rng.NormFloat64())
+	}
+	var detectedBins, bins float64
+	for _, frame := range monitor.ProcessIQ(samples) {
+		for _, d := range frame.Detections {
+			detectedBins += d.Bandwidth() / (config.SampleRate / float64(len(frame.PSD)))
+		}
+		bins += float64(len(frame.PSD))
+	}
+	if rate := detectedBins / bins; rate > 5*config.FalseAlarm {
+		t.Errorf("%.2e of bins detected, configured %.0e", rate, config.FalseAlarm)
+	}
+	if rows := len(monitor.Waterfall()); rows != 16 {
+		t.Errorf("%d waterfall rows, want 16", rows)
+	}
+}
+
+// TestSpectrumOccupancy synthesizes a carrier filling A, nothing on B, and a weak unassigned
+// tone inside C, and checks occupancy and interferer detection.
+func TestSpectrumOccupancy(t *testing.T) {
+	config := SpectrumConfig{CenterFrequency: 2.2e9, SampleRate: 1e6, FalseAlarm: 1e-3, WaterfallRows: 16, Seed: 5}
+	monitor, err := NewSpectrumMonitor(spectrumTestPlan(t), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signals := []RFSignal{
+		{ID: "carrier", Channel: "A", Frequency: 2.2e9 - 250e3, SignalStrength: 0.05},
+		{ID: "spur", Frequency: 2.2e9 + 262e3, SignalStrength: 0.002},
+	}
+	var interferers int
+	for i := 0; i < 8; i++ {
+		frames, err := monitor.ProcessSignals(signals, 1<<16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, frame := range frames {
+			for _, d := range frame.Detections {
+				if d.Kind == DetectionInterferer && d.Channel == "C" && math.Abs(d.Frequency-(2.2e9+262e3)) < 2e3 {
+					interferers++
+				}
+			}
+		}
+	}
+	if _, err := monitor.ProcessSignals(signals, -1); err == nil {
+		t.Errorf("ProcessSignals accepted a negative sample count")
+	}
+	occupancy := monitor.Occupancy()
+	if occupancy[0].Occupancy < 0.95 {
+		t.Errorf("carrier channel: %v", occupancy[0])
+	}
+	if occupancy[1].Occupancy > 0.05 || occupancy[2].Occupancy > 0.05 {
+		t.Errorf("idle channels: %v; %v", occupancy[1], occupancy[2])
+	}
+	if occupancy[2].InterferenceFrames < occupancy[2].Frames*9/10 || interferers == 0 {
+		t.Errorf("spur found in %d frames: %v", interferers, occupancy[2])
+	}
+}
+
+// TestScanSpectrum checks RFHandler's per-channel scan over the simulation's frequency
+// points with two busy channels.
+func TestScanSpectrum(t *testing.T) {
+	points, err := PlanFromFrequencies([]float64{2.4e9, 5.8e9, 8.5e9, 12.0e9}, 20e6, 5e6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rfh := NewRFHandler(points)
+	for _, id := range []string{"CH-5800MHz", "CH-12000MHz"} {
+		signal, err := rfh.GenerateChannelSignal(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signal.SignalStrength = 0.5
+		rfh.AddSignal(signal)
+	}
+	scan, err := rfh.ScanSpectrum(SpectrumConfig{Seed: 1}, 1<<15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scan) != 4 {
+		t.Fatalf("scanned %d channels, want 4", len(scan))
+	}
+	for i, busy := range []bool{false, true, false, true} {
+		if busy && scan[i].Occupancy < 0.95 || !busy && scan[i].Occupancy > 0.05 {
+			t.Errorf("busy %v: %v", busy, scan[i])
+		}
+	}
+}